@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+const (
+	// maxCandidates is the largest --count; the picker selects 1-9 directly.
+	maxCandidates = 9
+	// candidateMaxTokens is the response budget reserved per candidate.
+	candidateMaxTokens = 200
+)
+
+// pickCommand lets the user choose one of candidates. Without a terminal to
+// show the picker on, all candidates are printed and "" is returned, as it is
+// when the picker is canceled. A candidate picked for refinement is returned
+// with errRefine.
+func pickCommand(candidates []prompt.Candidate) (string, error) {
+	if !ui.Interactive() {
+		printCandidates(candidates)
+
+		return "", nil
+	}
+
+	choices := make([]ui.Choice, 0, len(candidates))
+	for _, c := range candidates {
+		choices = append(choices, ui.Choice{Label: c.Command, Detail: riskLabel(c.Command, c.Rationale)})
+	}
+
+	choice, err := ui.Pick(choices)
+	if err != nil {
+		if errors.Is(err, ui.ErrPickCanceled) {
+			return "", nil
+		}
+
+		if errors.Is(err, ui.ErrPickRefine) {
+			return candidates[choice].Command, errRefine
+		}
+
+		return "", errors.Wrap(err, "failed to pick a command")
+	}
+
+	return candidates[choice].Command, nil
+}
+
+// printCandidates prints every candidate. Quiet mode prints one command per
+// line and nothing else, so the output can be piped.
+func printCandidates(candidates []prompt.Candidate) {
+	if quietFlag {
+		for _, c := range candidates {
+			fmt.Println(c.Command)
+		}
+
+		return
+	}
+
+	items := make([]ui.Annotation, 0, len(candidates))
+	for i, c := range candidates {
+		items = append(items, ui.Annotation{Text: fmt.Sprintf("%d. %s", i+1, c.Command), Description: riskLabel(c.Command, c.Rationale)})
+	}
+
+	ui.PrintAnnotations(items)
+}
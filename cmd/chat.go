@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+var chatCmd = &cobra.Command{
+	Use:   "chat [query]",
+	Short: "Refine a command over several turns, then insert it",
+	Long: `Start a conversation about a command. Describe the task, then ask for changes
+such as "make it recursive" or "use fd instead"; every turn is sent to the
+provider, so it keeps the context.
+
+Press enter on an empty line to insert the current command, or type /quit
+(or press Ctrl-D) to leave without one.`,
+	RunE: runChat,
+}
+
+func runChat(cmd *cobra.Command, args []string) error {
+	settings, err := resolveSettings()
+	if err != nil {
+		return err
+	}
+
+	// A session may end without a command to record with its usage
+	defer recordUsage(settings)
+
+	opts := settings.promptOptions(context.Background())
+
+	system, err := prompt.System(opts)
+	if err != nil {
+		return err
+	}
+
+	return chat(cmd, settings, provider.NewConversation(system), opts, suggestion{query: strings.Join(args, " ")})
+}
+
+// errRefine is returned by the picker and the confirmation steps when the
+// user presses r to refine the suggestion in a chat instead.
+var errRefine = errors.New("refinement requested")
+
+// refineSuggestion starts a chat seeded with the query and command of s.
+func refineSuggestion(cmd *cobra.Command, settings *querySettings, opts prompt.Options, s suggestion) error {
+	system, err := prompt.System(opts)
+	if err != nil {
+		return err
+	}
+
+	conversation := provider.NewConversation(system)
+	conversation.AddUser(s.query)
+	conversation.AddAssistant(s.command)
+
+	return chat(cmd, settings, conversation, opts, s)
+}
+
+// chat runs the refinement loop. The conversation may already hold the
+// command of s; otherwise the query of s, if any, is sent before the user is
+// asked for more.
+func chat(cmd *cobra.Command, settings *querySettings, conversation *provider.Conversation, opts prompt.Options, s suggestion) error {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	if s.command == "" && s.query != "" {
+		s.refine(settings, conversation, s.query)
+	}
+
+	ui.PrintInfo("Describe a change, press enter to use the command, or /quit")
+
+	for {
+		if s.command != "" {
+			ui.PrintCommand(s.command)
+		}
+
+		_, _ = fmt.Fprint(os.Stderr, "> ")
+
+		if !scanner.Scan() {
+			_, _ = fmt.Fprintln(os.Stderr)
+
+			return errors.Wrap(scanner.Err(), "failed to read input")
+		}
+
+		switch input := strings.TrimSpace(scanner.Text()); {
+		case input == "/quit" || input == "/exit":
+			return nil
+		case input == "" && s.command != "":
+			// Pressing r at a confirmation comes back here
+			if err := finish(cmd, settings, s, opts); !errors.Is(err, errRefine) {
+				return err
+			}
+		case input != "":
+			if s.query == "" {
+				s.query = input
+			}
+
+			s.refine(settings, conversation, input)
+		}
+	}
+}
+
+// refine sends input as the next user turn and makes the answer the current
+// command. Both turns are only kept when the provider answers, so a failed
+// request can simply be retried; failures are reported and change nothing.
+func (s *suggestion) refine(settings *querySettings, conversation *provider.Conversation, input string) {
+	req := settings.request(prompt.Prompt{})
+	req.Messages = append(conversation.Turns(), provider.Turn{Role: provider.RoleUser, Content: input})
+
+	start := time.Now()
+	preview := ui.NewPreview()
+	response, err := settings.query(req, preview.Write)
+	preview.Clear()
+
+	s.latency += time.Since(start)
+
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to query %s: %v", settings.provider.Name, err))
+
+		return
+	}
+
+	command := prompt.SanitizeCommand(response)
+	if command == "" {
+		ui.PrintWarning(settings.provider.Name + " returned an empty command")
+
+		return
+	}
+
+	conversation.AddUser(input)
+	conversation.AddAssistant(command)
+
+	s.command = command
+	s.refinable = true
+}
+
+func init() {
+	rootCmd.AddCommand(chatCmd)
+}
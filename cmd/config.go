@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit the configuration file",
+	Long: `Inspect and edit howto's configuration file.
+
+The file is TOML and lives at $XDG_CONFIG_HOME/howto/config.toml
+(~/.config/howto/config.toml by default). Set HOWTO_CONFIG to use another
+file, e.g. a profile checked into your team's repository.
+
+Example:
+  profile = "work"
+
+  [profiles.work]
+  provider = "Anthropic"
+  model = "claude-sonnet-4-20250514"
+  timeout = "45s"
+  max_tokens = 500
+  temperature = 0.2
+  instructions = ["Prefer rg over grep", "Prefer fd over find"]
+
+Keys are dotted paths, e.g. profiles.work.model.`,
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the configuration file path",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigPath,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all configuration values",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigList,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print a configuration value",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a configuration value",
+	Long: `Set a configuration value. Numbers, booleans, quoted strings and arrays
+are read as TOML literals; anything else is stored as a string.
+
+Note that comments in the file are not preserved.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+func runConfigPath(cmd *cobra.Command, _ []string) error {
+	path, err := config.Path()
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve config path")
+	}
+
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), path)
+
+	return errors.Wrap(err, "failed to write output")
+}
+
+func runConfigList(cmd *cobra.Command, _ []string) error {
+	f, err := openConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range f.List() {
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s = %s\n", entry[0], entry[1]); err != nil {
+			return errors.Wrap(err, "failed to write output")
+		}
+	}
+
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	f, err := openConfig()
+	if err != nil {
+		return err
+	}
+
+	value, ok := f.Get(args[0])
+	if !ok {
+		return errors.Newf("key %s is not set", args[0])
+	}
+
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), config.FormatValue(value))
+
+	return errors.Wrap(err, "failed to write output")
+}
+
+func runConfigSet(_ *cobra.Command, args []string) error {
+	f, err := openConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := f.Set(args[0], args[1]); err != nil {
+		return errors.Wrapf(err, "failed to set %s", args[0])
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Set %s", args[0]))
+
+	return nil
+}
+
+func openConfig() (*config.File, error) {
+	path, err := config.Path()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve config path")
+	}
+
+	f, err := config.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open configuration")
+	}
+
+	return f, nil
+}
+
+func init() {
+	configCmd.AddCommand(configPathCmd, configListCmd, configGetCmd, configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/runner"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// defaultMaxFixes is how many corrected commands --exec asks for by default.
+const defaultMaxFixes = 3
+
+// maxFixes resolves the fix loop limit: --max-fixes, then the profile.
+func maxFixes(cmd *cobra.Command, profile config.Profile) int {
+	if !cmd.Flags().Changed("max-fixes") && profile.MaxFixes != nil {
+		return *profile.MaxFixes
+	}
+
+	return maxFixesFlag
+}
+
+// executeCommand runs command in the user's shell. While it fails, the
+// provider is asked for a corrected command, up to maxFixes times. Every run
+// and every fix request is confirmed unless --yes is set.
+func executeCommand(settings *querySettings, s suggestion, opts prompt.Options, maxFixes int) error {
+	var failures []prompt.Failure
+
+	for {
+		ui.PrintHeader(fmt.Sprintf("Attempt %d", len(failures)+1))
+		ui.PrintCommand(s.command)
+
+		if ok, err := confirmStep("Run this command?", s.refinable); !ok {
+			return err
+		}
+
+		result, err := runner.Run(context.Background(), s.command, os.Stdout, os.Stderr)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to run the command: %v", err))
+
+			return errors.Wrap(err, "failed to run command")
+		}
+
+		recordHistory(settings, s, &result.ExitCode)
+
+		if result.ExitCode == 0 {
+			ui.PrintSuccess("Command succeeded")
+
+			return nil
+		}
+
+		ui.PrintError(fmt.Sprintf("Command exited with status %d", result.ExitCode))
+
+		failed := errors.Newf("command exited with status %d", result.ExitCode)
+		failures = append(failures, prompt.Failure{Command: s.command, ExitCode: result.ExitCode, Stderr: result.StderrTail})
+
+		if len(failures) > maxFixes {
+			if maxFixes > 0 {
+				ui.PrintWarning(fmt.Sprintf("Giving up after %d fix attempts", maxFixes))
+			}
+
+			return failed
+		}
+
+		if ok, err := confirmStep(fmt.Sprintf("Ask %s for a fix?", settings.provider.Name), false); !ok {
+			return errors.CombineErrors(failed, err)
+		}
+
+		s, err = suggestFix(settings, s.query, failures, opts)
+		if err != nil {
+			return err
+		}
+
+		if ok, err := checkRisk(s.command, settings.maxRisk, false); !ok {
+			return err
+		}
+	}
+}
+
+// suggestFix asks the provider for a command that avoids the failures.
+func suggestFix(settings *querySettings, query string, failures []prompt.Failure, opts prompt.Options) (suggestion, error) {
+	p, err := prompt.Fix(query, failures, opts)
+	if err != nil {
+		return suggestion{}, err
+	}
+
+	start := time.Now()
+
+	response, err := settings.query(settings.request(p), nil)
+	if err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to query %s: %v", settings.provider.Name, err))
+
+		return suggestion{}, errors.Wrap(err, "failed to query provider")
+	}
+
+	s := suggestion{query: query, command: prompt.SanitizeCommand(response), latency: time.Since(start)}
+	if s.command == "" {
+		return suggestion{}, errors.Newf("%s returned an empty command", settings.provider.Name)
+	}
+
+	return s, nil
+}
+
+// confirmStep asks before an --exec step. --yes answers for the user; without
+// a terminal to ask on, the step is refused. When refinable is set, the user
+// may also refine the command, answering errRefine.
+func confirmStep(question string, refinable bool) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+
+	if !ui.Interactive() {
+		return false, errors.New("--exec needs a terminal to confirm each step; pass --yes to run without asking")
+	}
+
+	answer, err := confirm(question, refinable)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to confirm")
+	}
+
+	if answer == ui.AnswerRefine {
+		return false, errRefine
+	}
+
+	return answer == ui.AnswerYes, nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// explainMaxTokens is the minimum response budget for explanations, which
+// are much longer than a bare command.
+const explainMaxTokens = 2000
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <command>",
+	Short: "Explain what a shell command does, part by part",
+	Long: `Break a shell command into its pipeline stages, flags and arguments and
+describe each one.
+
+Quote the command so your shell does not interpret it:
+  howto explain "find . -name '*.go' -mtime -7 | xargs wc -l"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExplain,
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	command := prompt.SanitizeCommand(strings.Join(args, " "))
+
+	settings, err := resolveSettings()
+	if err != nil {
+		return err
+	}
+
+	defer recordUsage(settings)
+
+	if err := explainCommand(settings, command); err != nil {
+		ui.PrintError(fmt.Sprintf("Failed to explain command with %s: %v", settings.provider.Name, err))
+
+		return errors.Wrap(err, "failed to explain command")
+	}
+
+	return nil
+}
+
+// explainCommand asks the provider for a structured breakdown of command and
+// prints it.
+func explainCommand(settings *querySettings, command string) error {
+	explanation, response, err := explain(settings, command)
+	if err != nil && response == "" {
+		return err
+	}
+
+	ui.PrintHeader(command)
+
+	if err != nil {
+		ui.PrintWarning("Could not parse a structured explanation; showing the raw answer")
+		ui.Print(ui.OutputInfo, strings.TrimSpace(response))
+
+		return nil
+	}
+
+	printExplanation(explanation)
+
+	return nil
+}
+
+// explain asks the provider for a structured breakdown of command. When the
+// answer cannot be parsed, the error comes with the raw response.
+func explain(settings *querySettings, command string) (*prompt.Explanation, string, error) {
+	req := settings.request(prompt.Explain(command))
+	req.MaxTokens = max(req.MaxTokens, explainMaxTokens)
+	req.Format = provider.FormatJSON
+
+	preview := ui.NewPreview()
+	response, err := settings.query(req, preview.Write)
+	preview.Clear()
+
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to query provider")
+	}
+
+	explanation, err := prompt.ParseExplanation(response)
+	if err != nil {
+		return nil, response, err
+	}
+
+	return explanation, response, nil
+}
+
+// printExplanation renders an explanation as an aligned breakdown.
+func printExplanation(explanation *prompt.Explanation) {
+	if explanation.Summary != "" {
+		ui.Print(ui.OutputInfo, explanation.Summary)
+	}
+
+	var items []ui.Annotation
+
+	for _, stage := range explanation.Stages {
+		items = append(items, ui.Annotation{Text: stage.Command, Description: stage.Description})
+
+		for _, part := range stage.Parts {
+			items = append(items, ui.Annotation{Text: part.Token, Description: part.Description, Level: 1})
+		}
+	}
+
+	ui.PrintAnnotations(items)
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
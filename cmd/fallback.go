@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// target is a provider to query, with its credential and model.
+type target struct {
+	provider *provider.Provider
+	apiKey   string
+	model    string
+}
+
+// query sends req, streaming the answer to onToken when it is not nil.
+func (s *querySettings) query(req provider.Request, onToken provider.TokenFunc) (string, error) {
+	response, _, err := s.withFallback(req, func(ctx context.Context, t target, req provider.Request) (string, bool, error) {
+		if onToken != nil {
+			response, err := t.provider.QueryStream(ctx, t.apiKey, req, onToken)
+
+			return response, false, err
+		}
+
+		response, err := t.provider.Query(ctx, t.apiKey, req)
+
+		return response, false, err
+	})
+
+	return response, err
+}
+
+// cachedQuery is query through the response cache; fingerprint identifies the
+// environment in the prompt. cached reports whether the cache answered.
+func (s *querySettings) cachedQuery(req provider.Request, fingerprint string, onToken provider.TokenFunc) (string, bool, error) {
+	if s.cache == nil {
+		response, err := s.query(req, onToken)
+
+		return response, false, err
+	}
+
+	return s.withFallback(req, func(ctx context.Context, t target, req provider.Request) (string, bool, error) {
+		return s.cache.Query(ctx, t.provider, t.apiKey, req, fingerprint, onToken)
+	})
+}
+
+// withFallback asks the provider, then the fallback providers in order while
+// the failures are ones another provider might not have (see
+// provider.ShouldFallback). Each gets the full timeout. The provider that
+// answers is used for the rest of the command.
+func (s *querySettings) withFallback(
+	req provider.Request, ask func(context.Context, target, provider.Request) (string, bool, error),
+) (string, bool, error) {
+	if err := s.checkBudget(); err != nil {
+		return "", false, err
+	}
+
+	current := target{provider: s.provider, apiKey: s.apiKey, model: s.model}
+	tried := []string{current.provider.Name}
+
+	response, cached, err := s.attempt(ask, current, req)
+	if err == nil || !provider.ShouldFallback(err) {
+		return response, cached, err
+	}
+
+	for _, next := range s.fallbackTargets() {
+		ui.PrintWarning(fmt.Sprintf("%s failed: %v; trying %s", current.provider.Name, err, next.provider.Name))
+
+		current = next
+		tried = append(tried, next.provider.Name)
+
+		response, cached, err = s.attempt(ask, current, req)
+		if err == nil {
+			ui.PrintInfo(fmt.Sprintf("Answered by %s (model: %s)", current.provider.Name, current.model))
+
+			s.use(current)
+
+			return response, cached, nil
+		}
+
+		if !provider.ShouldFallback(err) {
+			return "", false, err
+		}
+	}
+
+	if len(tried) > 1 {
+		return "", false, errors.Wrapf(err, "every provider failed (%s)", strings.Join(tried, ", "))
+	}
+
+	return "", false, err
+}
+
+// attempt sends req to t through ask, with its own timeout.
+func (s *querySettings) attempt(
+	ask func(context.Context, target, provider.Request) (string, bool, error), t target, req provider.Request,
+) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	req.Model = t.model
+	req.OnUsage = s.onUsage(t)
+
+	return ask(ctx, t, req)
+}
+
+// fallbackTargets resolves the fallback providers, skipping the current one
+// and those that are not configured. They are only looked up once the
+// provider failed, since checking credentials can take a moment (e.g. probing
+// the Ollama daemon).
+func (s *querySettings) fallbackTargets() []target {
+	if s.fallback == nil {
+		return s.otherTargets(registeredNames(), false)
+	}
+
+	return s.otherTargets(s.fallback, true)
+}
+
+// registeredNames returns the names of every registered provider.
+func registeredNames() []string {
+	var names []string
+	for _, p := range provider.Registered() {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// otherTargets resolves the named providers, skipping the current one and
+// those that are not configured; explicit warns about the latter, for names
+// the user asked for.
+func (s *querySettings) otherTargets(names []string, explicit bool) []target {
+	var targets []target
+
+	for _, name := range names {
+		if s.provider.Matches(name) {
+			continue
+		}
+
+		t, err := lookupTarget(name, s.profile, false)
+		if err != nil {
+			if explicit {
+				ui.PrintWarning(fmt.Sprintf("Skipping provider %s: %v", name, err))
+			}
+
+			continue
+		}
+
+		if t.model != "" {
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
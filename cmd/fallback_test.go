@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+// fakeBackend answers as a test sets it up, logging each query in fakeCalls.
+type fakeBackend struct {
+	answer       string
+	err          error
+	unconfigured bool
+	// delay is how long the answer takes; canceled, if set, is closed when
+	// the query is canceled first.
+	delay    time.Duration
+	canceled chan struct{}
+}
+
+// fakeCalls lists the providers queried since the last resetFakes, in order.
+var (
+	fakeMu    sync.Mutex
+	fakeCalls []string
+)
+
+func (f *fakeBackend) Query(ctx context.Context, p *provider.Provider, _ string, _ provider.Request) (string, error) {
+	// Losers of a race may still run when the next test resets f
+	fakeMu.Lock()
+	fakeCalls = append(fakeCalls, p.Name)
+	answer := *f
+	fakeMu.Unlock()
+
+	select {
+	case <-time.After(answer.delay):
+		return answer.answer, answer.err
+	case <-ctx.Done():
+		if answer.canceled != nil {
+			close(answer.canceled)
+		}
+
+		return "", ctx.Err()
+	}
+}
+
+func (f *fakeBackend) Capabilities() provider.Capabilities {
+	return provider.Capabilities{}
+}
+
+func (f *fakeBackend) CheckAuth(p *provider.Provider) (string, error) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+
+	if f.unconfigured {
+		return "", errors.New(p.Name + " not configured")
+	}
+
+	return "fake-key", nil
+}
+
+func (f *fakeBackend) ListModels(context.Context, *provider.Provider, string) ([]string, error) {
+	return nil, nil
+}
+
+// fakes are registered once, ahead of every built-in provider, as Fake A,
+// Fake B and Fake C.
+var fakes = func() []*provider.Provider {
+	var registered []*provider.Provider
+
+	for i, name := range []string{"Fake A", "Fake B", "Fake C"} {
+		p := &provider.Provider{
+			Name:         name,
+			DefaultModel: "fake-1",
+			Priority:     i - 10,
+			Backend:      &fakeBackend{},
+			Retry:        provider.RetryPolicy{MaxAttempts: 1},
+		}
+		provider.Register(p)
+		registered = append(registered, p)
+	}
+
+	return registered
+}()
+
+// resetFakes sets up the fakes to answer as backends say, in order, and
+// unconfigures every built-in provider so that only the fakes answer.
+func resetFakes(t *testing.T, backends ...fakeBackend) {
+	t.Helper()
+
+	for _, p := range provider.Registered() {
+		if p.EnvVar != "" {
+			t.Setenv(p.EnvVar, "")
+		}
+	}
+
+	home := t.TempDir()
+	for _, name := range []string{
+		"GH_TOKEN", "GITHUB_TOKEN", "AZURE_OPENAI_ENDPOINT", "PATH",
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE",
+	} {
+		t.Setenv(name, "")
+	}
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CACHE_HOME", home)
+	t.Setenv("XDG_STATE_HOME", home)
+	t.Setenv(provider.OllamaHostEnvVar, "127.0.0.1:1")
+
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+
+	fakeCalls = nil
+
+	for i, p := range fakes {
+		backend := p.Backend.(*fakeBackend)
+		*backend = fakeBackend{}
+
+		if i < len(backends) {
+			*backend = backends[i]
+		}
+	}
+}
+
+// queried returns the providers queried since the last resetFakes.
+func queried() []string {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+
+	return slices.Clone(fakeCalls)
+}
+
+// fakeSettings queries Fake A with fallback.
+func fakeSettings(fallback []string) *querySettings {
+	return &querySettings{
+		provider: fakes[0],
+		apiKey:   "fake-key",
+		model:    "fake-1",
+		fallback: fallback,
+		timeout:  time.Second,
+		meter:    &meter{},
+	}
+}
+
+var (
+	errQuota   = &provider.Error{Kind: provider.KindQuota, Message: "out of credits"}
+	errInvalid = &provider.Error{Kind: provider.KindInvalidRequest, Message: "bad model"}
+)
+
+func TestWithFallback(t *testing.T) {
+	t.Run("registered providers by priority", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{err: errQuota}, fakeBackend{answer: "ls -la"})
+		s := fakeSettings(nil)
+
+		response, err := s.query(provider.Request{Prompt: "list files"}, nil)
+		if err != nil {
+			t.Fatalf("query() error = %v", err)
+		}
+
+		if response != "ls -la" {
+			t.Errorf("query() = %q, want %q", response, "ls -la")
+		}
+
+		if want := []string{"Fake A", "Fake B", "Fake C"}; !slices.Equal(queried(), want) {
+			t.Errorf("queried %v, want %v", queried(), want)
+		}
+
+		if s.provider.Name != "Fake C" {
+			t.Errorf("provider = %q, want the one that answered", s.provider.Name)
+		}
+	})
+
+	t.Run("profile order over priority", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{answer: "ls -la"}, fakeBackend{err: errQuota})
+		s := fakeSettings([]string{"Fake C", "Fake B"})
+
+		if _, err := s.query(provider.Request{Prompt: "list files"}, nil); err != nil {
+			t.Fatalf("query() error = %v", err)
+		}
+
+		if want := []string{"Fake A", "Fake C", "Fake B"}; !slices.Equal(queried(), want) {
+			t.Errorf("queried %v, want %v", queried(), want)
+		}
+	})
+
+	t.Run("unconfigured providers are skipped", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{unconfigured: true}, fakeBackend{answer: "ls -la"})
+		s := fakeSettings([]string{"Fake B", "Fake C"})
+
+		if _, err := s.query(provider.Request{Prompt: "list files"}, nil); err != nil {
+			t.Fatalf("query() error = %v", err)
+		}
+
+		if want := []string{"Fake A", "Fake C"}; !slices.Equal(queried(), want) {
+			t.Errorf("queried %v, want %v", queried(), want)
+		}
+	})
+
+	t.Run("no fallback on invalid requests", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errInvalid}, fakeBackend{answer: "ls -la"})
+		s := fakeSettings(nil)
+
+		if _, err := s.query(provider.Request{Prompt: "list files"}, nil); err == nil {
+			t.Fatal("query() error = nil, want the invalid request")
+		}
+
+		if want := []string{"Fake A"}; !slices.Equal(queried(), want) {
+			t.Errorf("queried %v, want %v", queried(), want)
+		}
+	})
+
+	t.Run("every provider failed", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{err: errQuota}, fakeBackend{err: errQuota})
+		s := fakeSettings([]string{"Fake B", "Fake C"})
+
+		_, err := s.query(provider.Request{Prompt: "list files"}, nil)
+		if err == nil {
+			t.Fatal("query() error = nil, want every provider to fail")
+		}
+
+		if want := "every provider failed (Fake A, Fake B, Fake C): quota exhausted: out of credits"; err.Error() != want {
+			t.Errorf("query() error = %q, want %q", err, want)
+		}
+	})
+}
+
+func TestFallbackSuppression(t *testing.T) {
+	tests := []struct {
+		name       string
+		fallback   string
+		provider   string
+		noFallback bool
+		want       []string
+	}{
+		{name: "every provider by default", want: []string{"Fake A", "Fake B", "Fake C"}},
+		{name: "the profile's fallback", fallback: `fallback = ["Fake C"]`, want: []string{"Fake A", "Fake C"}},
+		{name: "--provider alone suppresses it", provider: "Fake A", want: []string{"Fake A"}},
+		{name: "--provider keeps the profile's fallback", provider: "Fake A", fallback: `fallback = ["Fake C"]`, want: []string{"Fake A", "Fake C"}},
+		{name: "--no-fallback suppresses it", fallback: `fallback = ["Fake C"]`, noFallback: true, want: []string{"Fake A"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{err: errQuota}, fakeBackend{err: errQuota})
+			useConfig(t, "[profiles.default]\nprovider = \"Fake A\"\n"+tt.fallback+"\n")
+
+			providerFlag, noFallbackFlag = tt.provider, tt.noFallback
+			t.Cleanup(func() { providerFlag, noFallbackFlag = "", false })
+
+			s, err := resolveSettings()
+			if err != nil {
+				t.Fatalf("resolveSettings() error = %v", err)
+			}
+
+			if _, err := s.query(provider.Request{Prompt: "list files"}, nil); err == nil {
+				t.Fatal("query() error = nil, want every provider to fail")
+			} else if len(tt.want) == 1 && strings.Contains(err.Error(), "every provider failed") {
+				t.Errorf("query() error = %q, want the provider's own error", err)
+			}
+
+			if !slices.Equal(queried(), tt.want) {
+				t.Errorf("queried %v, want %v", queried(), tt.want)
+			}
+		})
+	}
+}
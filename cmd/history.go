@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/history"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// historyCellWidth caps the query and command columns of the history table.
+const historyCellWidth = 60
+
+var (
+	historyGrepFlag  string
+	historySinceFlag string
+	historyJSONFlag  bool
+	historyLimitFlag int
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past queries and the commands suggested for them",
+	Long: `List past queries and the commands suggested for them, newest last.
+
+Every suggestion is recorded with its provider, model and latency, and whether
+--exec ran it, in $XDG_STATE_HOME/howto/history.jsonl
+(~/.local/state/howto/history.jsonl by default). Set history = false in the
+config file to stop recording queries and commands; their tokens and cost are
+still recorded for howto usage and the budget.`,
+	Args: cobra.NoArgs,
+	RunE: runHistory,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print a history entry in full",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryShow,
+}
+
+var historyRerunCmd = &cobra.Command{
+	Use:   "rerun <id>",
+	Short: "Insert a recorded command again without querying the provider",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryRerun,
+}
+
+// openHistory opens the history file.
+func openHistory() (*history.Store, error) {
+	path, err := history.Path()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve history path")
+	}
+
+	return history.Open(path), nil
+}
+
+// recordHistory records a suggestion with the usage metered since the last
+// one; exitCode is set once --exec ran it. With the history turned off only
+// the usage is recorded. Failures are only reported, since the suggestion
+// itself is still usable.
+func recordHistory(settings *querySettings, s suggestion, exitCode *int) {
+	if loadedConfig != nil && loadedConfig.History != nil && !*loadedConfig.History {
+		recordUsage(settings)
+
+		return
+	}
+
+	entry := &history.Entry{
+		Query:     s.query,
+		Command:   s.command,
+		LatencyMS: s.latency.Milliseconds(),
+		Executed:  exitCode != nil,
+	}
+
+	if exitCode != nil {
+		entry.ExitCode = *exitCode
+	}
+
+	appendEntry(settings, entry)
+}
+
+// recordUsage records the usage metered since the last record, if any,
+// without a query or command, so that howto usage and the budget count
+// queries that suggested nothing to record.
+func recordUsage(settings *querySettings) {
+	if usage, _ := settings.meter.total(); usage != nil {
+		appendEntry(settings, &history.Entry{})
+	}
+}
+
+// appendEntry adds the provider, model and metered usage to e and appends it
+// to the history.
+func appendEntry(settings *querySettings, e *history.Entry) {
+	usage, usd := settings.meter.total()
+	settings.meter.reset()
+
+	e.Provider, e.Model, e.CostUSD = settings.provider.Name, settings.model, usd
+	if usage != nil {
+		e.InputTokens, e.OutputTokens = usage.InputTokens, usage.OutputTokens
+	}
+
+	store, err := openHistory()
+	if err == nil {
+		err = store.Append(e)
+	}
+
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not record the query in the history: %v", err))
+	}
+}
+
+func runHistory(cmd *cobra.Command, _ []string) error {
+	filter, err := historyFilter()
+	if err != nil {
+		return err
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+
+	all, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]history.Entry, 0, len(all))
+
+	for _, e := range all {
+		if filter.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	if historyLimitFlag > 0 && len(entries) > historyLimitFlag {
+		entries = entries[len(entries)-historyLimitFlag:]
+	}
+
+	if historyJSONFlag {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+
+		return errors.Wrap(encoder.Encode(entries), "failed to write output")
+	}
+
+	if len(entries) == 0 {
+		ui.PrintInfo("No history entries")
+
+		return nil
+	}
+
+	rows := make([][]string, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, []string{
+			strconv.Itoa(e.ID),
+			e.Time.Local().Format("2006-01-02 15:04"),
+			e.Provider,
+			ui.Truncate(e.Query, historyCellWidth),
+			ui.Truncate(e.Command, historyCellWidth),
+		})
+	}
+
+	ui.PrintTable([]string{"ID", "When", "Provider", "Query", "Command"}, rows)
+
+	return nil
+}
+
+// historyFilter builds the filter from --grep and --since.
+func historyFilter() (history.Filter, error) {
+	var filter history.Filter
+
+	if historyGrepFlag != "" {
+		pattern, err := regexp.Compile("(?i)" + historyGrepFlag)
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid --grep pattern")
+		}
+
+		filter.Pattern = pattern
+	}
+
+	if historySinceFlag != "" {
+		since, err := history.ParseSince(historySinceFlag, time.Now())
+		if err != nil {
+			return filter, errors.Wrap(err, "invalid --since")
+		}
+
+		filter.Since = since
+	}
+
+	return filter, nil
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	e, err := historyEntry(args[0])
+	if err != nil {
+		return err
+	}
+
+	status := "no"
+	if e.Executed {
+		status = fmt.Sprintf("yes (exit status %d)", e.ExitCode)
+	}
+
+	for _, field := range [][2]string{
+		{"ID", strconv.Itoa(e.ID)},
+		{"When", e.Time.Local().Format(time.DateTime)},
+		{"Query", e.Query},
+		{"Provider", e.Provider},
+		{"Model", e.Model},
+		{"Latency", (time.Duration(e.LatencyMS) * time.Millisecond).String()},
+		{"Tokens", fmt.Sprintf("%d in, %d out (%s)", e.InputTokens, e.OutputTokens, dollars(e.CostUSD))},
+		{"Executed", status},
+		{"Command", e.Command},
+	} {
+		if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%-9s %s\n", field[0]+":", field[1]); err != nil {
+			return errors.Wrap(err, "failed to write output")
+		}
+	}
+
+	return nil
+}
+
+func runHistoryRerun(_ *cobra.Command, args []string) error {
+	e, err := historyEntry(args[0])
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, _, err := cfg.SelectProfile(profileFlag)
+	if err != nil {
+		return errors.Wrap(err, "failed to select profile")
+	}
+
+	maxRisk, err := resolveMaxRisk(profile)
+	if err != nil {
+		return err
+	}
+
+	// The command may have been harmless where it was first suggested
+	if ok, err := checkRisk(e.Command, maxRisk, false); !ok {
+		return err
+	}
+
+	return insertCommand(e.Command)
+}
+
+// historyEntry looks up the entry named by an ID argument.
+func historyEntry(arg string) (history.Entry, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return history.Entry{}, errors.Newf("invalid history ID %q", arg)
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		return history.Entry{}, err
+	}
+
+	e, err := store.Get(id)
+	if err == nil && e.UsageOnly() {
+		return history.Entry{}, errors.Wrapf(history.ErrNotFound, "entry %d records only usage", id)
+	}
+
+	return e, err
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyGrepFlag, "grep", "", "Only list entries whose query or command matches this regular expression")
+	historyCmd.Flags().StringVar(&historySinceFlag, "since", "", "Only list entries newer than a duration (36h, 7d) or a date (2006-01-02)")
+	historyCmd.Flags().BoolVar(&historyJSONFlag, "json", false, "Print the entries as JSON")
+	historyCmd.Flags().IntVar(&historyLimitFlag, "limit", 20, "List at most this many of the newest entries (0 for all)")
+
+	historyCmd.AddCommand(historyShowCmd, historyRerunCmd)
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/cost"
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+func TestRecordUsage(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	off := false
+	loadedConfig = &config.Config{History: &off}
+
+	t.Cleanup(func() { loadedConfig = nil })
+
+	settings := &querySettings{
+		provider: provider.OpenAI,
+		model:    "gpt-4o",
+		prices:   cost.Table{"gpt-4o": {Input: 1000, Output: 1000}},
+		meter:    &meter{},
+		budget:   config.Budget{Monthly: 1, Action: budgetBlock},
+	}
+
+	// A suggestion with the history turned off, then an explanation or a chat
+	// that ended without one
+	settings.meter.add(settings.prices, settings.model, provider.Usage{InputTokens: 400, OutputTokens: 100})
+	recordHistory(settings, suggestion{query: "list files", command: "ls"}, nil)
+	settings.meter.add(settings.prices, settings.model, provider.Usage{InputTokens: 400, OutputTokens: 100})
+	recordUsage(settings)
+
+	// Nothing is left to record
+	recordUsage(settings)
+
+	entries, err := historyEntries()
+	if err != nil {
+		t.Fatalf("historyEntries() error = %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d entries, want 2", len(entries))
+	}
+
+	for _, e := range entries {
+		if !e.UsageOnly() {
+			t.Errorf("entry %d records query %q and command %q with the history off", e.ID, e.Query, e.Command)
+		}
+
+		if e.InputTokens != 400 || e.OutputTokens != 100 || e.CostUSD != 0.5 {
+			t.Errorf("entry %d = %d in, %d out, $%v; want 400 in, 100 out, $0.5", e.ID, e.InputTokens, e.OutputTokens, e.CostUSD)
+		}
+	}
+
+	if err := settings.checkBudget(); err == nil {
+		t.Error("checkBudget() = nil, want the block budget to refuse the query")
+	}
+}
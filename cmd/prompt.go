@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/prompt"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Inspect the prompt sent to providers",
+	Long: `Inspect the prompt sent to providers.
+
+The query is sent as the user message and the rules for answering it as the
+system prompt, a Go text/template. Put an override in prompt.tmpl and few-shot
+examples in examples.toml next to the config file, or point the [prompt]
+table of the config file at other files:
+
+  [prompt]
+  template = "prompt.tmpl"
+  examples = "examples.toml"
+
+The template is executed with .Query, .OS, .Shell, .Context (the environment facts),
+.Instructions (from the profile) and .Examples (each with .Query and
+.Command). The prompts of --count, of --exec fixes and of howto chat are the
+named templates "candidates" (with .Count), "fix" and "chat", sharing
+"environment" and "rules"; an override may redefine any of them and inherits
+the rest. Examples are written as:
+
+  [[example]]
+  query = "find go files"
+  command = "fd -e go"`,
+}
+
+var promptShowCmd = &cobra.Command{
+	Use:   "show <query>",
+	Short: "Print the system prompt and user message rendered for a query",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runPromptShow,
+}
+
+var promptTemplateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Print the built-in prompt template, to start an override from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		_, err := fmt.Fprint(cmd.OutOrStdout(), prompt.DefaultTemplateText())
+
+		return errors.Wrap(err, "failed to write output")
+	},
+}
+
+// runPromptShow renders the prompt as a query would, without needing a
+// configured provider.
+func runPromptShow(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile, _, err := cfg.SelectProfile(profileFlag)
+	if err != nil {
+		return errors.Wrap(err, "failed to select profile")
+	}
+
+	collector, err := contextCollector(profile)
+	if err != nil {
+		return err
+	}
+
+	promptTemplate, examples, err := loadPrompt(cfg)
+	if err != nil {
+		return err
+	}
+
+	settings := &querySettings{profile: profile, collector: collector, promptTemplate: promptTemplate, examples: examples}
+
+	p, err := prompt.Build(strings.Join(args, " "), settings.promptOptions(context.Background()))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "System:\n%s\nUser:\n%s\n", p.System, p.User)
+
+	return errors.Wrap(err, "failed to write output")
+}
+
+func init() {
+	promptShowCmd.Flags().StringSliceVar(&contextFlag, "context", nil, "Environment context to include: shell, distro, package-manager, coreutils, tools, directory, git - default: all")
+	promptShowCmd.Flags().BoolVar(&noContextFlag, "no-context", false, "Do not describe the environment in the prompt")
+
+	promptCmd.AddCommand(promptShowCmd, promptTemplateCmd)
+	rootCmd.AddCommand(promptCmd)
+}
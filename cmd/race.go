@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// answer is a target's response to a fanned-out request.
+type answer struct {
+	target   target
+	response string
+	err      error
+}
+
+// raceTargets returns the current provider followed by every other configured
+// one.
+func (s *querySettings) raceTargets() []target {
+	current := target{provider: s.provider, apiKey: s.apiKey, model: s.model}
+
+	return append([]target{current}, s.otherTargets(registeredNames(), false)...)
+}
+
+// fanOut sends req to every target at once under ctx. The answers arrive on
+// the returned channel in the order they finish; it holds one per target, so
+// nobody blocks once ctx is canceled.
+func (s *querySettings) fanOut(ctx context.Context, targets []target, req provider.Request) <-chan answer {
+	answers := make(chan answer, len(targets))
+
+	for _, t := range targets {
+		go func() {
+			// Retry notices from providers that may never be used are noise
+			p := *t.provider
+			p.Retry.OnRetry = nil
+
+			req := req
+			req.Model = t.model
+			req.OnUsage = s.onUsage(t)
+
+			response, err := p.Query(ctx, t.apiKey, req)
+			answers <- answer{target: t, response: response, err: err}
+		}()
+	}
+
+	return answers
+}
+
+// race sends req to every configured provider concurrently and returns the
+// first response valid accepts, canceling the others. The provider that won
+// is used for the rest of the command.
+func (s *querySettings) race(req provider.Request, valid func(string) bool) (string, error) {
+	if err := s.checkBudget(); err != nil {
+		return "", err
+	}
+
+	targets := s.raceTargets()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	answers := s.fanOut(ctx, targets, req)
+
+	var failures []string
+
+	for range targets {
+		a := <-answers
+		if a.err == nil && valid(a.response) {
+			cancel()
+			s.use(a.target)
+
+			ui.PrintInfo(fmt.Sprintf("Fastest answer from %s (model: %s)", a.target.provider.Name, a.target.model))
+
+			return a.response, nil
+		}
+
+		reason := "no usable command"
+		if a.err != nil {
+			reason = a.err.Error()
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %s", a.target.provider.Name, reason))
+	}
+
+	return "", errors.Newf("no provider answered (%s)", strings.Join(failures, "; "))
+}
+
+// consensus sends req to every configured provider concurrently, waits for
+// all of them and returns the command most agree on, compared after
+// prompt.NormalizeCommand. Without a clear winner, every answer is shown and
+// the one from the earliest provider in the tie is used.
+func (s *querySettings) consensus(req provider.Request) (string, error) {
+	if err := s.checkBudget(); err != nil {
+		return "", err
+	}
+
+	targets := s.raceTargets()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	answers := s.fanOut(ctx, targets, req)
+
+	// Keep the answers in provider order so ties are broken consistently
+	byProvider := make(map[*provider.Provider]answer, len(targets))
+	for range targets {
+		a := <-answers
+		byProvider[a.target.provider] = a
+	}
+
+	var (
+		valid    []answer
+		groups   [][]answer
+		index    = map[string]int{}
+		failures []string
+	)
+
+	for _, t := range targets {
+		a := byProvider[t.provider]
+
+		command := prompt.NormalizeCommand(a.response)
+		if a.err != nil || command == "" {
+			if a.err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", t.provider.Name, a.err))
+			}
+
+			continue
+		}
+
+		valid = append(valid, a)
+
+		i, ok := index[command]
+		if !ok {
+			i = len(groups)
+			index[command] = i
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], a)
+	}
+
+	if len(valid) == 0 {
+		return "", errors.Newf("no provider answered (%s)", strings.Join(failures, "; "))
+	}
+
+	for _, failure := range failures {
+		ui.PrintWarning("Ignoring " + failure)
+	}
+
+	best, tied := 0, false
+
+	for i, group := range groups[1:] {
+		switch {
+		case len(group) > len(groups[best]):
+			best, tied = i+1, false
+		case len(group) == len(groups[best]):
+			tied = true
+		}
+	}
+
+	winner := groups[best][0]
+
+	if tied {
+		ui.PrintWarning(fmt.Sprintf("The providers disagree; using the answer from %s", winner.target.provider.Name))
+		printDisagreement(valid)
+	} else {
+		ui.PrintInfo(fmt.Sprintf("%d of %d providers agree", len(groups[best]), len(valid)))
+	}
+
+	s.use(winner.target)
+
+	return winner.response, nil
+}
+
+// printDisagreement lists every provider's command.
+func printDisagreement(answers []answer) {
+	items := make([]ui.Annotation, 0, len(answers))
+	for _, a := range answers {
+		items = append(items, ui.Annotation{Text: a.target.provider.Name, Description: prompt.SanitizeCommand(a.response)})
+	}
+
+	ui.PrintAnnotations(items)
+}
+
+// use makes t the provider for the rest of the command.
+func (s *querySettings) use(t target) {
+	s.provider, s.apiKey, s.model = t.provider, t.apiKey, t.model
+}
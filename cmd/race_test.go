@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+// usable accepts any answer with a command in it, as howto --race does.
+func usable(response string) bool {
+	return strings.TrimSpace(response) != ""
+}
+
+func TestRace(t *testing.T) {
+	t.Run("the fastest usable answer wins", func(t *testing.T) {
+		canceled := make(chan struct{})
+		resetFakes(t,
+			fakeBackend{answer: "ls -l", delay: 200 * time.Millisecond},
+			fakeBackend{answer: "ls -la", delay: 10 * time.Millisecond},
+			fakeBackend{answer: "ls", delay: 10 * time.Second, canceled: canceled},
+		)
+		s := fakeSettings(nil)
+
+		response, err := s.race(provider.Request{Prompt: "list files"}, usable)
+		if err != nil {
+			t.Fatalf("race() error = %v", err)
+		}
+
+		if response != "ls -la" {
+			t.Errorf("race() = %q, want %q", response, "ls -la")
+		}
+
+		if s.provider.Name != "Fake B" {
+			t.Errorf("provider = %q, want the winner", s.provider.Name)
+		}
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Error("the slowest provider was not canceled")
+		}
+	})
+
+	t.Run("unusable answers and failures lose", func(t *testing.T) {
+		resetFakes(t,
+			fakeBackend{answer: "ls -l", delay: 50 * time.Millisecond},
+			fakeBackend{answer: "  "},
+			fakeBackend{err: errQuota},
+		)
+		s := fakeSettings(nil)
+
+		response, err := s.race(provider.Request{Prompt: "list files"}, usable)
+		if err != nil {
+			t.Fatalf("race() error = %v", err)
+		}
+
+		if response != "ls -l" || s.provider.Name != "Fake A" {
+			t.Errorf("race() = %q from %s, want %q from Fake A", response, s.provider.Name, "ls -l")
+		}
+	})
+
+	t.Run("no provider answered", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{answer: ""}, fakeBackend{err: errInvalid})
+
+		_, err := fakeSettings(nil).race(provider.Request{Prompt: "list files"}, usable)
+		if err == nil {
+			t.Fatal("race() error = nil, want no provider to answer")
+		}
+
+		for _, want := range []string{"Fake A: quota exhausted", "Fake B: no usable command", "Fake C: invalid request"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("race() error = %q, want it to mention %q", err, want)
+			}
+		}
+	})
+}
+
+func TestConsensus(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []fakeBackend
+		want     string
+		winner   string
+	}{
+		{
+			name: "the majority wins",
+			backends: []fakeBackend{
+				{answer: "ls -l"},
+				{answer: "ls -la"},
+				{answer: "ls  -la;"},
+			},
+			want:   "ls -la",
+			winner: "Fake B",
+		},
+		{
+			name: "failures do not vote",
+			backends: []fakeBackend{
+				{err: errQuota},
+				{answer: "du -sh ."},
+				{answer: "du -sh ."},
+			},
+			want:   "du -sh .",
+			winner: "Fake B",
+		},
+		{
+			name: "ties go to the earliest provider, not the fastest",
+			backends: []fakeBackend{
+				{answer: "ls -l", delay: 100 * time.Millisecond},
+				{answer: "ls -la"},
+				{err: errQuota},
+			},
+			want:   "ls -l",
+			winner: "Fake A",
+		},
+		{
+			name: "quoting that changes nothing agrees",
+			backends: []fakeBackend{
+				{answer: "ls -l"},
+				{answer: `find . -name "*.go"`},
+				{answer: "find . -name '*.go'"},
+			},
+			want:   `find . -name "*.go"`,
+			winner: "Fake B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFakes(t, tt.backends...)
+			s := fakeSettings(nil)
+
+			response, err := s.consensus(provider.Request{Prompt: "list files"})
+			if err != nil {
+				t.Fatalf("consensus() error = %v", err)
+			}
+
+			if response != tt.want {
+				t.Errorf("consensus() = %q, want %q", response, tt.want)
+			}
+
+			if s.provider.Name != tt.winner {
+				t.Errorf("provider = %q, want %q", s.provider.Name, tt.winner)
+			}
+		})
+	}
+
+	t.Run("no provider answered", func(t *testing.T) {
+		resetFakes(t, fakeBackend{err: errQuota}, fakeBackend{answer: ""}, fakeBackend{err: errQuota})
+
+		if _, err := fakeSettings(nil).consensus(provider.Request{Prompt: "list files"}); err == nil {
+			t.Fatal("consensus() error = nil, want no provider to answer")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/risk"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// defaultMaxRisk lets commands that delete a few named files through, and
+// asks before anything that deletes in bulk or worse.
+const defaultMaxRisk = "low"
+
+// riskAction is what checkRisk does with a risky command.
+type riskAction int
+
+const (
+	// riskAllow uses the command.
+	riskAllow riskAction = iota
+	// riskConfirm asks the user first.
+	riskConfirm
+	// riskRefuse rejects the command.
+	riskRefuse
+)
+
+// riskPolicy decides what happens to a command of the given level. Commands
+// within maxRisk are used, as is anything --dry-run only prints. --yes never
+// raises the ceiling: with nobody asked, a command above it is refused.
+// Otherwise --exec confirms the command before running it anyway, and any
+// other use needs confirmation.
+func riskPolicy(level, maxRisk risk.Level, dryRun, yes, exec bool) riskAction {
+	switch {
+	case level <= maxRisk || dryRun:
+		return riskAllow
+	case yes:
+		return riskRefuse
+	case exec:
+		return riskAllow
+	default:
+		return riskConfirm
+	}
+}
+
+// checkRisk warns about destructive patterns in command and applies
+// riskPolicy; checkRisk reports whether the command may be used. When
+// refinable is set, the confirmation also offers to refine the command,
+// answering errRefine.
+func checkRisk(command string, maxRisk risk.Level, refinable bool) (bool, error) {
+	findings := risk.Analyze(command)
+	if len(findings) == 0 {
+		return true, nil
+	}
+
+	level := risk.Highest(findings)
+
+	reasons := make([]string, 0, len(findings))
+	for _, f := range findings {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", f.Level, f.Reason))
+	}
+
+	ui.PrintDanger(fmt.Sprintf("This command is %s risk", level), reasons)
+
+	switch riskPolicy(level, maxRisk, dryRunFlag, yesFlag, execFlag) {
+	case riskAllow:
+		return true, nil
+	case riskRefuse:
+		ui.PrintError(fmt.Sprintf("Refusing a %s risk command: --yes only accepts up to %s risk; raise --max-risk to allow it", level, maxRisk))
+
+		return false, errors.Newf("command exceeds the %s risk policy", maxRisk)
+	case riskConfirm:
+	}
+
+	if !ui.Interactive() {
+		ui.PrintError(fmt.Sprintf("Refusing a %s risk command without confirmation; raise --max-risk to allow it", level))
+
+		return false, errors.Newf("command exceeds the %s risk policy", maxRisk)
+	}
+
+	answer, err := confirm("Use this command anyway?", refinable)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to confirm command")
+	}
+
+	switch answer {
+	case ui.AnswerYes:
+		return true, nil
+	case ui.AnswerRefine:
+		return false, errRefine
+	default:
+		ui.PrintInfo("Canceled")
+
+		return false, nil
+	}
+}
+
+// confirm asks question, offering to refine the command when refinable is
+// set.
+func confirm(question string, refinable bool) (ui.Answer, error) {
+	if refinable {
+		return ui.ConfirmOrRefine(question)
+	}
+
+	ok, err := ui.Confirm(question)
+	if ok {
+		return ui.AnswerYes, err
+	}
+
+	return ui.AnswerNo, err
+}
+
+// riskLabel prefixes detail with the risk level of command, if it has one.
+func riskLabel(command, detail string) string {
+	level := risk.Highest(risk.Analyze(command))
+	if level == risk.None {
+		return detail
+	}
+
+	return fmt.Sprintf("[%s risk] %s", level, detail)
+}
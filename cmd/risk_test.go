@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/techquestsdev/howto/internal/risk"
+)
+
+func TestRiskPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		level  risk.Level
+		dryRun bool
+		yes    bool
+		exec   bool
+		want   riskAction
+	}{
+		{name: "within the policy", level: risk.Low, want: riskAllow},
+		{name: "within the policy with yes", level: risk.Low, yes: true, want: riskAllow},
+		{name: "above the policy", level: risk.High, want: riskConfirm},
+		{name: "dry run only prints", level: risk.Critical, dryRun: true, want: riskAllow},
+		{name: "exec confirms the run", level: risk.Critical, exec: true, want: riskAllow},
+		{name: "yes does not raise the ceiling", level: risk.Critical, yes: true, want: riskRefuse},
+		{name: "exec with yes runs nothing above the policy", level: risk.Critical, exec: true, yes: true, want: riskRefuse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := riskPolicy(tt.level, risk.Low, tt.dryRun, tt.yes, tt.exec); got != tt.want {
+				t.Errorf("riskPolicy(%s) = %d, want %d", tt.level, got, tt.want)
+			}
+		})
+	}
+}
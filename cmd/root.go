@@ -3,11 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/cache"
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/output"
 	"github.com/techquestsdev/howto/internal/prompt"
 	"github.com/techquestsdev/howto/internal/provider"
 	"github.com/techquestsdev/howto/internal/terminal"
@@ -15,10 +19,28 @@ import (
 )
 
 var (
-	modelFlag    string
-	providerFlag string
-	dryRunFlag   bool
-	timeoutFlag  time.Duration
+	modelFlag      string
+	providerFlag   string
+	profileFlag    string
+	dryRunFlag     bool
+	quietFlag      bool
+	explainFlag    bool
+	countFlag      int
+	yesFlag        bool
+	maxRiskFlag    string
+	noCacheFlag    bool
+	refreshFlag    bool
+	raceFlag       bool
+	consensusFlag  bool
+	outputFlag     string
+	noFallbackFlag bool
+	contextFlag    []string
+	noContextFlag  bool
+	execFlag       bool
+	maxFixesFlag   int
+	refineFlag     bool
+	modelsFlag     bool
+	timeoutFlag    time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -31,17 +53,27 @@ Supports multiple AI providers:
   - Anthropic (Claude)
   - Google Gemini
   - DeepSeek
+  - Ollama (local)
   - GitHub Copilot
+  - Any OpenAI-compatible endpoint (LM Studio, vLLM, OpenRouter, Groq, ...)
 
 Environment Variables:
   OPENAI_API_KEY      OpenAI API key
   ANTHROPIC_API_KEY   Anthropic API key
   GEMINI_API_KEY      Google Gemini API key
   DEEPSEEK_API_KEY    DeepSeek API key
-  GITHUB_TOKEN        GitHub token (for Copilot)
+  GH_TOKEN            GitHub token for Copilot - default: gh auth token
+  OLLAMA_HOST         Ollama daemon address - default: localhost:11434
+  HOWTO_<NAME>_BASE_URL, HOWTO_<NAME>_API_KEY, HOWTO_<NAME>_MODEL
+                      Declare a custom OpenAI-compatible provider <name>
   HOWTO_MODEL         Override default model for the provider
   HOWTO_PROVIDER      Force a specific provider
-  HOWTO_TIMEOUT       Request timeout (e.g., "30s", "1m") - default: 30s`,
+  HOWTO_PROFILE       Select a profile from the config file
+  HOWTO_CONFIG        Path to the config file
+  HOWTO_TIMEOUT       Request timeout (e.g., "30s", "1m") - default: 30s
+
+Settings are resolved as: flag > environment variable > profile > default.
+See "howto config --help" for the config file.`,
 	Version: "1.0.0",
 	Args:    cobra.MinimumNArgs(1),
 	RunE:    runHowto,
@@ -56,75 +88,303 @@ var listProvidersCmd = &cobra.Command{
 func runHowto(cmd *cobra.Command, args []string) error {
 	query := strings.Join(args, " ")
 
-	// Detect or use specified provider
-	p, apiKey, err := getProvider()
+	format, err := output.ParseFormat(outputFlag)
 	if err != nil {
 		return err
 	}
 
-	// Override model if specified
-	model := modelFlag
-	if model == "" {
-		model = p.DefaultModel
+	out := output.NewWriter(os.Stdout, format)
+
+	// Quiet mode reserves stdout for the command itself, and machine-readable
+	// output for the result
+	if quietFlag || out.Machine() {
+		ui.SetOutput(os.Stderr)
+	}
+
+	settings, err := resolveSettings()
+	if err != nil {
+		return err
+	}
+
+	// Record what failed or refined queries used, too
+	defer recordUsage(settings)
+
+	if countFlag < 1 || countFlag > maxCandidates {
+		return errors.Newf("--count must be between 1 and %d", maxCandidates)
+	}
+
+	if consensusFlag && countFlag > 1 {
+		return errors.New("--consensus cannot be combined with --count")
+	}
+
+	if out.Machine() && countFlag > 1 {
+		return errors.Newf("--output %s cannot be combined with --count", format)
 	}
 
+	p := settings.provider
+
 	// Generate the prompt
-	promptText := prompt.Generate(query)
+	promptOpts := settings.promptOptions(context.Background())
 
-	// Create context with timeout
-	timeout := provider.GetTimeout(timeoutFlag)
+	var promptText prompt.Prompt
+	if countFlag > 1 {
+		promptText, err = prompt.Candidates(query, countFlag, promptOpts)
+	} else {
+		promptText, err = prompt.Build(query, promptOpts)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	if err != nil {
+		return err
+	}
+
+	// Query the AI, streaming a live preview when the result is printed anyway
+	req := settings.request(promptText)
+	if countFlag > 1 {
+		req.MaxTokens = max(req.MaxTokens, countFlag*candidateMaxTokens)
+	}
+
+	var (
+		response string
+		cached   bool
+	)
+
+	start := time.Now()
+	fingerprint := cache.Fingerprint(promptOpts.Environment)
+
+	switch {
+	case raceFlag:
+		response, err = settings.race(req, func(response string) bool {
+			if countFlag > 1 {
+				_, err := prompt.ParseCandidates(response)
+
+				return err == nil
+			}
+
+			return prompt.SanitizeCommand(response) != ""
+		})
+	case consensusFlag:
+		response, err = settings.consensus(req)
+	case format == output.NDJSON:
+		response, cached, err = settings.cachedQuery(req, fingerprint, out.Token)
+	case dryRunFlag || explainFlag || out.Machine():
+		preview := ui.NewPreview()
+		response, cached, err = settings.cachedQuery(req, fingerprint, preview.Write)
+		preview.Clear()
+	default:
+		response, cached, err = settings.cachedQuery(req, fingerprint, nil)
+	}
 
-	// Query the AI
-	response, err := p.Query(ctx, apiKey, model, promptText)
 	if err != nil {
-		ui.PrintError(fmt.Sprintf("Failed to query %s: %v", p.Name, err))
+		name := p.Name
+		if raceFlag || consensusFlag {
+			name = "the providers"
+		}
+
+		ui.PrintError(fmt.Sprintf("Failed to query %s: %v", name, err))
+		out.Error(err)
 
 		return errors.Wrap(err, "failed to query provider")
 	}
 
-	// Sanitize the command
+	latency := time.Since(start)
+
+	// Sanitize the command, letting the user pick when several were requested
 	command := prompt.SanitizeCommand(response)
+	refine := refineFlag
+
+	if countFlag > 1 {
+		candidates, err := prompt.ParseCandidates(response)
+		if err != nil {
+			ui.PrintError(fmt.Sprintf("Failed to parse alternatives from %s: %v", p.Name, err))
+
+			return errors.Wrap(err, "failed to parse candidates")
+		}
+
+		command, err = pickCommand(candidates)
+		if errors.Is(err, errRefine) {
+			refine = true
+		} else if err != nil || command == "" {
+			return err
+		}
+	}
+
+	var explanation *prompt.Explanation
+
+	switch {
+	case explainFlag && out.Machine():
+		if explanation, _, err = explain(settings, command); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Could not explain the command: %v", err))
+		}
+	case explainFlag:
+		if err := explainCommand(settings, command); err != nil {
+			ui.PrintWarning(fmt.Sprintf("Could not explain the command: %v", err))
+		}
+	}
+
+	s := suggestion{query: query, command: command, latency: latency, cached: cached, refinable: true}
+
+	if out.Machine() {
+		usage, usd := settings.meter.total()
+		recordHistory(settings, s, nil)
+
+		return out.Result(output.Result{
+			Query:       s.query,
+			Command:     s.command,
+			Provider:    settings.provider.Name,
+			Model:       settings.model,
+			Explanation: explanation,
+			Risk:        output.AnalyzeRisk(s.command),
+			LatencyMS:   s.latency.Milliseconds(),
+			Usage:       usage,
+			CostUSD:     usd,
+			Cached:      s.cached,
+		})
+	}
+
+	if refine {
+		return refineSuggestion(cmd, settings, promptOpts, s)
+	}
+
+	// The confirmation steps of finish may hand the command over to a chat
+	if err := finish(cmd, settings, s, promptOpts); !errors.Is(err, errRefine) {
+		return err
+	}
+
+	return refineSuggestion(cmd, settings, promptOpts, s)
+}
+
+// suggestion is a command suggested for a query.
+type suggestion struct {
+	query   string
+	command string
+	// latency is the time spent waiting for the provider.
+	latency time.Duration
+	// cached is set when the response cache answered the query.
+	cached bool
+	// refinable is set while the command has not run yet, so confirming it
+	// may offer to refine it in a chat instead.
+	refinable bool
+}
+
+// finish hands the chosen command over: it is run with --exec, printed with
+// --quiet or --dry-run and inserted into the terminal otherwise.
+func finish(cmd *cobra.Command, settings *querySettings, s suggestion, opts prompt.Options) error {
+	// Warn about destructive commands before they reach the command line
+	if ok, err := checkRisk(s.command, settings.maxRisk, s.refinable); !ok {
+		return err
+	}
+
+	if execFlag {
+		return executeCommand(settings, s, opts, maxFixes(cmd, settings.profile))
+	}
+
+	recordHistory(settings, s, nil)
+
+	if quietFlag {
+		fmt.Println(s.command)
+
+		return nil
+	}
 
 	if dryRunFlag {
-		ui.PrintInfo(fmt.Sprintf("Provider: %s (model: %s)", p.Name, model))
-		fmt.Println(command)
+		source := fmt.Sprintf("Provider: %s (model: %s)", settings.provider.Name, settings.model)
+		if s.cached {
+			source += ", from cache"
+		}
+
+		ui.PrintInfo(source)
+		fmt.Println(s.command)
 
 		return nil
 	}
 
-	// Insert the command into the terminal
-	terminal.InsertInput(command)
+	return insertCommand(s.command)
+}
+
+// insertCommand inserts command into the terminal, printing it instead when
+// the terminal does not allow that.
+func insertCommand(command string) error {
+	if err := terminal.InsertInput(command); err != nil {
+		if errors.Is(err, terminal.ErrInjectionUnsupported) {
+			ui.PrintWarning("Your terminal does not allow inserting input; the command was printed instead")
+			ui.PrintInfo("For in-place editing, set up shell integration: howto shell-init --help")
+
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to insert command")
+	}
 
 	return nil
 }
 
-func getProvider() (*provider.Provider, string, error) {
-	if providerFlag != "" {
-		p, apiKey, err := provider.GetByName(providerFlag)
+// getProvider returns the named provider, or detects one when name is empty,
+// configured by the profile.
+func getProvider(name string, profile config.Profile) (target, error) {
+	if name != "" {
+		t, err := lookupTarget(name, profile, true)
 		if err != nil {
-			ui.PrintError(fmt.Sprintf("Provider '%s' not found or not configured", providerFlag))
+			ui.PrintError(fmt.Sprintf("Provider '%s' not found or not configured", name))
 
-			return nil, "", errors.Wrap(err, "failed to get provider")
+			return target{}, errors.Wrap(err, "failed to get provider")
 		}
 
-		return p, apiKey, nil
+		return t, nil
 	}
 
-	p, apiKey := provider.Detect()
-	if p == nil {
-		ui.PrintError("No API key found")
-		ui.PrintInfo("Set one of: OPENAI_API_KEY, ANTHROPIC_API_KEY, GEMINI_API_KEY, DEEPSEEK_API_KEY, GITHUB_TOKEN")
+	for _, p := range provider.Registered() {
+		if t, err := checkTarget(p, profile, true); err == nil {
+			return t, nil
+		}
+	}
+
+	ui.PrintError("No API key found")
+	ui.PrintInfo("Set one of: " + strings.Join(providerEnvVars(), ", "))
 
-		return nil, "", errors.New("no provider configured")
+	return target{}, errors.New("no provider configured")
+}
+
+// lookupTarget is checkTarget for the provider with the given name or alias.
+func lookupTarget(name string, profile config.Profile, primary bool) (target, error) {
+	p, err := provider.Lookup(name)
+	if err != nil {
+		return target{}, err
 	}
 
-	return p, apiKey, nil
+	return checkTarget(p, profile, primary)
+}
+
+// checkTarget applies the profile to p before checking its credentials, so
+// that an endpoint from the profile is the one checked.
+func checkTarget(p *provider.Provider, profile config.Profile, primary bool) (target, error) {
+	p, model := configureProvider(p, profile, primary)
+
+	apiKey, err := provider.Check(p)
+	if err != nil {
+		return target{}, err
+	}
+
+	return target{provider: p, apiKey: apiKey, model: model}, nil
+}
+
+// providerEnvVars returns the credential variables of all registered providers.
+func providerEnvVars() []string {
+	var envVars []string
+
+	for _, p := range provider.Registered() {
+		if p.EnvVar != "" {
+			envVars = append(envVars, p.EnvVar)
+		}
+	}
+
+	return envVars
 }
 
 func runListProviders(cmd *cobra.Command, args []string) error {
+	if _, err := loadConfig(); err != nil {
+		return err
+	}
+
 	providers := provider.ListAll()
 
 	ui.PrintHeader("Available Providers")
@@ -143,9 +403,44 @@ func runListProviders(cmd *cobra.Command, args []string) error {
 
 	ui.PrintTable(headers, rows)
 
+	// Local providers list their models by default since it costs nothing
+	for _, info := range providers {
+		if info.Configured && (info.Local || modelsFlag) {
+			printModels(info.Name)
+		}
+	}
+
 	return nil
 }
 
+// printModels lists the models offered by the named provider.
+func printModels(name string) {
+	p, apiKey, err := provider.GetByName(name)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), provider.GetTimeout(timeoutFlag))
+	defer cancel()
+
+	models, err := p.ListModels(ctx, apiKey)
+
+	ui.PrintHeader(p.Name + " Models")
+
+	switch {
+	case errors.Is(err, provider.ErrModelListingUnsupported):
+		ui.PrintInfo("Model listing not supported")
+	case err != nil:
+		ui.PrintError(fmt.Sprintf("Failed to list models: %v", err))
+	case len(models) == 0:
+		ui.PrintInfo("No models available")
+	default:
+		for _, model := range models {
+			fmt.Println(model)
+		}
+	}
+}
+
 // Execute is the main entry point for the CLI.
 func Execute() error {
 	if err := rootCmd.Execute(); err != nil {
@@ -156,10 +451,40 @@ func Execute() error {
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&modelFlag, "model", "m", "", "Override the default model")
-	rootCmd.Flags().StringVarP(&providerFlag, "provider", "p", "", "Force a specific provider")
+	rootCmd.PersistentFlags().StringVarP(&modelFlag, "model", "m", "", "Override the default model")
+	rootCmd.PersistentFlags().StringVarP(&providerFlag, "provider", "p", "", "Force a specific provider")
+	rootCmd.PersistentFlags().StringVarP(&profileFlag, "profile", "P", "", "Use a named profile from the config file")
+	rootCmd.PersistentFlags().DurationVarP(&timeoutFlag, "timeout", "t", 0, "Request timeout (e.g., 30s, 1m) - default: 30s")
 	rootCmd.Flags().BoolVarP(&dryRunFlag, "dry-run", "d", false, "Print command without inserting into terminal")
-	rootCmd.Flags().DurationVarP(&timeoutFlag, "timeout", "t", 0, "Request timeout (e.g., 30s, 1m) - default: 30s")
+	rootCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Print only the command to stdout (for scripts and shell integration)")
+	rootCmd.Flags().BoolVarP(&explainFlag, "explain", "e", false, "Explain the suggested command part by part")
+	rootCmd.Flags().IntVarP(&countFlag, "count", "n", 1, "Suggest N alternative commands and pick one")
+	rootCmd.PersistentFlags().BoolVarP(&yesFlag, "yes", "y", false, "Answer confirmations with yes; commands above --max-risk are still refused")
+	rootCmd.Flags().BoolVarP(&execFlag, "exec", "x", false, "Run the command after confirmation and offer fixes when it fails")
+	rootCmd.Flags().BoolVarP(&refineFlag, "refine", "r", false, "Refine the suggestion over several turns before using it (see howto chat)")
+	rootCmd.Flags().IntVar(&maxFixesFlag, "max-fixes", defaultMaxFixes, "How many corrected commands --exec asks for when one fails")
+	rootCmd.Flags().StringSliceVar(&contextFlag, "context", nil, "Environment context to include: shell, distro, package-manager, coreutils, tools, directory, git - default: all")
+	rootCmd.Flags().BoolVar(&noContextFlag, "no-context", false, "Do not describe the environment in the prompt")
+	rootCmd.PersistentFlags().StringVar(&maxRiskFlag, "max-risk", "", "Highest risk used without confirmation: none, low, medium, high, critical - default: low")
+
+	rootCmd.Flags().BoolVar(&noCacheFlag, "no-cache", false, "Neither read nor write the response cache")
+	rootCmd.Flags().BoolVar(&refreshFlag, "refresh", false, "Query the provider even when the answer is cached, and update the cache")
+
+	rootCmd.Flags().StringVarP(&outputFlag, "output", "o", string(output.Text), "Output format: text, json, or ndjson to also stream the response")
+	rootCmd.Flags().BoolVar(&raceFlag, "race", false, "Ask every configured provider at once and use the first answer")
+	rootCmd.Flags().BoolVar(&consensusFlag, "consensus", false, "Ask every configured provider and use the command most of them agree on")
+
+	rootCmd.PersistentFlags().BoolVar(&noFallbackFlag, "no-fallback", false, "Do not try other providers when the provider fails")
+
+	rootCmd.MarkFlagsMutuallyExclusive("exec", "dry-run")
+	rootCmd.MarkFlagsMutuallyExclusive("exec", "quiet")
+	rootCmd.MarkFlagsMutuallyExclusive("no-cache", "refresh")
+	rootCmd.MarkFlagsMutuallyExclusive("race", "consensus")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "exec")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "quiet")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "refine")
+
+	listProvidersCmd.Flags().BoolVar(&modelsFlag, "models", false, "Also list the models of every configured provider")
 
 	rootCmd.AddCommand(listProvidersCmd)
 }
@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/cache"
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/cost"
+	"github.com/techquestsdev/howto/internal/envinfo"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/risk"
+	"github.com/techquestsdev/howto/internal/runner"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// querySettings is the effective configuration for a query, resolved with the
+// precedence flag > environment > profile > defaults.
+type querySettings struct {
+	provider *provider.Provider
+	apiKey   string
+	model    string
+	// fallback names the providers tried when the provider fails; nil means
+	// every configured one.
+	fallback  []string
+	timeout   time.Duration
+	maxRisk   risk.Level
+	collector *envinfo.Collector
+	// cache answers repeated queries; nil when disabled.
+	cache   *cache.Cache
+	profile config.Profile
+	// prices converts reported usage to cost, which meter adds up until the
+	// suggestion is recorded.
+	prices cost.Table
+	meter  *meter
+	budget config.Budget
+	// budgetChecked is set once the budget was checked for this process.
+	budgetChecked bool
+	// promptTemplate renders the command prompt, with examples as few-shot
+	// examples.
+	promptTemplate *template.Template
+	examples       []prompt.Example
+}
+
+// loadedConfig caches the configuration file once loadConfig has run.
+var loadedConfig *config.Config
+
+// loadConfig reads the configuration file and registers the custom providers
+// it and the environment declare. It runs at most once per process.
+func loadConfig() (*config.Config, error) {
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load configuration")
+	}
+
+	if err := registerCustomProviders(cfg); err != nil {
+		return nil, err
+	}
+
+	configureAzure(cfg.Azure)
+	configureGemini(cfg.Gemini)
+
+	loadedConfig = cfg
+
+	return cfg, nil
+}
+
+// registerCustomProviders adds the OpenAI-compatible endpoints declared in
+// the config file and HOWTO_<NAME>_BASE_URL variables to the registry.
+func registerCustomProviders(cfg *config.Config) error {
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	endpoints := make([]provider.CustomEndpoint, 0, len(names))
+
+	for _, name := range names {
+		c := cfg.Providers[name]
+		endpoints = append(endpoints, provider.CustomEndpoint{
+			Name:         name,
+			BaseURL:      c.BaseURL,
+			Auth:         c.Auth,
+			AuthHeader:   c.AuthHeader,
+			KeyEnv:       c.KeyEnv,
+			DefaultModel: c.DefaultModel,
+			Headers:      c.Headers,
+			Priority:     c.Priority,
+		})
+	}
+
+	for _, warning := range provider.BuiltinOverridesFromEnv(endpoints) {
+		ui.PrintWarning(warning)
+	}
+
+	for _, endpoint := range provider.CustomEndpointsFromEnv(endpoints) {
+		if err := provider.RegisterCustom(endpoint); err != nil {
+			return errors.Wrap(err, "invalid custom provider")
+		}
+	}
+
+	return nil
+}
+
+// configureAzure applies the [azure] table of the config file to the Azure
+// OpenAI provider.
+func configureAzure(c config.Azure) {
+	endpoint := c.Endpoint
+	if endpoint == "" && c.Resource != "" {
+		endpoint = provider.AzureResourceURL(c.Resource)
+	}
+
+	shell, flag := runner.Shell()
+
+	provider.ConfigureAzure(provider.AzureSettings{
+		Endpoint:     endpoint,
+		APIVersion:   c.APIVersion,
+		TokenCommand: c.TokenCommand,
+		Shell:        shell,
+		ShellFlag:    flag,
+		Deployments:  c.Deployments,
+	})
+}
+
+// configureGemini applies the [gemini] section of the config file, sending
+// the safety settings in a stable order.
+func configureGemini(c config.Gemini) {
+	categories := make([]string, 0, len(c.SafetySettings))
+	for category := range c.SafetySettings {
+		categories = append(categories, category)
+	}
+
+	sort.Strings(categories)
+
+	settings := make([]provider.GeminiSafetySetting, 0, len(categories))
+
+	for _, category := range categories {
+		settings = append(settings, provider.GeminiSafetySetting{Category: category, Threshold: c.SafetySettings[category]})
+	}
+
+	provider.ConfigureGemini(provider.GeminiSettings{SafetySettings: settings})
+}
+
+// resolveSettings loads the configuration file and combines the selected
+// profile with flags and environment variables.
+func resolveSettings() (*querySettings, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, _, err := cfg.SelectProfile(profileFlag)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select profile")
+	}
+
+	primary, err := getProvider(cmp.Or(providerFlag, os.Getenv(config.ProviderEnvVar), profile.Provider), profile)
+	if err != nil {
+		return nil, err
+	}
+
+	model := cmp.Or(modelFlag, os.Getenv(config.ModelEnvVar), primary.model)
+	if model == "" {
+		return nil, errors.Newf("provider %s has no default model; pass --model", primary.provider.Name)
+	}
+
+	// An explicitly chosen provider is only replaced when the profile asks for it
+	fallback := profile.Fallback
+	if noFallbackFlag || (providerFlag != "" && fallback == nil) {
+		fallback = []string{}
+	}
+
+	maxRisk, err := resolveMaxRisk(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	collector, err := contextCollector(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	promptTemplate, examples, err := loadPrompt(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if action := cfg.Budget.Action; action != "" && action != budgetWarn && action != budgetBlock {
+		return nil, errors.Newf("invalid budget action %q (want %s or %s)", action, budgetWarn, budgetBlock)
+	}
+
+	return &querySettings{
+		provider:  primary.provider,
+		apiKey:    primary.apiKey,
+		model:     model,
+		fallback:  fallback,
+		timeout:   provider.ResolveTimeout(timeoutFlag, profile.Timeout),
+		maxRisk:   maxRisk,
+		collector: collector,
+		cache:     responseCache(cfg.Cache),
+		profile:   profile,
+		prices:    priceTable(cfg.Prices),
+		meter:     &meter{},
+		budget:    cfg.Budget,
+
+		promptTemplate: promptTemplate,
+		examples:       examples,
+	}, nil
+}
+
+// loadPrompt loads the prompt template and few-shot examples named by the
+// config file, falling back to the built-in template and no examples.
+func loadPrompt(cfg *config.Config) (*template.Template, []prompt.Example, error) {
+	templatePath, examplesPath, err := cfg.PromptFiles()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to locate prompt files")
+	}
+
+	tmpl, err := prompt.LoadTemplate(templatePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load %s", templatePath)
+	}
+
+	examples, err := prompt.LoadExamples(examplesPath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load examples")
+	}
+
+	return tmpl, examples, nil
+}
+
+// responseCache configures the response cache from --no-cache, --refresh and
+// the config file. It returns nil when the cache is disabled or there is no
+// directory to keep it in.
+func responseCache(c config.Cache) *cache.Cache {
+	if noCacheFlag || (c.Enabled != nil && !*c.Enabled) {
+		return nil
+	}
+
+	dir, err := cache.Dir()
+	if err != nil {
+		return nil
+	}
+
+	return &cache.Cache{Dir: dir, TTL: c.TTL, MaxBytes: c.MaxBytes, Refresh: refreshFlag}
+}
+
+// configureProvider applies the profile to p: its endpoint, if the profile
+// is for p (or for any provider when primary is set), and a notice on
+// retries. It returns the configured provider and its default model, which
+// is the profile's under the same condition.
+func configureProvider(p *provider.Provider, profile config.Profile, primary bool) (*provider.Provider, string) {
+	configured := *p
+	model := p.DefaultModel
+
+	// A profile's model and endpoint only make sense for the profile's provider
+	if (primary && profile.Provider == "") || (profile.Provider != "" && p.Matches(profile.Provider)) {
+		model = cmp.Or(profile.Model, model)
+		configured.Endpoint = cmp.Or(profile.Endpoint, p.Endpoint)
+	}
+
+	// Say why an answer takes longer than usual
+	configured.Retry.OnRetry = func(err error, delay time.Duration) {
+		ui.PrintWarning(fmt.Sprintf("%s: %v; retrying in %s", p.Name, err, delay.Round(100*time.Millisecond)))
+	}
+
+	return &configured, model
+}
+
+// resolveMaxRisk resolves the risk policy: --max-risk, then the profile.
+func resolveMaxRisk(profile config.Profile) (risk.Level, error) {
+	level, err := risk.ParseLevel(cmp.Or(maxRiskFlag, profile.MaxRisk, defaultMaxRisk))
+	if err != nil {
+		return risk.None, errors.Wrap(err, "invalid risk policy")
+	}
+
+	return level, nil
+}
+
+// contextCollector configures the environment context from --no-context,
+// --context and the profile, in that order.
+func contextCollector(profile config.Profile) (*envinfo.Collector, error) {
+	if noContextFlag {
+		return &envinfo.Collector{Sources: []envinfo.Source{}}, nil
+	}
+
+	names := profile.Context
+	if len(contextFlag) > 0 {
+		names = contextFlag
+	}
+
+	collector := &envinfo.Collector{MaxBytes: profile.ContextMaxBytes}
+
+	if names != nil {
+		sources, err := envinfo.ParseSources(names)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid context sources")
+		}
+
+		collector.Sources = sources
+	}
+
+	return collector, nil
+}
+
+// promptOptions builds the prompt options, collecting the environment context.
+func (s *querySettings) promptOptions(ctx context.Context) prompt.Options {
+	opts := prompt.Options{Instructions: s.profile.Instructions, Examples: s.examples, Template: s.promptTemplate}
+
+	for _, fact := range s.collector.Collect(ctx) {
+		opts.Environment = append(opts.Environment, fact.String())
+
+		if fact.Name == envinfo.ShellFact {
+			opts.Shell = fact.Value
+		}
+	}
+
+	return opts
+}
+
+// request builds a provider request for p.
+func (s *querySettings) request(p prompt.Prompt) provider.Request {
+	return provider.Request{
+		Model:       s.model,
+		System:      p.System,
+		Prompt:      p.User,
+		MaxTokens:   s.profile.MaxTokens,
+		Temperature: s.profile.Temperature,
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+// useConfig points the commands at a config file with the given content and
+// resets what previous tests loaded or selected.
+func useConfig(t *testing.T, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	t.Setenv(config.PathEnvVar, path)
+	t.Setenv(config.ProfileEnvVar, "")
+	t.Setenv(config.ProviderEnvVar, "")
+	t.Setenv(config.ModelEnvVar, "")
+
+	loadedConfig = nil
+	providerFlag, profileFlag, modelFlag = "", "", ""
+	noFallbackFlag = false
+
+	t.Cleanup(func() { loadedConfig = nil })
+}
+
+func TestResolveSettingsProfileEndpoint(t *testing.T) {
+	t.Run("Ollama is checked at the profile endpoint", func(t *testing.T) {
+		daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/version" {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"version":"0.5.0"}`))
+		}))
+		defer daemon.Close()
+
+		t.Setenv("OLLAMA_HOST", "127.0.0.1:1")
+		useConfig(t, "[profiles.default]\nprovider = \"Ollama\"\nendpoint = \""+daemon.URL+"\"\n")
+
+		s, err := resolveSettings()
+		if err != nil {
+			t.Fatalf("resolveSettings() error = %v", err)
+		}
+
+		if s.provider.Name != "Ollama" {
+			t.Errorf("provider = %q, want %q", s.provider.Name, "Ollama")
+		}
+
+		if s.provider.Endpoint != daemon.URL {
+			t.Errorf("endpoint = %q, want %q", s.provider.Endpoint, daemon.URL)
+		}
+	})
+
+	t.Run("Azure takes its resource URL from the profile", func(t *testing.T) {
+		var path string
+
+		resource := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ls -la"}}]}`))
+		}))
+		defer resource.Close()
+
+		t.Setenv(provider.AzureEndpointEnvVar, "")
+		t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+		useConfig(t, "[profiles.default]\nprovider = \"Azure\"\nmodel = \"gpt-4o\"\nendpoint = \""+resource.URL+"\"\n")
+
+		s, err := resolveSettings()
+		if err != nil {
+			t.Fatalf("resolveSettings() error = %v", err)
+		}
+
+		response, err := s.provider.Query(context.Background(), s.apiKey, provider.Request{Prompt: "list files", Model: s.model})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if response != "ls -la" {
+			t.Errorf("Query() = %q, want %q", response, "ls -la")
+		}
+
+		if want := "/openai/deployments/gpt-4o/chat/completions"; path != want {
+			t.Errorf("path = %q, want %q", path, want)
+		}
+	})
+
+	t.Run("Bedrock is queried at the profile endpoint", func(t *testing.T) {
+		var path string
+
+		vpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			_, _ = w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"ls -la"}]}},"stopReason":"end_turn"}`))
+		}))
+		defer vpc.Close()
+
+		t.Setenv("AWS_REGION", "eu-west-1")
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		useConfig(t, "[profiles.default]\nprovider = \"Bedrock\"\nmodel = \"mistral-large\"\nendpoint = \""+vpc.URL+"\"\n")
+
+		s, err := resolveSettings()
+		if err != nil {
+			t.Fatalf("resolveSettings() error = %v", err)
+		}
+
+		response, err := s.provider.Query(context.Background(), s.apiKey, provider.Request{Prompt: "list files", Model: s.model})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if response != "ls -la" {
+			t.Errorf("Query() = %q, want %q", response, "ls -la")
+		}
+
+		if want := "/model/mistral.mistral-large-2407-v1:0/converse"; path != want {
+			t.Errorf("path = %q, want %q", path, want)
+		}
+	})
+}
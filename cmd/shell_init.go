@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/shell"
+)
+
+var shellInitCmd = &cobra.Command{
+	Use:   "shell-init <zsh|bash|fish>",
+	Short: "Print shell integration that inserts suggestions into the command line",
+	Long: `Print a line-editor widget that sends the current command line to howto and
+replaces it with the suggested command. Unlike the default terminal insertion,
+this does not rely on TIOCSTI, which recent Linux kernels and OpenBSD disable.
+
+Bind the widget to Alt-g by adding one of these to your shell's rc file:
+  zsh:  eval "$(howto shell-init zsh)"
+  bash: eval "$(howto shell-init bash)"
+  fish: howto shell-init fish | source`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: shell.Supported,
+	RunE:      runShellInit,
+}
+
+func runShellInit(cmd *cobra.Command, args []string) error {
+	script, err := shell.Script(args[0])
+	if err != nil {
+		return errors.Wrap(err, "failed to load shell integration")
+	}
+
+	_, err = fmt.Fprint(cmd.OutOrStdout(), script)
+
+	return errors.Wrap(err, "failed to write shell integration")
+}
+
+func init() {
+	rootCmd.AddCommand(shellInitCmd)
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/techquestsdev/howto/internal/config"
+	"github.com/techquestsdev/howto/internal/cost"
+	"github.com/techquestsdev/howto/internal/history"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/ui"
+)
+
+// Budget actions.
+const (
+	budgetWarn  = "warn"
+	budgetBlock = "block"
+)
+
+var (
+	usageSinceFlag string
+	usageJSONFlag  bool
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show tokens and cost by day, provider and model",
+	Long: `Show the tokens providers reported and what they cost, by day, provider
+and model, from the history file.
+
+Costs use the built-in list prices, which the [prices] table of the config
+file overrides or extends per model:
+
+  [prices."gpt-4o"]
+  input = 2.50   # US dollars per million input tokens
+  output = 10.00 # and per million output tokens
+
+Models without a price, such as local ones, count as free. A monthly budget
+warns, or with action = "block" refuses to query, once this month's spend
+reaches it:
+
+  [budget]
+  monthly = 20.00
+  action = "warn"`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+// meter adds up the usage providers report until it is recorded. Raced
+// queries report concurrently.
+type meter struct {
+	mu       sync.Mutex
+	usage    provider.Usage
+	usd      float64
+	reported bool
+}
+
+// add records u, priced for model.
+func (m *meter) add(prices cost.Table, model string, u provider.Usage) {
+	price, _ := prices.Lookup(model)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage.InputTokens += u.InputTokens
+	m.usage.OutputTokens += u.OutputTokens
+	m.usd += price.Cost(u.InputTokens, u.OutputTokens)
+	m.reported = true
+}
+
+// total returns the usage so far and its cost; the usage is nil when no
+// provider reported any.
+func (m *meter) total() (*provider.Usage, float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.reported {
+		return nil, 0
+	}
+
+	usage := m.usage
+
+	return &usage, m.usd
+}
+
+// reset starts counting afresh, once a suggestion was recorded.
+func (m *meter) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage, m.usd, m.reported = provider.Usage{}, 0, false
+}
+
+// onUsage returns the callback that meters the usage t reports.
+func (s *querySettings) onUsage(t target) func(provider.Usage) {
+	return func(u provider.Usage) {
+		s.meter.add(s.prices, t.model, u)
+	}
+}
+
+// priceTable combines the built-in prices with those from the config file.
+func priceTable(prices map[string]config.Price) cost.Table {
+	overrides := make(cost.Table, len(prices))
+	for model, p := range prices {
+		overrides[model] = cost.Price{Input: p.Input, Output: p.Output}
+	}
+
+	return cost.Prices(overrides)
+}
+
+// checkBudget compares this month's spend with the budget before the first
+// query of the process, warning or, with the block action, failing once it
+// is reached.
+func (s *querySettings) checkBudget() error {
+	if s.budget.Monthly <= 0 || s.budgetChecked {
+		return nil
+	}
+
+	s.budgetChecked = true
+
+	entries, err := historyEntries()
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not check the budget: %v", err))
+
+		return nil
+	}
+
+	spent := cost.MonthToDate(entries, time.Now())
+	if spent < s.budget.Monthly {
+		return nil
+	}
+
+	msg := fmt.Sprintf("this month's spend of %s has reached the budget of %s", dollars(spent), dollars(s.budget.Monthly))
+	if s.budget.Action == budgetBlock {
+		return errors.Newf("%s; raise budget.monthly in the config file to continue", msg)
+	}
+
+	ui.PrintWarning("Budget: " + msg)
+
+	return nil
+}
+
+// historyEntries returns every recorded entry.
+func historyEntries() ([]history.Entry, error) {
+	store, err := openHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.List()
+}
+
+// usageReport is the JSON form of howto usage.
+type usageReport struct {
+	Since     time.Time    `json:"since"`
+	Days      []cost.Total `json:"days"`
+	Providers []cost.Total `json:"providers"`
+	Models    []cost.Total `json:"models"`
+	Total     cost.Total   `json:"total"`
+	// MonthToDate is this calendar month's spend and Budget its limit.
+	MonthToDate float64 `json:"month_to_date_usd"`
+	Budget      float64 `json:"budget_usd,omitempty"`
+}
+
+func runUsage(cmd *cobra.Command, _ []string) error {
+	now := time.Now()
+
+	since, err := history.ParseSince(usageSinceFlag, now)
+	if err != nil {
+		return errors.Wrap(err, "invalid --since")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	all, err := historyEntries()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]history.Entry, 0, len(all))
+	for _, e := range all {
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+
+	report := usageReport{
+		Since:       since,
+		Days:        cost.Summarize(entries, func(e history.Entry) string { return e.Time.Local().Format(time.DateOnly) }),
+		Providers:   cost.Summarize(entries, func(e history.Entry) string { return e.Provider }),
+		Models:      cost.Summarize(entries, func(e history.Entry) string { return e.Model }),
+		MonthToDate: cost.MonthToDate(all, now),
+		Budget:      cfg.Budget.Monthly,
+	}
+
+	if total := cost.Summarize(entries, func(history.Entry) string { return "total" }); len(total) > 0 {
+		report.Total = total[0]
+	}
+
+	report.Total.Key = "total"
+
+	if usageJSONFlag {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+
+		return errors.Wrap(encoder.Encode(report), "failed to write output")
+	}
+
+	if len(entries) == 0 {
+		ui.PrintInfo("No queries recorded since " + since.Local().Format(time.DateOnly))
+	} else {
+		printTotals("Day", report.Days)
+		printTotals("Provider", report.Providers)
+		printTotals("Model", report.Models)
+
+		ui.PrintInfo(fmt.Sprintf("Total: %d requests, %d input and %d output tokens, %s",
+			report.Total.Requests, report.Total.InputTokens, report.Total.OutputTokens, dollars(report.Total.USD)))
+	}
+
+	month := "This month: " + dollars(report.MonthToDate)
+	if report.Budget > 0 {
+		month += fmt.Sprintf(" of the %s budget (%.0f%%)", dollars(report.Budget), 100*report.MonthToDate/report.Budget)
+	}
+
+	ui.PrintInfo(month)
+
+	return nil
+}
+
+// printTotals prints totals as a table whose first column is titled by.
+func printTotals(by string, totals []cost.Total) {
+	rows := make([][]string, 0, len(totals))
+	for _, t := range totals {
+		rows = append(rows, []string{
+			t.Key,
+			strconv.Itoa(t.Requests),
+			strconv.Itoa(t.InputTokens),
+			strconv.Itoa(t.OutputTokens),
+			dollars(t.USD),
+		})
+	}
+
+	ui.PrintHeader("By " + by)
+	ui.PrintTable([]string{by, "Requests", "Input tokens", "Output tokens", "Cost"}, rows)
+}
+
+// dollars formats a US dollar amount, keeping fractions of a cent visible.
+func dollars(usd float64) string {
+	return fmt.Sprintf("$%.4f", usd)
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageSinceFlag, "since", "30d", "Only count queries newer than a duration (36h, 7d) or a date (2006-01-02)")
+	usageCmd.Flags().BoolVar(&usageJSONFlag, "json", false, "Print the totals as JSON")
+
+	rootCmd.AddCommand(usageCmd)
+}
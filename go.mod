@@ -3,10 +3,12 @@ module github.com/techquestsdev/howto
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/cockroachdb/errors v1.12.0
 	github.com/fatih/color v1.19.0
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/term v0.42.0
+	mvdan.cc/sh/v3 v3.12.0
 )
 
 require (
@@ -20,7 +22,7 @@ require (
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/rogpeppe/go-internal v1.9.0 // indirect
+	github.com/rogpeppe/go-internal v1.14.1 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 	golang.org/x/sys v0.43.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
@@ -0,0 +1,295 @@
+// Package cache stores provider responses on disk so repeated queries are
+// answered instantly and without cost.
+//
+// Entries are content-addressed: the file name is a hash of the provider,
+// the request parameters that shape the answer and the environment
+// fingerprint. They expire after a TTL, and the
+// least recently used ones are evicted once the cache outgrows its size cap.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+const (
+	// DefaultTTL is how long an entry is used when Cache.TTL is unset.
+	DefaultTTL = 7 * 24 * time.Hour
+	// DefaultMaxBytes caps the cache size when Cache.MaxBytes is unset.
+	DefaultMaxBytes = 5 << 20
+	// entrySuffix marks entry files.
+	entrySuffix = ".json"
+)
+
+// Dir returns the default cache directory.
+func Dir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "howto", "responses"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".cache", "howto", "responses"), nil
+}
+
+// Key identifies a response.
+type Key struct {
+	Provider string
+	// Endpoint tells apart the same provider served from elsewhere, e.g.
+	// by a profile's endpoint.
+	Endpoint string
+	Model    string
+	System   string
+	Prompt   string
+	// Messages is the conversation of a multi-turn request.
+	Messages    []provider.Turn
+	MaxTokens   int
+	Temperature *float64
+	Stop        []string
+	Format      provider.ResponseFormat
+	// Context is the Fingerprint of the environment the prompt describes.
+	Context string
+}
+
+// KeyFor returns the key of the response of p to req; fingerprint identifies
+// the environment the prompt describes.
+func KeyFor(p *provider.Provider, req provider.Request, fingerprint string) Key {
+	return Key{
+		Provider:    p.Name,
+		Endpoint:    p.Endpoint,
+		Model:       req.Model,
+		System:      req.System,
+		Prompt:      req.Prompt,
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stop:        req.Stop,
+		Format:      req.Format,
+		Context:     fingerprint,
+	}
+}
+
+// Hash returns the content address of k.
+func (k Key) Hash() string {
+	temperature := "default"
+	if k.Temperature != nil {
+		temperature = strconv.FormatFloat(*k.Temperature, 'g', -1, 64)
+	}
+
+	fields := []string{
+		k.Provider, k.Endpoint, k.Model, k.System, k.Prompt, k.Context,
+		strconv.Itoa(k.MaxTokens), temperature, strconv.Itoa(int(k.Format)),
+		strconv.Itoa(len(k.Messages)),
+	}
+
+	for _, t := range k.Messages {
+		fields = append(fields, string(t.Role), t.Content)
+	}
+
+	fields = append(fields, strconv.Itoa(len(k.Stop)))
+	fields = append(fields, k.Stop...)
+
+	h := sha256.New()
+
+	// Length prefixes, and counts before lists, keep field boundaries
+	// unambiguous
+	for _, field := range fields {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Fingerprint condenses environment facts into a short stable string.
+func Fingerprint(facts []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(facts, "\n")))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// entry is the content of an entry file.
+type entry struct {
+	Created  time.Time `json:"created"`
+	Response string    `json:"response"`
+}
+
+// Cache is a directory of cached responses.
+type Cache struct {
+	Dir string
+	// TTL is how long an entry is used; zero means DefaultTTL.
+	TTL time.Duration
+	// MaxBytes caps the total size of the entries; zero means DefaultMaxBytes.
+	MaxBytes int64
+	// Refresh skips lookups, so every query reaches the provider and
+	// rewrites its entry.
+	Refresh bool
+}
+
+// Get returns the response stored for k. Expired entries are removed; a hit
+// marks the entry as recently used.
+func (c *Cache) Get(k Key) (string, bool) {
+	path := c.path(k)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || time.Since(e.Created) > c.ttl() {
+		_ = os.Remove(path)
+
+		return "", false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return e.Response, true
+}
+
+// Put stores response for k, then evicts the least recently used entries
+// until the cache fits in MaxBytes.
+func (c *Cache) Put(k Key, response string) error {
+	data, err := json.Marshal(entry{Created: time.Now(), Response: response})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode cache entry")
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return errors.Wrap(err, "failed to create cache directory")
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	f, err := os.CreateTemp(c.Dir, "entry-*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	if err := os.Rename(f.Name(), c.path(k)); err != nil {
+		_ = os.Remove(f.Name())
+
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	return c.evict()
+}
+
+// Query answers req from the cache, or from p, storing the answer; fingerprint
+// identifies the environment the prompt describes. With a non-nil onToken the
+// provider response is streamed, and a cached one is delivered in a single
+// call. hit reports whether the cache answered.
+func (c *Cache) Query(ctx context.Context, p *provider.Provider, apiKey string, req provider.Request, fingerprint string, onToken provider.TokenFunc) (response string, hit bool, err error) {
+	k := KeyFor(p, req, fingerprint)
+
+	if !c.Refresh {
+		if cached, ok := c.Get(k); ok {
+			if onToken != nil {
+				onToken(cached)
+			}
+
+			return cached, true, nil
+		}
+	}
+
+	if onToken != nil {
+		response, err = p.QueryStream(ctx, apiKey, req, onToken)
+	} else {
+		response, err = p.Query(ctx, apiKey, req)
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	// A failure to cache must not lose the answer
+	_ = c.Put(k, response)
+
+	return response, false, nil
+}
+
+func (c *Cache) path(k Key) string {
+	return filepath.Join(c.Dir, k.Hash()+entrySuffix)
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+
+	return DefaultTTL
+}
+
+// evict removes the least recently used entries while the cache is larger
+// than MaxBytes.
+func (c *Cache) evict() error {
+	maxBytes := c.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	dirEntries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read cache directory")
+	}
+
+	var (
+		files []os.FileInfo
+		size  int64
+	)
+
+	for _, d := range dirEntries {
+		if !strings.HasSuffix(d.Name(), entrySuffix) {
+			continue
+		}
+
+		if info, err := d.Info(); err == nil {
+			files = append(files, info)
+			size += info.Size()
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	for _, info := range files {
+		if size <= maxBytes {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(c.Dir, info.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrap(err, "failed to evict cache entry")
+		}
+
+		size -= info.Size()
+	}
+
+	return nil
+}
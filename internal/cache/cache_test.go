@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+// countingProvider returns an OpenAI-compatible provider whose server counts
+// the requests it answers.
+func countingProvider(t *testing.T) (*provider.Provider, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"ls -la # %d"}}]}`, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := provider.CustomEndpoint{Name: "Test", BaseURL: srv.URL}.Provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, &calls
+}
+
+func TestDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/cache")
+
+	got, err := Dir()
+	if want := filepath.Join("/cache", "howto", "responses"); err != nil || got != want {
+		t.Errorf("Dir() = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestKeyHash(t *testing.T) {
+	t.Parallel()
+
+	base := Key{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc"}
+	zero := 0.0
+
+	if base.Hash() != base.Hash() {
+		t.Error("Hash() is not stable")
+	}
+
+	for _, other := range []Key{
+		{Provider: "Anthropic", Model: "gpt-4o", Prompt: "list files", Context: "abc"},
+		{Provider: "OpenAI", Endpoint: "http://localhost:8080/v1", Model: "gpt-4o", Prompt: "list files", Context: "abc"},
+		{Provider: "OpenAI", Model: "gpt-4o-mini", Prompt: "list files", Context: "abc"},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list all files", Context: "abc"},
+		{Provider: "OpenAI", Model: "gpt-4o", System: "Prefer fd", Prompt: "list files", Context: "abc"},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abd"},
+		{Provider: "OpenAIgpt-4o", Prompt: "list files", Context: "abc"},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc", MaxTokens: 600},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc", Temperature: &zero},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc", Stop: []string{"\n"}},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc", Format: provider.FormatJSON},
+		{Provider: "OpenAI", Model: "gpt-4o", Prompt: "list files", Context: "abc", Messages: []provider.Turn{
+			{Role: provider.RoleUser, Content: "list files"},
+			{Role: provider.RoleAssistant, Content: "ls"},
+			{Role: provider.RoleUser, Content: "include hidden ones"},
+		}},
+	} {
+		if other.Hash() == base.Hash() {
+			t.Errorf("%+v hashes like %+v", other, base)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	p, calls := countingProvider(t)
+	c := &Cache{Dir: t.TempDir()}
+	req := provider.Request{Model: "m", Prompt: "list files"}
+	ctx := context.Background()
+
+	first, hit, err := c.Query(ctx, p, "", req, "env", nil)
+	if err != nil || hit || first != "ls -la # 1" {
+		t.Fatalf("first Query() = %q, %v, %v; want a miss", first, hit, err)
+	}
+
+	var streamed strings.Builder
+
+	second, hit, err := c.Query(ctx, p, "", req, "env", func(token string) { streamed.WriteString(token) })
+	if err != nil || !hit || second != first || streamed.String() != first {
+		t.Errorf("second Query() = %q (streamed %q), %v, %v; want a hit", second, streamed.String(), hit, err)
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server saw %d requests after a hit, want 1", got)
+	}
+
+	// A different environment is a different key
+	if _, hit, _ := c.Query(ctx, p, "", req, "other", nil); hit {
+		t.Error("Query() with another fingerprint hit the cache")
+	}
+
+	// So is the same provider at another endpoint
+	elsewhere, elsewhereCalls := countingProvider(t)
+	if elsewhere.Name != p.Name {
+		t.Fatalf("providers are named %q and %q, want the same name", p.Name, elsewhere.Name)
+	}
+
+	if response, hit, _ := c.Query(ctx, elsewhere, "", req, "env", nil); hit || response != "ls -la # 1" || elsewhereCalls.Load() != 1 {
+		t.Errorf("Query() at another endpoint = %q, %v; want that endpoint's answer", response, hit)
+	}
+
+	// Refresh goes to the provider and rewrites the entry
+	refresh := &Cache{Dir: c.Dir, Refresh: true}
+	if response, hit, _ := refresh.Query(ctx, p, "", req, "env", nil); hit || response != "ls -la # 3" {
+		t.Errorf("refreshing Query() = %q, %v; want a fresh answer", response, hit)
+	}
+
+	if response, hit, _ := c.Query(ctx, p, "", req, "env", nil); !hit || response != "ls -la # 3" {
+		t.Errorf("Query() after refresh = %q, %v; want the refreshed answer", response, hit)
+	}
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+}
+
+func TestGetExpired(t *testing.T) {
+	t.Parallel()
+
+	c := &Cache{Dir: t.TempDir(), TTL: time.Hour}
+	k := Key{Prompt: "old"}
+
+	if err := c.Put(k, "ls"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the entry past its TTL
+	data := fmt.Sprintf(`{"created":%q,"response":"ls"}`, time.Now().Add(-2*time.Hour).Format(time.RFC3339))
+	if err := os.WriteFile(c.path(k), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if response, ok := c.Get(k); ok {
+		t.Errorf("Get() = %q for an expired entry", response)
+	}
+
+	if _, err := os.Stat(c.path(k)); !os.IsNotExist(err) {
+		t.Errorf("expired entry was not removed: %v", err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	t.Parallel()
+
+	c := &Cache{Dir: t.TempDir()}
+	response := strings.Repeat("x", 100)
+	keys := []Key{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}}
+
+	for i, k := range keys {
+		if err := c.Put(k, response); err != nil {
+			t.Fatal(err)
+		}
+
+		// Space the entries out so their use order is unambiguous
+		used := time.Now().Add(time.Duration(i-10) * time.Minute)
+		_ = os.Chtimes(c.path(k), used, used)
+	}
+
+	// Using "a" makes "b" the least recently used entry
+	if _, ok := c.Get(keys[0]); !ok {
+		t.Fatal("Get() missed a fresh entry")
+	}
+
+	info, err := os.Stat(c.path(keys[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries differ by a few bytes, as timestamps vary in length; leave
+	// room for three of them but not for four
+	c.MaxBytes = 3*info.Size() + info.Size()/2
+	if err := c.Put(Key{Prompt: "d"}, response); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		prompt string
+		want   bool
+	}{{"a", true}, {"b", false}, {"c", true}, {"d", true}} {
+		if _, ok := c.Get(Key{Prompt: tt.prompt}); ok != tt.want {
+			t.Errorf("entry %q cached = %v, want %v", tt.prompt, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package config loads howto's configuration file and its named profiles.
+//
+// Settings are resolved with the precedence flag > environment > profile >
+// built-in defaults. The file lives at $XDG_CONFIG_HOME/howto/config.toml
+// (~/.config/howto/config.toml when XDG_CONFIG_HOME is unset) and can be
+// pointed elsewhere with HOWTO_CONFIG, e.g. at a profile checked into a repo.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cockroachdb/errors"
+)
+
+// Environment variables read by howto.
+const (
+	// PathEnvVar overrides the configuration file location.
+	PathEnvVar = "HOWTO_CONFIG"
+	// ProfileEnvVar selects a profile.
+	ProfileEnvVar = "HOWTO_PROFILE"
+	// ProviderEnvVar forces a provider.
+	ProviderEnvVar = "HOWTO_PROVIDER"
+	// ModelEnvVar overrides the model.
+	ModelEnvVar = "HOWTO_MODEL"
+)
+
+// DefaultProfile is used when no profile is selected but one with this name exists.
+const DefaultProfile = "default"
+
+// Config is the content of the configuration file.
+type Config struct {
+	// Profile names the profile used when --profile and HOWTO_PROFILE are unset.
+	Profile  string             `toml:"profile"`
+	Profiles map[string]Profile `toml:"profiles"`
+	// Providers declares custom OpenAI-compatible endpoints, keyed by name.
+	Providers map[string]CustomProvider `toml:"providers"`
+	// History turns recording queries in the history file on or off; it is
+	// on by default.
+	History *bool `toml:"history"`
+	// Cache configures the response cache.
+	Cache Cache `toml:"cache"`
+	// Prices adds to or overrides the built-in price table, keyed by model.
+	Prices map[string]Price `toml:"prices"`
+	// Budget caps what queries may cost per calendar month.
+	Budget Budget `toml:"budget"`
+	// Prompt locates the prompt template and few-shot examples.
+	Prompt Prompt `toml:"prompt"`
+	// Azure configures the Azure OpenAI provider.
+	Azure Azure `toml:"azure"`
+	// Gemini configures the Gemini provider.
+	Gemini Gemini `toml:"gemini"`
+}
+
+// Azure configures the Azure OpenAI provider.
+type Azure struct {
+	// Resource is the name of the Azure OpenAI resource, short for an
+	// endpoint of https://<resource>.openai.azure.com.
+	Resource string `toml:"resource"`
+	// Endpoint is the resource URL; it takes precedence over Resource.
+	Endpoint   string `toml:"endpoint"`
+	APIVersion string `toml:"api_version"`
+	// TokenCommand prints a Microsoft Entra ID access token, sent instead of
+	// an API key.
+	TokenCommand string `toml:"token_command"`
+	// Deployments maps model names to deployment names.
+	Deployments map[string]string `toml:"deployments"`
+}
+
+// Gemini configures the Gemini provider.
+type Gemini struct {
+	// SafetySettings maps harm categories, e.g.
+	// HARM_CATEGORY_DANGEROUS_CONTENT, to the threshold at which Gemini
+	// blocks content in them, e.g. BLOCK_ONLY_HIGH.
+	SafetySettings map[string]string `toml:"safety_settings"`
+}
+
+// Default names of the prompt files, looked up next to the config file.
+const (
+	DefaultPromptTemplate = "prompt.tmpl"
+	DefaultExamples       = "examples.toml"
+)
+
+// Prompt locates the files that customize the prompt. Relative paths are
+// resolved against the directory of the config file.
+type Prompt struct {
+	// Template is a text/template file replacing the built-in prompt.
+	Template string `toml:"template"`
+	// Examples is a TOML file of [[example]] tables with query and command
+	// keys, shown to the model as few-shot examples.
+	Examples string `toml:"examples"`
+}
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Input  float64 `toml:"input"`
+	Output float64 `toml:"output"`
+}
+
+// Budget caps the monthly spend recorded in the history.
+type Budget struct {
+	// Monthly is the limit in US dollars; zero means none.
+	Monthly float64 `toml:"monthly"`
+	// Action is "warn" (the default) or "block", which refuses to query a
+	// provider once the limit is reached.
+	Action string `toml:"action"`
+}
+
+// Cache configures the response cache.
+type Cache struct {
+	// Enabled turns the cache on or off; it is on by default.
+	Enabled *bool `toml:"enabled"`
+	// TTL is how long a response is reused.
+	TTL time.Duration `toml:"ttl"`
+	// MaxBytes caps the size of the cache directory.
+	MaxBytes int64 `toml:"max_bytes"`
+}
+
+// Profile is a named set of defaults for queries.
+type Profile struct {
+	Provider    string        `toml:"provider"`
+	Model       string        `toml:"model"`
+	Endpoint    string        `toml:"endpoint"`
+	Timeout     time.Duration `toml:"timeout"`
+	MaxTokens   int           `toml:"max_tokens"`
+	Temperature *float64      `toml:"temperature"`
+	// Instructions are appended to the prompt's instruction list.
+	Instructions []string `toml:"instructions"`
+	// MaxRisk is the highest risk level inserted without confirmation.
+	MaxRisk string `toml:"max_risk"`
+	// Context lists the environment sources described in the prompt; unset
+	// means all of them and an empty list none.
+	Context         []string `toml:"context"`
+	ContextMaxBytes int      `toml:"context_max_bytes"`
+	// MaxFixes is how many corrected commands --exec asks for; 0 disables
+	// the fix loop.
+	MaxFixes *int `toml:"max_fixes"`
+	// Fallback lists the providers tried, in order, when the provider fails;
+	// unset means every configured provider by priority and an empty list
+	// none.
+	Fallback []string `toml:"fallback"`
+}
+
+// CustomProvider declares an OpenAI-compatible endpoint such as LM Studio,
+// vLLM, a llama.cpp server, OpenRouter or Groq.
+type CustomProvider struct {
+	// BaseURL is the API root, e.g. "https://openrouter.ai/api/v1".
+	BaseURL string `toml:"base_url"`
+	// Auth is "bearer", "header" or "none"; it defaults to bearer when
+	// KeyEnv is set and none otherwise.
+	Auth string `toml:"auth"`
+	// AuthHeader names the header carrying the key when Auth is "header".
+	AuthHeader   string            `toml:"auth_header"`
+	KeyEnv       string            `toml:"key_env"`
+	DefaultModel string            `toml:"default_model"`
+	Headers      map[string]string `toml:"headers"`
+	// Priority orders detection; lower values win (built-ins use 10-50).
+	Priority int `toml:"priority"`
+}
+
+// Path returns the location of the configuration file.
+func Path() (string, error) {
+	if path := os.Getenv(PathEnvVar); path != "" {
+		return path, nil
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "howto", "config.toml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".config", "howto", "config.toml"), nil
+}
+
+// PromptFiles returns the paths of the prompt template and examples files.
+func (c *Config) PromptFiles() (string, string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := filepath.Dir(path)
+
+	resolve := func(name, fallback string) string {
+		if name == "" {
+			name = fallback
+		}
+
+		if filepath.IsAbs(name) {
+			return name
+		}
+
+		return filepath.Join(dir, name)
+	}
+
+	return resolve(c.Prompt.Template, DefaultPromptTemplate), resolve(c.Prompt.Examples, DefaultExamples), nil
+}
+
+// Load reads the configuration file. A missing file yields an empty Config.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadFile(path)
+}
+
+// LoadFile reads the configuration from path. A missing file yields an empty
+// Config; unknown keys are rejected so typos do not go unnoticed.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+
+		return nil, errors.Newf("%s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+
+	return cfg, nil
+}
+
+// SelectProfile returns the profile to use. An explicit name (from --profile)
+// wins over HOWTO_PROFILE, which wins over the file's profile key. Without any
+// selection the "default" profile is used if present, else an empty one.
+func (c *Config) SelectProfile(name string) (Profile, string, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnvVar)
+	}
+
+	if name == "" {
+		name = c.Profile
+	}
+
+	if name == "" {
+		return c.Profiles[DefaultProfile], DefaultProfile, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, "", errors.Newf("profile %q not found (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	return profile, name, nil
+}
+
+// ProfileNames returns the names of all profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestPath(t *testing.T) {
+	t.Run("HOWTO_CONFIG wins", func(t *testing.T) {
+		t.Setenv(PathEnvVar, "/tmp/team.toml")
+		t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+		got, err := Path()
+		if err != nil || got != "/tmp/team.toml" {
+			t.Errorf("Path() = %q, %v; want %q", got, err, "/tmp/team.toml")
+		}
+	})
+
+	t.Run("XDG_CONFIG_HOME", func(t *testing.T) {
+		t.Setenv(PathEnvVar, "")
+		t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+		got, err := Path()
+		want := filepath.Join("/xdg", "howto", "config.toml")
+
+		if err != nil || got != want {
+			t.Errorf("Path() = %q, %v; want %q", got, err, want)
+		}
+	})
+}
+
+func TestPromptFiles(t *testing.T) {
+	t.Setenv(PathEnvVar, "/team/howto.toml")
+
+	tests := []struct {
+		name                       string
+		prompt                     Prompt
+		wantTemplate, wantExamples string
+	}{
+		{"defaults", Prompt{}, "/team/prompt.tmpl", "/team/examples.toml"},
+		{"relative", Prompt{Template: "prompts/ops.tmpl"}, "/team/prompts/ops.tmpl", "/team/examples.toml"},
+		{"absolute", Prompt{Examples: "/etc/howto/examples.toml"}, "/team/prompt.tmpl", "/etc/howto/examples.toml"},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Prompt: tt.prompt}
+
+		template, examples, err := cfg.PromptFiles()
+		if err != nil || template != tt.wantTemplate || examples != tt.wantExamples {
+			t.Errorf("%s: PromptFiles() = %q, %q, %v; want %q, %q", tt.name, template, examples, err, tt.wantTemplate, tt.wantExamples)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing file yields empty config", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := LoadFile(filepath.Join(t.TempDir(), "missing.toml"))
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+
+		if len(cfg.Profiles) != 0 {
+			t.Errorf("LoadFile() profiles = %v, want none", cfg.Profiles)
+		}
+	})
+
+	t.Run("parses profiles", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeConfig(t, `
+profile = "work"
+
+[profiles.work]
+provider = "Anthropic"
+model = "claude-test"
+timeout = "45s"
+max_tokens = 500
+temperature = 0
+instructions = ["Prefer rg"]
+`)
+
+		cfg, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+
+		work := cfg.Profiles["work"]
+		if work.Provider != "Anthropic" || work.Model != "claude-test" || work.MaxTokens != 500 {
+			t.Errorf("LoadFile() work profile = %+v", work)
+		}
+
+		if work.Timeout != 45*time.Second {
+			t.Errorf("LoadFile() timeout = %v, want 45s", work.Timeout)
+		}
+
+		if work.Temperature == nil || *work.Temperature != 0 {
+			t.Errorf("LoadFile() temperature = %v, want explicit 0", work.Temperature)
+		}
+	})
+
+	t.Run("parses gemini safety settings", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeConfig(t, `
+[gemini.safety_settings]
+HARM_CATEGORY_DANGEROUS_CONTENT = "BLOCK_ONLY_HIGH"
+`)
+
+		cfg, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("LoadFile() error = %v", err)
+		}
+
+		if got := cfg.Gemini.SafetySettings["HARM_CATEGORY_DANGEROUS_CONTENT"]; got != "BLOCK_ONLY_HIGH" {
+			t.Errorf("LoadFile() dangerous content threshold = %q, want BLOCK_ONLY_HIGH", got)
+		}
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		t.Parallel()
+
+		path := writeConfig(t, "[profiles.work]\nmodle = \"typo\"\n")
+
+		if _, err := LoadFile(path); err == nil {
+			t.Error("LoadFile() expected error for unknown key, got nil")
+		}
+	})
+}
+
+func TestSelectProfile(t *testing.T) {
+	cfg := &Config{
+		Profile: "file",
+		Profiles: map[string]Profile{
+			"default": {Model: "default-model"},
+			"file":    {Model: "file-model"},
+			"env":     {Model: "env-model"},
+			"flag":    {Model: "flag-model"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		flag     string
+		env      string
+		fileKey  string
+		expected string
+		wantErr  bool
+	}{
+		{name: "flag wins", flag: "flag", env: "env", fileKey: "file", expected: "flag-model"},
+		{name: "env beats file", env: "env", fileKey: "file", expected: "env-model"},
+		{name: "file key", fileKey: "file", expected: "file-model"},
+		{name: "default profile", expected: "default-model"},
+		{name: "unknown profile", flag: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ProfileEnvVar, tt.env)
+
+			cfg.Profile = tt.fileKey
+
+			got, _, err := cfg.SelectProfile(tt.flag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got.Model != tt.expected {
+				t.Errorf("SelectProfile() model = %q, want %q", got.Model, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileEdit(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "config.toml")
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	sets := [][2]string{
+		{"profile", "work"},
+		{"profiles.work.model", "gpt-4o-mini"},
+		{"profiles.work.max_tokens", "500"},
+		{"profiles.work.timeout", "45s"},
+		{"profiles.work.instructions", `["Prefer rg", "Prefer fd"]`},
+	}
+	for _, kv := range sets {
+		if err := f.Set(kv[0], kv[1]); err != nil {
+			t.Fatalf("Set(%q, %q) error = %v", kv[0], kv[1], err)
+		}
+	}
+
+	if err := f.Set("profiles.work.modle", "typo"); err == nil {
+		t.Error("Set() expected error for unknown key, got nil")
+	}
+
+	if err := f.Set("profiles.work.max_tokens", "many"); err == nil {
+		t.Error("Set() expected error for wrong type, got nil")
+	}
+
+	if err := f.Set("profiles.work.max_tokens", "500"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	work := cfg.Profiles["work"]
+	if cfg.Profile != "work" || work.Model != "gpt-4o-mini" || work.MaxTokens != 500 ||
+		work.Timeout != 45*time.Second || len(work.Instructions) != 2 {
+		t.Errorf("round trip = %+v / %+v", cfg, work)
+	}
+
+	value, ok := f.Get("profiles.work.max_tokens")
+	if !ok || FormatValue(value) != "500" {
+		t.Errorf("Get() = %v, %v; want 500", value, ok)
+	}
+
+	if _, ok := f.Get("profiles.home.model"); ok {
+		t.Error("Get() found a key that was never set")
+	}
+}
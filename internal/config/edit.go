@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cockroachdb/errors"
+)
+
+// File is an editable view of the configuration file used by the
+// `howto config` subcommands. Keys are dotted paths such as
+// "profiles.work.model".
+type File struct {
+	path string
+	tree map[string]any
+}
+
+// Open reads the configuration file at path for editing. A missing file
+// yields an empty tree that Save will create.
+func Open(path string) (*File, error) {
+	f := &File{path: path, tree: map[string]any{}}
+
+	if _, err := toml.DecodeFile(path, &f.tree); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	return f, nil
+}
+
+// Get returns the value stored at key.
+func (f *File) Get(key string) (any, bool) {
+	var node any = f.tree
+
+	for part := range strings.SplitSeq(key, ".") {
+		table, ok := node.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if node, ok = table[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// Set stores value at key, creating intermediate tables. The value is parsed
+// as a TOML literal when possible (numbers, booleans, arrays, quoted strings)
+// and stored as a plain string otherwise. If the result is not a valid
+// configuration the file is left unchanged.
+func (f *File) Set(key, value string) error {
+	parts := strings.Split(key, ".")
+	for _, part := range parts {
+		if part == "" {
+			return errors.Newf("invalid key %q", key)
+		}
+	}
+
+	tree, err := clone(f.tree)
+	if err != nil {
+		return err
+	}
+
+	table := tree
+
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := table[part].(map[string]any)
+		if !ok {
+			if _, exists := table[part]; exists {
+				return errors.Newf("%s is not a table", part)
+			}
+
+			next = map[string]any{}
+			table[part] = next
+		}
+
+		table = next
+	}
+
+	table[parts[len(parts)-1]] = parseValue(value)
+
+	if err := validate(tree); err != nil {
+		return err
+	}
+
+	f.tree = tree
+
+	return nil
+}
+
+// List returns every leaf key with its value, sorted by key.
+func (f *File) List() [][2]string {
+	var entries [][2]string
+
+	var walk func(prefix string, table map[string]any)
+
+	walk = func(prefix string, table map[string]any) {
+		for key, value := range table {
+			if nested, ok := value.(map[string]any); ok {
+				walk(prefix+key+".", nested)
+
+				continue
+			}
+
+			entries = append(entries, [2]string{prefix + key, FormatValue(value)})
+		}
+	}
+	walk("", f.tree)
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i][0] < entries[j][0] })
+
+	return entries
+}
+
+// Save writes the configuration back to disk, creating its directory.
+// Comments in the original file are not preserved.
+func (f *File) Save() error {
+	data, err := encode(f.tree)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create configuration directory")
+	}
+
+	if err := os.WriteFile(f.path, data, 0o600); err != nil {
+		return errors.Wrap(err, "failed to write configuration")
+	}
+
+	return nil
+}
+
+// validate checks that tree decodes into a Config without unknown keys.
+func validate(tree map[string]any) error {
+	data, err := encode(tree)
+	if err != nil {
+		return err
+	}
+
+	var cfg Config
+
+	md, err := toml.Decode(string(data), &cfg)
+	if err != nil {
+		return errors.Wrap(err, "invalid value")
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return errors.Newf("unknown key: %s", undecoded[0].String())
+	}
+
+	return nil
+}
+
+// parseValue interprets value as a TOML literal, falling back to a string.
+func parseValue(value string) any {
+	var doc map[string]any
+	if _, err := toml.Decode("v = "+value, &doc); err == nil {
+		return doc["v"]
+	}
+
+	return value
+}
+
+// FormatValue renders a configuration value for display.
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]any:
+		data, _ := encode(v)
+
+		return strings.TrimSpace(string(data))
+	default:
+		data, _ := encode(map[string]any{"v": v})
+
+		return strings.TrimPrefix(strings.TrimSpace(string(data)), "v = ")
+	}
+}
+
+// clone deep-copies a configuration tree so edits can be discarded.
+func clone(tree map[string]any) (map[string]any, error) {
+	data, err := encode(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := map[string]any{}
+	if _, err := toml.Decode(string(data), &copied); err != nil {
+		return nil, errors.Wrap(err, "failed to copy configuration")
+	}
+
+	return copied, nil
+}
+
+// encode serializes a configuration tree as TOML.
+func encode(tree map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+
+	if err := enc.Encode(tree); err != nil {
+		return nil, errors.Wrap(err, "failed to encode configuration")
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+// Package cost prices provider usage and totals the spend recorded in the
+// history.
+package cost
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/techquestsdev/howto/internal/history"
+)
+
+// Price is what a model costs in US dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the price of a request with the given token counts.
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// Table maps model names to prices. A name also prices every model it is a
+// prefix of, up to a "-", ":" or "@", so "claude-sonnet-4" covers
+// "claude-sonnet-4-20250514".
+type Table map[string]Price
+
+// defaultPrices are the list prices of the providers' common models.
+var defaultPrices = Table{
+	"gpt-4o":                {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":           {Input: 0.15, Output: 0.60},
+	"gpt-4.1":               {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":          {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":          {Input: 0.10, Output: 0.40},
+	"o3-mini":               {Input: 1.10, Output: 4.40},
+	"o4-mini":               {Input: 1.10, Output: 4.40},
+	"claude-opus-4":         {Input: 15.00, Output: 75.00},
+	"claude-sonnet-4":       {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet":     {Input: 3.00, Output: 15.00},
+	"claude-3-5-sonnet":     {Input: 3.00, Output: 15.00},
+	"claude-3-5-haiku":      {Input: 0.80, Output: 4.00},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10.00},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+	"deepseek-chat":         {Input: 0.27, Output: 1.10},
+	"deepseek-reasoner":     {Input: 0.55, Output: 2.19},
+}
+
+// Prices returns the default table with overrides applied.
+func Prices(overrides Table) Table {
+	t := make(Table, len(defaultPrices)+len(overrides))
+	for model, price := range defaultPrices {
+		t[model] = price
+	}
+
+	for model, price := range overrides {
+		t[strings.ToLower(model)] = price
+	}
+
+	return t
+}
+
+// Lookup returns the price of model: an exact match, else the longest entry
+// it starts with. Provider prefixes such as "openai/" (OpenRouter) are
+// ignored.
+func (t Table) Lookup(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var (
+		best  string
+		price Price
+	)
+
+	for name, p := range t {
+		if len(name) > len(best) && len(model) > len(name) && strings.HasPrefix(model, name) &&
+			strings.ContainsRune("-:@", rune(model[len(name)])) {
+			best, price = name, p
+		}
+	}
+
+	return price, best != ""
+}
+
+// Total sums the usage of a group of history entries.
+type Total struct {
+	Key          string  `json:"key"`
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	USD          float64 `json:"cost_usd"`
+}
+
+// Summarize groups entries by key, sorted by key.
+func Summarize(entries []history.Entry, key func(history.Entry) string) []Total {
+	index := map[string]int{}
+	totals := []Total{}
+
+	for _, e := range entries {
+		k := key(e)
+
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, Total{Key: k})
+		}
+
+		totals[i].Requests++
+		totals[i].InputTokens += e.InputTokens
+		totals[i].OutputTokens += e.OutputTokens
+		totals[i].USD += e.CostUSD
+	}
+
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Key < totals[j].Key })
+
+	return totals
+}
+
+// MonthToDate returns the spend recorded since the start of now's month.
+func MonthToDate(entries []history.Entry, now time.Time) float64 {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var spent float64
+
+	for _, e := range entries {
+		if !e.Time.Before(start) {
+			spent += e.CostUSD
+		}
+	}
+
+	return spent
+}
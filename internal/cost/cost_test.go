@@ -0,0 +1,87 @@
+package cost
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/techquestsdev/howto/internal/history"
+)
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	prices := Prices(Table{"GPT-4o": {Input: 1, Output: 2}, "llama3.2": {}})
+
+	tests := []struct {
+		model string
+		want  Price
+		ok    bool
+	}{
+		{"gpt-4o", Price{Input: 1, Output: 2}, true},
+		{"gpt-4o-mini", Price{Input: 0.15, Output: 0.60}, true},
+		{"gpt-4o-mini-2024-07-18", Price{Input: 0.15, Output: 0.60}, true},
+		{"claude-sonnet-4-20250514", Price{Input: 3, Output: 15}, true},
+		{"openai/gpt-4.1-nano", Price{Input: 0.10, Output: 0.40}, true},
+		{"llama3.2:latest", Price{}, true},
+		{"gpt-4oo", Price{}, false},
+		{"mystery-model", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := prices.Lookup(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %+v, %v; want %+v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCost(t *testing.T) {
+	t.Parallel()
+
+	got := Price{Input: 2.5, Output: 10}.Cost(2000, 100)
+	if want := 0.006; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Cost() = %v, want %v", got, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	entries := []history.Entry{
+		{Provider: "OpenAI", InputTokens: 100, OutputTokens: 10, CostUSD: 0.5},
+		{Provider: "Anthropic", InputTokens: 50, OutputTokens: 5, CostUSD: 0.25},
+		{Provider: "OpenAI", InputTokens: 200, OutputTokens: 20, CostUSD: 1},
+	}
+
+	got := Summarize(entries, func(e history.Entry) string { return e.Provider })
+	want := []Total{
+		{Key: "Anthropic", Requests: 1, InputTokens: 50, OutputTokens: 5, USD: 0.25},
+		{Key: "OpenAI", Requests: 2, InputTokens: 300, OutputTokens: 30, USD: 1.5},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Summarize() = %+v, want %+v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Summarize()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMonthToDate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	entries := []history.Entry{
+		{Time: time.Date(2025, 2, 28, 23, 59, 0, 0, time.UTC), CostUSD: 5},
+		{Time: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), CostUSD: 1},
+		{Time: time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC), CostUSD: 0.5},
+	}
+
+	if got := MonthToDate(entries, now); got != 1.5 {
+		t.Errorf("MonthToDate() = %v, want 1.5", got)
+	}
+}
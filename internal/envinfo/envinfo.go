@@ -0,0 +1,383 @@
+// Package envinfo collects facts about the user's environment (shell,
+// distribution, package manager, available tools, working directory and git
+// state) so suggestions fit the machine they will run on.
+//
+// Every source can be turned off and every fact is size-capped, since the
+// result is sent to the provider with each query.
+package envinfo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Source is a kind of information the collector gathers.
+type Source string
+
+// Sources, in the order their facts are reported.
+const (
+	SourceShell          Source = "shell"
+	SourceDistro         Source = "distro"
+	SourcePackageManager Source = "package-manager"
+	SourceCoreutils      Source = "coreutils"
+	SourceTools          Source = "tools"
+	SourceDirectory      Source = "directory"
+	SourceGit            Source = "git"
+)
+
+// AllSources lists every source.
+var AllSources = []Source{
+	SourceShell, SourceDistro, SourcePackageManager, SourceCoreutils,
+	SourceTools, SourceDirectory, SourceGit,
+}
+
+const (
+	// DefaultMaxBytes caps the total size of the collected facts.
+	DefaultMaxBytes = 1500
+	// maxFactBytes caps a single fact.
+	maxFactBytes = 300
+	// collectTimeout bounds the external commands run by the collector.
+	collectTimeout = time.Second
+	// maxListedEntries is how many directory entries are named.
+	maxListedEntries = 20
+)
+
+// ParseSources validates source names such as "shell" or "git".
+func ParseSources(names []string) ([]Source, error) {
+	sources := make([]Source, 0, len(names))
+
+	for _, name := range names {
+		source := Source(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(AllSources, source) {
+			valid := make([]string, len(AllSources))
+			for i, s := range AllSources {
+				valid[i] = string(s)
+			}
+
+			return nil, errors.Newf("unknown context source %q (use %s)", name, strings.Join(valid, ", "))
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+// ShellFact names the fact holding the user's shell.
+const ShellFact = "Shell"
+
+// Fact is one piece of collected information, e.g. "Shell: zsh".
+type Fact struct {
+	Name  string
+	Value string
+}
+
+func (f Fact) String() string {
+	return f.Name + ": " + f.Value
+}
+
+// Collector gathers facts from the enabled sources.
+type Collector struct {
+	// Sources are the enabled sources; nil means AllSources and an empty
+	// slice none.
+	Sources []Source
+	// MaxBytes caps the total size of the facts; zero means DefaultMaxBytes.
+	MaxBytes int
+	// Dir is the directory described by the directory and git sources; empty
+	// means the working directory.
+	Dir string
+}
+
+// Collect gathers the facts. Sources that fail or do not apply (e.g. git
+// outside a repository) are skipped, and facts past the size cap are dropped.
+func (c Collector) Collect(ctx context.Context) []Fact {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	sources := c.Sources
+	if sources == nil {
+		sources = AllSources
+	}
+
+	maxBytes := c.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+
+	dir := c.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	var (
+		facts []Fact
+		size  int
+	)
+
+	for _, source := range AllSources {
+		if !slices.Contains(sources, source) {
+			continue
+		}
+
+		for _, fact := range collect(ctx, source, dir) {
+			if fact.Value == "" {
+				continue
+			}
+
+			fact.Value = truncate(fact.Value, maxFactBytes)
+
+			if size += len(fact.String()); size > maxBytes {
+				return facts
+			}
+
+			facts = append(facts, fact)
+		}
+	}
+
+	return facts
+}
+
+func collect(ctx context.Context, source Source, dir string) []Fact {
+	switch source {
+	case SourceShell:
+		return []Fact{{Name: ShellFact, Value: detectShell()}}
+	case SourceDistro:
+		return []Fact{{Name: "Distribution", Value: detectDistro()}}
+	case SourcePackageManager:
+		return []Fact{{Name: "Package managers", Value: strings.Join(onPath(packageManagers), ", ")}}
+	case SourceCoreutils:
+		return []Fact{{Name: "Coreutils", Value: detectCoreutils(ctx)}}
+	case SourceTools:
+		return []Fact{{Name: "Installed tools", Value: strings.Join(onPath(tools), ", ")}}
+	case SourceDirectory:
+		return []Fact{
+			{Name: "Working directory", Value: dir},
+			{Name: "Directory contents", Value: summarizeDir(dir)},
+		}
+	case SourceGit:
+		return []Fact{{Name: "Git", Value: gitState(ctx, dir)}}
+	}
+
+	return nil
+}
+
+var shells = []string{"bash", "zsh", "fish", "sh", "dash", "ksh", "tcsh", "csh", "nu", "pwsh", "powershell"}
+
+// detectShell prefers the parent process, which is the shell howto runs in,
+// over $SHELL, which is only the login shell.
+func detectShell() string {
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", os.Getppid())); err == nil {
+		if name := strings.TrimPrefix(strings.TrimSpace(string(comm)), "-"); slices.Contains(shells, name) {
+			return name
+		}
+	}
+
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return filepath.Base(shell)
+	}
+
+	return ""
+}
+
+func detectDistro() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	return parseOSRelease(f)
+}
+
+// parseOSRelease returns PRETTY_NAME from an os-release file, falling back to
+// NAME and VERSION_ID.
+func parseOSRelease(r io.Reader) string {
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"'`)
+		}
+	}
+
+	if pretty := values["PRETTY_NAME"]; pretty != "" {
+		return pretty
+	}
+
+	return strings.TrimSpace(values["NAME"] + " " + values["VERSION_ID"])
+}
+
+var packageManagers = []string{
+	"apt", "dnf", "yum", "pacman", "zypper", "apk", "emerge", "xbps-install", "nix",
+	"brew", "port", "pkg", "winget", "choco", "scoop",
+}
+
+var tools = []string{
+	"rg", "fd", "fdfind", "jq", "yq", "fzf", "bat", "eza", "gawk", "parallel", "rsync",
+	"curl", "wget", "git", "gh", "make", "docker", "podman", "kubectl", "helm",
+	"terraform", "aws", "gcloud", "az", "python3", "node", "go", "cargo", "ffmpeg",
+	"magick", "tmux", "systemctl",
+}
+
+// onPath returns the names that resolve to an executable.
+func onPath(names []string) []string {
+	var found []string
+
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+
+	return found
+}
+
+// detectCoreutils tells GNU, BSD and BusyBox userlands apart, since their
+// flags differ (sed -i, date -d, stat -c, ...).
+func detectCoreutils(ctx context.Context) string {
+	out, err := exec.CommandContext(ctx, "sed", "--version").CombinedOutput()
+	if errors.Is(err, exec.ErrNotFound) {
+		return ""
+	}
+
+	switch text := string(out); {
+	case err == nil && strings.Contains(text, "BusyBox"), strings.Contains(text, "not GNU"):
+		return "BusyBox"
+	case err == nil && strings.Contains(text, "GNU"):
+		return "GNU"
+	case runtime.GOOS == "windows":
+		return ""
+	}
+
+	if _, err := exec.LookPath("gsed"); err == nil {
+		return "BSD (GNU versions installed with a g prefix, e.g. gsed, gdate)"
+	}
+
+	return "BSD"
+}
+
+// summarizeDir counts the entries of dir and names the first few, marking
+// directories with a trailing slash. Hidden entries are only counted.
+func summarizeDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	var names []string
+
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") || len(names) == maxListedEntries {
+			continue
+		}
+
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+
+		names = append(names, name)
+	}
+
+	summary := fmt.Sprintf("%d entries", len(entries))
+	if len(names) > 0 {
+		summary += ": " + strings.Join(names, ", ")
+	}
+
+	if len(entries) > len(names) {
+		summary += ", ..."
+	}
+
+	return summary
+}
+
+func gitState(ctx context.Context, dir string) string {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain=v1", "--branch").Output()
+	if err != nil {
+		return ""
+	}
+
+	return summarizeGitStatus(string(out))
+}
+
+// summarizeGitStatus condenses `git status --porcelain=v1 --branch` output to
+// the branch and counts of staged, modified and untracked files.
+func summarizeGitStatus(status string) string {
+	var (
+		branch                      string
+		staged, modified, untracked int
+	)
+
+	for line := range strings.SplitSeq(status, "\n") {
+		switch {
+		case strings.HasPrefix(line, "## "):
+			branch = strings.TrimPrefix(line, "## ")
+			branch = strings.TrimPrefix(branch, "No commits yet on ")
+
+			if i := strings.Index(branch, "..."); i >= 0 {
+				branch = branch[:i]
+			}
+		case strings.HasPrefix(line, "??"):
+			untracked++
+		case len(line) >= 2:
+			if line[0] != ' ' {
+				staged++
+			}
+
+			if line[1] != ' ' {
+				modified++
+			}
+		}
+	}
+
+	summary := "branch " + branch
+
+	var changes []string
+
+	for _, c := range []struct {
+		count int
+		label string
+	}{{staged, "staged"}, {modified, "modified"}, {untracked, "untracked"}} {
+		if c.count > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", c.count, c.label))
+		}
+	}
+
+	if len(changes) == 0 {
+		return summary + ", clean"
+	}
+
+	return summary + ", " + strings.Join(changes, ", ")
+}
+
+// truncate shortens s to at most n bytes without splitting a rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	cut := n - len("...")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + "..."
+}
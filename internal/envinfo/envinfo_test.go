@@ -0,0 +1,125 @@
+package envinfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSources(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseSources([]string{"shell", " Git "})
+	if err != nil || len(got) != 2 || got[0] != SourceShell || got[1] != SourceGit {
+		t.Errorf("ParseSources() = %v, %v", got, err)
+	}
+
+	if _, err := ParseSources([]string{"history"}); err == nil {
+		t.Error("ParseSources() expected error for unknown source")
+	}
+}
+
+func TestParseOSRelease(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "pretty name",
+			content: "NAME=\"Fedora Linux\"\nVERSION_ID=40\nPRETTY_NAME=\"Fedora Linux 40 (Workstation Edition)\"\n",
+			want:    "Fedora Linux 40 (Workstation Edition)",
+		},
+		{name: "name and version", content: "NAME=Alpine\nVERSION_ID='3.20'\n", want: "Alpine 3.20"},
+		{name: "empty", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := parseOSRelease(strings.NewReader(tt.content)); got != tt.want {
+				t.Errorf("parseOSRelease() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeGitStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{status: "## main...origin/main\n", want: "branch main, clean"},
+		{status: "## No commits yet on trunk\n?? a\n", want: "branch trunk, 1 untracked"},
+		{status: "## feature\nM  a\n M b\nMM c\n?? d\n", want: "branch feature, 2 staged, 2 modified, 1 untracked"},
+	}
+
+	for _, tt := range tests {
+		if got := summarizeGitStatus(tt.status); got != tt.want {
+			t.Errorf("summarizeGitStatus(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"go.mod", "main.go", ".env"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "cmd"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := summarizeDir(dir), "4 entries: cmd/, go.mod, main.go, ..."; got != want {
+		t.Errorf("summarizeDir() = %q, want %q", got, want)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	t.Run("only enabled sources", func(t *testing.T) {
+		t.Parallel()
+
+		facts := Collector{Sources: []Source{SourceDirectory}, Dir: dir}.Collect(context.Background())
+		if len(facts) != 2 || facts[0].String() != "Working directory: "+dir {
+			t.Errorf("Collect() = %v, want the directory facts only", facts)
+		}
+	})
+
+	t.Run("size cap", func(t *testing.T) {
+		t.Parallel()
+
+		facts := Collector{Sources: []Source{SourceDirectory}, Dir: dir, MaxBytes: len("Working directory: " + dir)}.Collect(context.Background())
+		if len(facts) != 1 {
+			t.Errorf("Collect() = %v, want 1 fact within the cap", facts)
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	// The cut falls inside "é", which must not be split
+	if got := truncate("héllo", 5); got != "h..." {
+		t.Errorf("truncate() = %q, want %q", got, "h...")
+	}
+
+	if got := truncate("short", 8); got != "short" {
+		t.Errorf("truncate() = %q, want %q", got, "short")
+	}
+}
@@ -0,0 +1,259 @@
+// Package history records queries and the commands suggested for them in a
+// JSON Lines file under $XDG_STATE_HOME/howto/, so past answers can be
+// searched and reused without calling a provider again.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrNotFound is returned by Get for unknown IDs.
+var ErrNotFound = errors.New("history entry not found")
+
+// maxLineSize bounds a single history line.
+const maxLineSize = 1 << 20
+
+// Entry is one recorded suggestion.
+type Entry struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	Query    string    `json:"query"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Command  string    `json:"command"`
+	// LatencyMS is how long the provider took to answer.
+	LatencyMS int64 `json:"latency_ms"`
+	// InputTokens and OutputTokens are the tokens the providers reported
+	// for the suggestion, and CostUSD their price; all are zero for cached
+	// answers and providers that report no usage.
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	CostUSD      float64 `json:"cost_usd,omitempty"`
+	// Executed is set when --exec ran the command; ExitCode is then its status.
+	Executed bool `json:"executed"`
+	ExitCode int  `json:"exit_code,omitempty"`
+}
+
+// UsageOnly reports whether e records only the tokens and cost of queries:
+// those that ended without a suggestion, or all of them when the history is
+// turned off.
+func (e Entry) UsageOnly() bool {
+	return e.Query == "" && e.Command == ""
+}
+
+// Path returns the location of the history file.
+func Path() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "howto", "history.jsonl"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".local", "state", "howto", "history.jsonl"), nil
+}
+
+// Store is a history file.
+type Store struct {
+	path string
+}
+
+// Open returns the store at path. The file is created on the first Append.
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Append records e, assigning the next ID and, if unset, the current time.
+// The file is locked from reading the last ID to writing e, so concurrent
+// howto processes never assign the same ID.
+func (s *Store) Append(e *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return errors.Wrap(err, "failed to create history directory")
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open history")
+	}
+
+	if err := s.append(f, e); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return errors.Wrap(f.Close(), "failed to write history")
+}
+
+func (s *Store) append(f *os.File, e *Entry) error {
+	if err := lock(f); err != nil {
+		return err
+	}
+
+	last, err := lastID(f)
+	if err != nil {
+		return err
+	}
+
+	e.ID = last + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode history entry")
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write history")
+	}
+
+	return nil
+}
+
+// tailSize is how much of the end of the file lastID reads at first.
+const tailSize = 16 * 1024
+
+// lastID returns the ID of the last entry of f, or 0 when it has none. It
+// reads the file from the end, only as far back as it must.
+func lastID(f *os.File) (int, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read history")
+	}
+
+	size := info.Size()
+
+	for n := min(int64(tailSize), size); ; n = min(2*n, size) {
+		tail := make([]byte, n)
+		if _, err := f.ReadAt(tail, size-n); err != nil && !errors.Is(err, io.EOF) {
+			return 0, errors.Wrap(err, "failed to read history")
+		}
+
+		lines := bytes.Split(tail, []byte("\n"))
+		if n < size {
+			lines = lines[1:] // the first line may be cut off
+		}
+
+		for i := len(lines) - 1; i >= 0; i-- {
+			var e Entry
+			if err := json.Unmarshal(lines[i], &e); err == nil && e.ID > 0 {
+				return e.ID, nil
+			}
+		}
+
+		if n == size {
+			return 0, nil
+		}
+	}
+}
+
+// List returns every entry, oldest first. A missing file is an empty
+// history; lines that cannot be parsed are skipped.
+func (s *Store) List() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to open history")
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil && e.ID > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read history")
+	}
+
+	return entries, nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id int) (Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+
+	return Entry{}, errors.Wrapf(ErrNotFound, "no entry %d", id)
+}
+
+// Filter selects entries.
+type Filter struct {
+	// Pattern matches the query or the command.
+	Pattern *regexp.Regexp
+	// Since excludes older entries.
+	Since time.Time
+}
+
+// Match reports whether e passes the filter; entries that only record usage
+// never do.
+func (f Filter) Match(e Entry) bool {
+	if e.UsageOnly() || (!f.Since.IsZero() && e.Time.Before(f.Since)) {
+		return false
+	}
+
+	return f.Pattern == nil || f.Pattern.MatchString(e.Query) || f.Pattern.MatchString(e.Command)
+}
+
+// ParseSince parses a --since value: a duration such as "36h" or "7d" back
+// from now, or a date (2006-01-02).
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := parseDays(value); ok {
+		return now.AddDate(0, 0, -days), nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, errors.Newf("invalid time %q (use e.g. 36h, 7d or 2006-01-02)", value)
+}
+
+var daysPattern = regexp.MustCompile(`^(\d+)d$`)
+
+func parseDays(value string) (int, bool) {
+	m := daysPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, false
+	}
+
+	days, err := strconv.Atoi(m[1])
+
+	return days, err == nil
+}
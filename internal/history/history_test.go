@@ -0,0 +1,160 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+
+	got, err := Path()
+	if want := filepath.Join("/state", "howto", "history.jsonl"); err != nil || got != want {
+		t.Errorf("Path() = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+	store := Open(path)
+
+	entries, err := store.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("List() on a missing file = %v, %v; want empty", entries, err)
+	}
+
+	for _, command := range []string{"ls -la", "du -sh *"} {
+		if err := store.Append(&Entry{Query: "q", Command: command}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// A corrupt line must not hide the rest of the history
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = f.WriteString("{not json\n")
+	_ = f.Close()
+
+	e := &Entry{Query: "q", Command: "df -h"}
+	if err := store.Append(e); err != nil || e.ID != 3 || e.Time.IsZero() {
+		t.Fatalf("Append() = %+v, %v; want ID 3 and a time", e, err)
+	}
+
+	got, err := store.Get(2)
+	if err != nil || got.Command != "du -sh *" {
+		t.Errorf("Get(2) = %+v, %v", got, err)
+	}
+
+	if _, err := store.Get(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(42) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	const writers, appends = 8, 20
+
+	var wg sync.WaitGroup
+
+	for range writers {
+		wg.Go(func() {
+			// A store per writer, as separate howto processes have
+			store := Open(path)
+
+			for range appends {
+				if err := store.Append(&Entry{Query: "q", Command: strings.Repeat("x", 4000)}); err != nil {
+					t.Errorf("Append() error = %v", err)
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	entries, err := Open(path).List()
+	if err != nil || len(entries) != writers*appends {
+		t.Fatalf("List() = %d entries, %v; want %d", len(entries), err, writers*appends)
+	}
+
+	for i, e := range entries {
+		if e.ID != i+1 {
+			t.Fatalf("entry %d has ID %d; IDs must be unique and in order", i+1, e.ID)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	e := Entry{Time: now.Add(-time.Hour), Query: "find big files", Command: "du -ah . | sort -rh"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty", filter: Filter{}, want: true},
+		{name: "query match", filter: Filter{Pattern: regexp.MustCompile(`(?i)BIG`)}, want: true},
+		{name: "command match", filter: Filter{Pattern: regexp.MustCompile(`sort -rh`)}, want: true},
+		{name: "no match", filter: Filter{Pattern: regexp.MustCompile(`docker`)}, want: false},
+		{name: "recent enough", filter: Filter{Since: now.Add(-2 * time.Hour)}, want: true},
+		{name: "too old", filter: Filter{Since: now.Add(-time.Minute)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.filter.Match(e); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("usage only", func(t *testing.T) {
+		t.Parallel()
+
+		if (Filter{}).Match(Entry{Time: now, Provider: "OpenAI", CostUSD: 0.01}) {
+			t.Error("Match() = true for an entry without query or command")
+		}
+	})
+}
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "36h", want: now.Add(-36 * time.Hour)},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "2025-03-01", want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "last week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
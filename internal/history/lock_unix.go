@@ -0,0 +1,21 @@
+//go:build !windows
+
+package history
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+)
+
+// lock takes an exclusive lock on f, waiting for other holders. Closing f
+// releases it.
+func lock(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if !errors.Is(err, syscall.EINTR) {
+			return errors.Wrap(err, "failed to lock history")
+		}
+	}
+}
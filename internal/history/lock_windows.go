@@ -0,0 +1,29 @@
+//go:build windows
+
+package history
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+// lockfileExclusiveLock is LOCKFILE_EXCLUSIVE_LOCK.
+const lockfileExclusiveLock = 0x2
+
+// lock takes an exclusive lock on f, waiting for other holders. Closing f
+// releases it.
+func lock(f *os.File) error {
+	var overlapped syscall.Overlapped
+
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return errors.Wrap(err, "failed to lock history")
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package output writes suggestions in machine-readable formats for editor
+// integrations and scripts.
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+	"github.com/techquestsdev/howto/internal/risk"
+)
+
+// Format selects how a suggestion is written.
+type Format string
+
+const (
+	// Text is the human-readable default.
+	Text Format = "text"
+	// JSON writes a single Result object once the suggestion is ready.
+	JSON Format = "json"
+	// NDJSON writes one Event per line: the response tokens as they stream
+	// in, then the result or an error.
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the format called name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case Text, JSON, NDJSON:
+		return f, nil
+	}
+
+	return "", errors.Newf("unknown output format %q (want text, json or ndjson)", name)
+}
+
+// Result describes a suggested command.
+type Result struct {
+	Query    string `json:"query"`
+	Command  string `json:"command"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Explanation is set when one was requested and could be parsed.
+	Explanation *prompt.Explanation `json:"explanation"`
+	Risk        Risk                `json:"risk"`
+	LatencyMS   int64               `json:"latency_ms"`
+	// Usage is nil when the provider did not report token counts; CostUSD is
+	// its price, zero for models without one.
+	Usage   *provider.Usage `json:"usage"`
+	CostUSD float64         `json:"cost_usd"`
+	Cached  bool            `json:"cached"`
+}
+
+// Risk is the outcome of risk analysis of a command.
+type Risk struct {
+	// Level is the highest level found, "none" without findings.
+	Level    string    `json:"level"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is a destructive pattern found in a command.
+type Finding struct {
+	Level  string `json:"level"`
+	Reason string `json:"reason"`
+}
+
+// AnalyzeRisk rates command.
+func AnalyzeRisk(command string) Risk {
+	findings := risk.Analyze(command)
+
+	r := Risk{Level: risk.Highest(findings).String(), Findings: make([]Finding, 0, len(findings))}
+	for _, f := range findings {
+		r.Findings = append(r.Findings, Finding{Level: f.Level.String(), Reason: f.Reason})
+	}
+
+	return r
+}
+
+// Event types of NDJSON output.
+const (
+	EventToken  = "token"
+	EventResult = "result"
+	EventError  = "error"
+)
+
+// Event is one line of NDJSON output.
+type Event struct {
+	Type   string  `json:"type"`
+	Text   string  `json:"text,omitempty"`
+	Result *Result `json:"result,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Writer writes suggestions in a machine-readable format. Writing text is a
+// no-op; the caller prints it.
+type Writer struct {
+	format  Format
+	encoder *json.Encoder
+}
+
+// NewWriter returns a writer of format to w.
+func NewWriter(w io.Writer, format Format) *Writer {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	if format == JSON {
+		encoder.SetIndent("", "  ")
+	}
+
+	return &Writer{format: format, encoder: encoder}
+}
+
+// Machine reports whether the format is meant for programs rather than
+// people.
+func (w *Writer) Machine() bool {
+	return w.format == JSON || w.format == NDJSON
+}
+
+// Token writes a streamed response token; only NDJSON has a place for it.
+func (w *Writer) Token(text string) {
+	if w.format == NDJSON {
+		_ = w.encoder.Encode(Event{Type: EventToken, Text: text})
+	}
+}
+
+// Result writes the suggestion.
+func (w *Writer) Result(r Result) error {
+	if r.Risk.Findings == nil {
+		r.Risk.Findings = []Finding{}
+	}
+
+	var err error
+
+	switch w.format {
+	case JSON:
+		err = w.encoder.Encode(r)
+	case NDJSON:
+		err = w.encoder.Encode(Event{Type: EventResult, Result: &r})
+	case Text:
+	}
+
+	return errors.Wrap(err, "failed to write result")
+}
+
+// Error reports a failure. JSON leaves it to the exit status and stderr, so
+// stdout is either a result or empty; NDJSON ends the stream with an error
+// event.
+func (w *Writer) Error(failure error) {
+	if w.format == NDJSON {
+		_ = w.encoder.Encode(Event{Type: EventError, Error: failure.Error()})
+	}
+}
@@ -0,0 +1,135 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/techquestsdev/howto/internal/prompt"
+	"github.com/techquestsdev/howto/internal/provider"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// golden compares got with testdata/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]Format{"text": Text, "JSON": JSON, "ndjson": NDJSON} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseFormat("yaml"); err == nil {
+		t.Error("ParseFormat(yaml) succeeded")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	explained := Result{
+		Query:    "find go files changed this week",
+		Command:  "find . -name '*.go' -mtime -7",
+		Provider: "OpenAI",
+		Model:    "gpt-4o-mini",
+		Explanation: &prompt.Explanation{
+			Summary: "Lists Go files modified in the last seven days.",
+			Stages: []prompt.Stage{{
+				Command:     "find . -name '*.go' -mtime -7",
+				Description: "Search the current directory tree",
+				Parts: []prompt.Part{
+					{Token: "-name '*.go'", Description: "Only files ending in .go"},
+					{Token: "-mtime -7", Description: "Modified less than 7 days ago"},
+				},
+			}},
+		},
+		Risk:      AnalyzeRisk("find . -name '*.go' -mtime -7"),
+		LatencyMS: 812,
+		Usage:     &provider.Usage{InputTokens: 164, OutputTokens: 12},
+		CostUSD:   0.000032,
+	}
+
+	risky := Result{
+		Query:    "clean build output",
+		Command:  "rm -rf build",
+		Provider: "Ollama",
+		Model:    "llama3.2",
+		Risk:     AnalyzeRisk("rm -rf build"),
+		Cached:   true,
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		write  func(w *Writer) error
+	}{
+		{"explained.json", JSON, func(w *Writer) error { return w.Result(explained) }},
+		{"risky.json", JSON, func(w *Writer) error { return w.Result(risky) }},
+		{"stream.ndjson", NDJSON, func(w *Writer) error {
+			for _, token := range []string{"rm", " -rf", " build"} {
+				w.Token(token)
+			}
+
+			return w.Result(risky)
+		}},
+		{"error.ndjson", NDJSON, func(w *Writer) error {
+			w.Token("ls")
+			w.Error(errors.New("every provider failed (OpenAI, Ollama)"))
+
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := tt.write(NewWriter(&buf, tt.format)); err != nil {
+				t.Fatal(err)
+			}
+
+			golden(t, tt.name, buf.Bytes())
+		})
+	}
+}
+
+func TestWriterText(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	w := NewWriter(&buf, Text)
+	w.Token("ls")
+	w.Error(errors.New("failed"))
+
+	if err := w.Result(Result{Command: "ls"}); err != nil || buf.Len() != 0 || w.Machine() {
+		t.Errorf("text Writer wrote %q, %v", buf.String(), err)
+	}
+}
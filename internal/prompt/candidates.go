@@ -0,0 +1,59 @@
+package prompt
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Candidate is one of several alternative commands for a query.
+type Candidate struct {
+	Command   string `json:"command"`
+	Rationale string `json:"rationale"`
+}
+
+// Candidates creates the prompt asking for count alternative commands, with
+// the system prompt rendered by the CandidatesTemplate of opts.
+func Candidates(query string, count int, opts Options) (Prompt, error) {
+	data := newData(query, opts)
+	data.Count = count
+
+	system, err := render(opts, CandidatesTemplate, data)
+	if err != nil {
+		return Prompt{}, err
+	}
+
+	return Prompt{System: system, User: query}, nil
+}
+
+// ParseCandidates extracts the candidate commands from a model response. The
+// commands are sanitized and empty ones dropped.
+func ParseCandidates(response string) ([]Candidate, error) {
+	array, ok := jsonSpan(response, "[", "]")
+	if !ok {
+		return nil, errors.New("response contains no JSON array")
+	}
+
+	var parsed []Candidate
+	if err := json.Unmarshal([]byte(array), &parsed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse candidates")
+	}
+
+	candidates := parsed[:0]
+
+	for _, c := range parsed {
+		c.Command = SanitizeCommand(c.Command)
+		c.Rationale = strings.TrimSpace(c.Rationale)
+
+		if c.Command != "" {
+			candidates = append(candidates, c)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.New("response contains no commands")
+	}
+
+	return candidates, nil
+}
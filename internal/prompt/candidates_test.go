@@ -0,0 +1,75 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCandidates(t *testing.T) {
+	t.Parallel()
+
+	got, err := Candidates("list files", 3, Options{Instructions: []string{"Prefer fd over find"}})
+	if err != nil {
+		t.Fatalf("Candidates() error = %v", err)
+	}
+
+	for _, want := range []string{"Suggest 3 different commands", "- Prefer fd over find\n", `"rationale"`} {
+		if !strings.Contains(got.System, want) {
+			t.Errorf("Candidates() system prompt = %q, want to contain %q", got.System, want)
+		}
+	}
+
+	if got.User != "list files" {
+		t.Errorf("Candidates() user message = %q, want the query", got.User)
+	}
+}
+
+func TestParseCandidates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "plain json",
+			response: `[{"command":"fd -e go","rationale":"needs fd"},{"command":"find . -name '*.go'","rationale":"POSIX"}]`,
+			want:     []string{"fd -e go", "find . -name '*.go'"},
+		},
+		{
+			name:     "fenced with backticks in commands",
+			response: "```json\n[{\"command\":\"`ls -la`\",\"rationale\":\"\"}]\n```",
+			want:     []string{"ls -la"},
+		},
+		{
+			name:     "empty commands dropped",
+			response: `[{"command":"  ","rationale":"x"},{"command":"ls","rationale":"y"}]`,
+			want:     []string{"ls"},
+		},
+		{name: "no json", response: "ls -la", wantErr: true},
+		{name: "only empty commands", response: `[{"command":""}]`, wantErr: true},
+		{name: "invalid json", response: `[{"command":}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseCandidates(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCandidates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			commands := make([]string, 0, len(got))
+			for _, c := range got {
+				commands = append(commands, c.Command)
+			}
+
+			if strings.Join(commands, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ParseCandidates() = %q, want %q", commands, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package prompt
+
+// System creates the system prompt of a refinement conversation, in which the
+// user states a task and then asks for changes to the suggested command. The
+// ChatTemplate of opts renders it.
+func System(opts Options) (string, error) {
+	return render(opts, ChatTemplate, newData("", opts))
+}
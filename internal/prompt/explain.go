@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Explanation is a structured breakdown of a shell command.
+type Explanation struct {
+	Summary string  `json:"summary"`
+	Stages  []Stage `json:"stages"`
+}
+
+// Stage is one command of a pipeline or command list.
+type Stage struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	Parts       []Part `json:"parts"`
+}
+
+// Part is a flag, option or argument of a stage.
+type Part struct {
+	Token       string `json:"token"`
+	Description string `json:"description"`
+}
+
+// Explain creates the prompt asking for a structured explanation of command.
+func Explain(command string) Prompt {
+	system := fmt.Sprintf(`You are a command line expert explaining a shell command to a junior engineer.
+The user sends the command to explain.
+
+Instructions:
+- Split the command into its stages: every command in a pipeline (|) or list (&&, ||, ;)
+- For each stage, explain every flag, option and argument in a few words
+- Keep a flag together with its value (e.g. "-name '*.go'")
+- Mention anything destructive or surprising in the relevant description
+- The command should be explained as it behaves on %s
+- Respond with JSON only, no markdown, in exactly this shape:
+{"summary": "<one sentence>", "stages": [{"command": "<stage>", "description": "<what it does>", "parts": [{"token": "<flag or argument>", "description": "<what it means>"}]}]}
+`, userOS())
+
+	return Prompt{System: system, User: command}
+}
+
+// ParseExplanation extracts the JSON explanation from a model response,
+// tolerating markdown fences or prose around the object.
+func ParseExplanation(response string) (*Explanation, error) {
+	object, ok := jsonSpan(response, "{", "}")
+	if !ok {
+		return nil, errors.New("response contains no JSON object")
+	}
+
+	var explanation Explanation
+	if err := json.Unmarshal([]byte(object), &explanation); err != nil {
+		return nil, errors.Wrap(err, "failed to parse explanation")
+	}
+
+	if len(explanation.Stages) == 0 {
+		return nil, errors.New("explanation has no stages")
+	}
+
+	return &explanation, nil
+}
+
+// jsonSpan returns the text from the first open to the last closing delimiter,
+// which strips markdown fences and prose models like to add around JSON.
+func jsonSpan(response, open, closing string) (string, bool) {
+	start := strings.Index(response, open)
+	end := strings.LastIndex(response, closing)
+
+	if start < 0 || end < start {
+		return "", false
+	}
+
+	return response[start : end+1], true
+}
@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	got := Explain("ls -la | grep foo")
+
+	for _, want := range []string{`"stages"`, userOS()} {
+		if !strings.Contains(got.System, want) {
+			t.Errorf("Explain() system prompt = %q, want to contain %q", got.System, want)
+		}
+	}
+
+	if got.User != "ls -la | grep foo" {
+		t.Errorf("Explain() user message = %q, want the command", got.User)
+	}
+}
+
+func TestParseExplanation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		response   string
+		wantStages int
+		wantErr    bool
+	}{
+		{
+			name:       "plain json",
+			response:   `{"summary":"Lists files","stages":[{"command":"ls -la","description":"list","parts":[{"token":"-la","description":"long, all"}]}]}`,
+			wantStages: 1,
+		},
+		{
+			name:       "markdown fence",
+			response:   "```json\n{\"summary\":\"s\",\"stages\":[{\"command\":\"a\"},{\"command\":\"b\"}]}\n```",
+			wantStages: 2,
+		},
+		{name: "no json", response: "ls lists files", wantErr: true},
+		{name: "invalid json", response: `{"summary": }`, wantErr: true},
+		{name: "no stages", response: `{"summary":"s","stages":[]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseExplanation(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExplanation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && len(got.Stages) != tt.wantStages {
+				t.Errorf("ParseExplanation() stages = %d, want %d", len(got.Stages), tt.wantStages)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Failure is a suggested command that exited with an error.
+type Failure struct {
+	Command  string
+	ExitCode int
+	// Stderr is the end of what the command printed to stderr.
+	Stderr string
+}
+
+// Fix creates the prompt asking for a corrected command after the attempts
+// for query failed, oldest first. The FixTemplate of opts renders the system
+// prompt.
+func Fix(query string, failures []Failure, opts Options) (Prompt, error) {
+	system, err := render(opts, FixTemplate, newData(query, opts))
+	if err != nil {
+		return Prompt{}, err
+	}
+
+	var attempts strings.Builder
+
+	for i, f := range failures {
+		stderr := strings.TrimSpace(f.Stderr)
+		if stderr == "" {
+			stderr = "(no error output)"
+		}
+
+		_, _ = fmt.Fprintf(&attempts, "Attempt %d:\n%s\nExited with status %d and printed:\n%s\n\n", i+1, f.Command, f.ExitCode, stderr)
+	}
+
+	user := fmt.Sprintf("%s\n\nThe commands suggested so far failed:\n\n%s", query, strings.TrimSpace(attempts.String()))
+
+	return Prompt{System: system, User: user}, nil
+}
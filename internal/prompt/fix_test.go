@@ -0,0 +1,32 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFix(t *testing.T) {
+	t.Parallel()
+
+	got, err := Fix("list files by size", []Failure{
+		{Command: "exa -l --sort size", ExitCode: 127, Stderr: "sh: exa: not found\n"},
+		{Command: "ls -lS --blocks", ExitCode: 2},
+	}, Options{Instructions: []string{"Prefer long flags"}})
+	if err != nil {
+		t.Fatalf("Fix() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"list files by size\n\n",
+		"Attempt 1:\nexa -l --sort size\nExited with status 127 and printed:\nsh: exa: not found\n",
+		"Attempt 2:\nls -lS --blocks\nExited with status 2 and printed:\n(no error output)",
+	} {
+		if !strings.Contains(got.User, want) {
+			t.Errorf("Fix() user message = %q, want to contain %q", got.User, want)
+		}
+	}
+
+	if !strings.Contains(got.System, "- Prefer long flags\n") {
+		t.Errorf("Fix() system prompt = %q, want the extra instruction", got.System)
+	}
+}
@@ -1,26 +1,227 @@
 package prompt
 
 import (
-	"fmt"
+	_ "embed"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"text/template"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cockroachdb/errors"
 )
 
+// Options customizes the generated prompt.
+type Options struct {
+	// Instructions are extra rules appended to the instruction list.
+	Instructions []string
+	// Environment describes the user's machine, one fact per entry, e.g.
+	// "Shell: zsh".
+	Environment []string
+	// Shell is the user's shell, if known.
+	Shell string
+	// Examples are tasks paired with the commands preferred for them.
+	Examples []Example
+	// Template renders the prompts; nil means DefaultTemplate. Its named
+	// templates render the prompts other than the command prompt.
+	Template *template.Template
+}
+
+// Example is a task and the command preferred for it, shown to the model so
+// it follows house conventions.
+type Example struct {
+	Query   string `toml:"query"`
+	Command string `toml:"command"`
+}
+
+// Prompt is a system prompt with the user message it frames. Rules go in
+// the system prompt, where models follow them most reliably.
+type Prompt struct {
+	System string
+	User   string
+}
+
+// Data is what prompt templates are executed with.
+type Data struct {
+	// Query is the user's task. It is sent as the user message as well, so
+	// templates need not include it; templates written when the whole prompt
+	// was one message keep working.
+	Query string
+	// OS is the operating system, e.g. "Linux" or "macOS".
+	OS    string
+	Shell string
+	// Context holds the environment facts, e.g. "Shell: zsh".
+	Context      []string
+	Instructions []string
+	Examples     []Example
+	// Count is the number of alternatives the "candidates" template asks for.
+	Count int
+}
+
+// Named templates of the other prompts, which an override may redefine.
+const (
+	// CandidatesTemplate renders the system prompt asking for alternatives.
+	CandidatesTemplate = "candidates"
+	// FixTemplate renders the system prompt asking for a corrected command.
+	FixTemplate = "fix"
+	// ChatTemplate renders the system prompt of a refinement conversation.
+	ChatTemplate = "chat"
+)
+
+// sharedTemplates are the named templates of DefaultTemplate an override
+// inherits unless it defines them itself.
+var sharedTemplates = []string{"environment", "rules", CandidatesTemplate, FixTemplate, ChatTemplate}
+
+//go:embed prompt.tmpl
+var defaultTemplateText string
+
+// DefaultTemplate is the built-in template of the system prompt, along with
+// the named templates of the other prompts.
+var DefaultTemplate = template.Must(template.New("prompt").Parse(defaultTemplateText))
+
+// DefaultTemplateText returns the source of DefaultTemplate, a starting point
+// for an override.
+func DefaultTemplateText() string {
+	return defaultTemplateText
+}
+
 // Generate creates the prompt for the AI provider.
-func Generate(query string) string {
-	return fmt.Sprintf(`You are a command line assistant that helps users with shell commands.
-User wants assistance with the following task:
-
-%s
-
-Instructions:
-- Respond with a single command that achieves the desired result
-- The command should be suitable for %s operating system
-- Output ONLY the command, without any explanation
-- Do not include any quotes, backticks, or markdown formatting
-- If the task requires multiple commands, chain them with && or ;
-- If you're unsure, provide the most common/standard approach
-`, query, userOS())
+func Generate(query string) Prompt {
+	// The default template renders without options
+	p, _ := Build(query, Options{})
+
+	return p
+}
+
+// Build creates the prompt for the AI provider with the given options: the
+// template renders the system prompt and the query is the user message.
+func Build(query string, opts Options) (Prompt, error) {
+	system, err := render(opts, "", newData(query, opts))
+	if err != nil {
+		return Prompt{}, err
+	}
+
+	return Prompt{System: system, User: query}, nil
+}
+
+// newData returns the template data for query.
+func newData(query string, opts Options) Data {
+	return Data{
+		Query:        query,
+		OS:           userOS(),
+		Shell:        opts.Shell,
+		Context:      opts.Environment,
+		Instructions: opts.Instructions,
+		Examples:     opts.Examples,
+	}
+}
+
+// render executes the template of opts named name, or the template itself
+// when name is "". A template without the named one falls back to
+// DefaultTemplate.
+func render(opts Options, name string, data Data) (string, error) {
+	tmpl := opts.Template
+	if tmpl == nil || (name != "" && tmpl.Lookup(name) == nil) {
+		tmpl = DefaultTemplate
+	}
+
+	if name == "" {
+		name = tmpl.Name()
+	}
+
+	var sb strings.Builder
+	if err := tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render %s prompt", name)
+	}
+
+	return sb.String(), nil
+}
+
+// LoadTemplate parses the prompt template at path, or returns DefaultTemplate
+// when there is no file. Named templates the file does not define, e.g.
+// "fix", are taken from DefaultTemplate, so they use the file's "environment"
+// and "rules" if it redefines those. Every prompt is tried on sample data so
+// mistakes show up before a query is sent.
+func LoadTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return DefaultTemplate, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to read prompt template")
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid prompt template")
+	}
+
+	for _, name := range sharedTemplates {
+		if tmpl.Lookup(name) != nil {
+			continue
+		}
+
+		if _, err := tmpl.AddParseTree(name, DefaultTemplate.Lookup(name).Tree); err != nil {
+			return nil, errors.Wrap(err, "invalid prompt template")
+		}
+	}
+
+	sample := Options{
+		Environment:  []string{"Shell: bash"},
+		Shell:        "bash",
+		Instructions: []string{"Prefer fd over find"},
+		Examples:     []Example{{Query: "list files", Command: "ls"}},
+		Template:     tmpl,
+	}
+	if _, err := Build("list files", sample); err != nil {
+		return nil, err
+	}
+
+	if _, err := Candidates("list files", 3, sample); err != nil {
+		return nil, err
+	}
+
+	if _, err := Fix("list files", []Failure{{Command: "ls -z", ExitCode: 2}}, sample); err != nil {
+		return nil, err
+	}
+
+	if _, err := System(sample); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// LoadExamples reads few-shot examples from the TOML file at path, written
+// as [[example]] tables with query and command keys. A missing file yields
+// no examples.
+func LoadExamples(path string) ([]Example, error) {
+	var file struct {
+		Example []Example `toml:"example"`
+	}
+
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, errors.Newf("%s: unknown key %s", path, undecoded[0])
+	}
+
+	for i, e := range file.Example {
+		if strings.TrimSpace(e.Query) == "" || strings.TrimSpace(e.Command) == "" {
+			return nil, errors.Newf("%s: example %d needs a query and a command", path, i+1)
+		}
+	}
+
+	return file.Example, nil
 }
 
 // SanitizeCommand cleans up the AI response to extract just the command.
@@ -59,6 +260,23 @@ func SanitizeCommand(cmd string) string {
 	return strings.TrimSpace(cmd)
 }
 
+// NormalizeCommand reduces a sanitized command to a form in which spelling
+// differences that do not change what it does compare equal: whitespace is
+// collapsed, a trailing semicolon dropped and double-quoted words without
+// anything to expand are single-quoted.
+func NormalizeCommand(cmd string) string {
+	words := strings.Fields(SanitizeCommand(cmd))
+
+	for i, word := range words {
+		if len(word) >= 2 && word[0] == '"' && word[len(word)-1] == '"' &&
+			!strings.ContainsAny(word[1:len(word)-1], "\\\"'$`") {
+			words[i] = "'" + word[1:len(word)-1] + "'"
+		}
+	}
+
+	return strings.TrimRight(strings.Join(words, " "), "; ")
+}
+
 func userOS() string {
 	switch runtime.GOOS {
 	case "darwin":
@@ -1,6 +1,8 @@
 package prompt
 
 import (
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -20,7 +22,6 @@ func TestGenerate(t *testing.T) {
 			query:  "list files",
 			wantOS: expectedOS(),
 			contains: []string{
-				"list files",
 				"single command",
 				expectedOS(),
 			},
@@ -30,7 +31,6 @@ func TestGenerate(t *testing.T) {
 			query:  "find all go files larger than 1MB",
 			wantOS: expectedOS(),
 			contains: []string{
-				"find all go files larger than 1MB",
 				"shell commands",
 			},
 		},
@@ -49,10 +49,13 @@ func TestGenerate(t *testing.T) {
 			t.Parallel()
 
 			got := Generate(tt.query)
+			if got.User != tt.query {
+				t.Errorf("Generate() user message = %q, want %q", got.User, tt.query)
+			}
 
 			for _, want := range tt.contains {
-				if !strings.Contains(got, want) {
-					t.Errorf("Generate() = %q, want to contain %q", got, want)
+				if !strings.Contains(got.System, want) {
+					t.Errorf("Generate() system prompt = %q, want to contain %q", got.System, want)
 				}
 			}
 		})
@@ -151,6 +154,28 @@ func TestSanitizeCommand(t *testing.T) {
 	}
 }
 
+func TestNormalizeCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"ls -la", "ls  -la", true},
+		{"ls -la", "`ls -la`", true},
+		{"find . -name \"*.go\"", "find . -name '*.go'", true},
+		{"df -h;", "df -h", true},
+		{"echo \"$HOME\"", "echo '$HOME'", false},
+		{"ls -la", "ls -l", false},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCommand(tt.a) == NormalizeCommand(tt.b); got != tt.equal {
+			t.Errorf("NormalizeCommand(%q) == NormalizeCommand(%q) is %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
+
 func TestUserOS(t *testing.T) {
 	t.Parallel()
 
@@ -189,3 +214,242 @@ func expectedOS() string {
 		return runtime.GOOS
 	}
 }
+
+// build renders the prompt for query, failing the test on errors.
+func build(t *testing.T, query string, opts Options) Prompt {
+	t.Helper()
+
+	got, err := Build(query, opts)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	return got
+}
+
+func TestBuildInstructions(t *testing.T) {
+	t.Parallel()
+
+	got := build(t, "list files", Options{Instructions: []string{"Prefer fd over find", "Use long flags"}})
+
+	for _, want := range []string{"- Prefer fd over find\n", "- Use long flags\n"} {
+		if !strings.Contains(got.System, want) {
+			t.Errorf("Build() system prompt = %q, want to contain %q", got.System, want)
+		}
+	}
+
+	if got.User != "list files" || strings.Contains(got.System, "list files") {
+		t.Errorf("Build() = %+v, want the query as the user message only", got)
+	}
+
+	if build(t, "q", Options{}) != Generate("q") {
+		t.Error("Build() with empty options should match Generate()")
+	}
+}
+
+func TestBuildEnvironment(t *testing.T) {
+	t.Parallel()
+
+	got := build(t, "list files", Options{Environment: []string{"Shell: fish", "Distribution: Fedora Linux 40"}})
+
+	for _, want := range []string{"Environment:\n- Shell: fish\n- Distribution: Fedora Linux 40\n\nInstructions:", "tools of the environment above"} {
+		if !strings.Contains(got.System, want) {
+			t.Errorf("Build() system prompt = %q, want to contain %q", got.System, want)
+		}
+	}
+
+	if strings.Contains(Generate("list files").System, "Environment:") {
+		t.Error("Generate() should not contain an environment section")
+	}
+}
+
+func TestBuildExamples(t *testing.T) {
+	t.Parallel()
+
+	examples := []Example{{Query: "find go files", Command: "fd -e go"}, {Query: "search for TODO", Command: "rg TODO"}}
+	got := build(t, "list files", Options{Examples: examples})
+
+	want := "Examples of tasks and the commands preferred for them:\n" +
+		"Task: find go files\nCommand: fd -e go\nTask: search for TODO\nCommand: rg TODO\n\nInstructions:"
+	if !strings.Contains(got.System, want) || !strings.Contains(got.System, "- Follow the conventions of the examples above\n") {
+		t.Errorf("Build() system prompt = %q, want the examples", got.System)
+	}
+
+	if system, err := System(Options{Examples: examples}); err != nil || !strings.Contains(system, "Command: rg TODO") {
+		t.Errorf("System() = %q, %v; want the examples", system, err)
+	}
+}
+
+func TestLoadTemplate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl, err := LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl"))
+		if err != nil || tmpl != DefaultTemplate {
+			t.Errorf("LoadTemplate() = %v, %v; want the default template", tmpl, err)
+		}
+	})
+
+	t.Run("override", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl, err := LoadTemplate(writeFile(t, "house.tmpl", "{{.Shell}} on {{.OS}}:{{range .Examples}} [{{.Command}}]{{end}}"))
+		if err != nil {
+			t.Fatalf("LoadTemplate() error = %v", err)
+		}
+
+		got := build(t, "list files", Options{Shell: "fish", Examples: []Example{{Query: "q", Command: "fd"}}, Template: tmpl})
+		if want := (Prompt{System: "fish on " + expectedOS() + ": [fd]", User: "list files"}); got != want {
+			t.Errorf("Build() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("single message template", func(t *testing.T) {
+		t.Parallel()
+
+		// Templates written before the query moved to the user message
+		// render it with .Query.
+		text := "User wants assistance with the following task:\n\n{{.Query}}\n\n" +
+			"{{range .Examples}}Task: {{.Query}}\nCommand: {{.Command}}\n{{end}}" +
+			"- The command should be suitable for {{.OS}} operating system\n"
+
+		tmpl, err := LoadTemplate(writeFile(t, "prompt.tmpl", text))
+		if err != nil {
+			t.Fatalf("LoadTemplate() error = %v", err)
+		}
+
+		got := build(t, "list files", Options{Examples: []Example{{Query: "q", Command: "fd"}}, Template: tmpl})
+		want := Prompt{
+			System: "User wants assistance with the following task:\n\nlist files\n\nTask: q\nCommand: fd\n" +
+				"- The command should be suitable for " + expectedOS() + " operating system\n",
+			User: "list files",
+		}
+
+		if got != want {
+			t.Errorf("Build() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("other prompts", func(t *testing.T) {
+		t.Parallel()
+
+		// Redefining "rules" reaches every prompt; "fix" is replaced outright
+		text := "{{.Shell}}\n" +
+			`{{define "rules"}}- House rule{{end}}` +
+			`{{define "fix"}}Fix it on {{.OS}}{{end}}`
+
+		tmpl, err := LoadTemplate(writeFile(t, "prompt.tmpl", text))
+		if err != nil {
+			t.Fatalf("LoadTemplate() error = %v", err)
+		}
+
+		opts := Options{Template: tmpl}
+
+		candidates, err := Candidates("list files", 2, opts)
+		if err != nil || !strings.Contains(candidates.System, "Suggest 2 different commands") ||
+			!strings.Contains(candidates.System, "- House rule") {
+			t.Errorf("Candidates() = %q, %v; want the built-in prompt with the house rule", candidates.System, err)
+		}
+
+		if fix, err := Fix("list files", nil, opts); err != nil || fix.System != "Fix it on "+expectedOS() {
+			t.Errorf("Fix() system prompt = %q, %v; want the override", fix.System, err)
+		}
+
+		if system, err := System(opts); err != nil || !strings.Contains(system, "- House rule") {
+			t.Errorf("System() = %q, %v; want the house rule", system, err)
+		}
+	})
+
+	for name, text := range map[string]string{
+		"syntax error":              "{{.Shell",
+		"unknown field":             "{{.Question}}",
+		"unknown field in a prompt": `{{.Shell}}{{define "chat"}}{{.Question}}{{end}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := LoadTemplate(writeFile(t, "prompt.tmpl", text)); err == nil {
+				t.Errorf("LoadTemplate(%q) succeeded", text)
+			}
+		})
+	}
+}
+
+func TestDefaultTemplateText(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := LoadTemplate(writeFile(t, "prompt.tmpl", DefaultTemplateText()))
+	if err != nil {
+		t.Fatalf("LoadTemplate() error = %v", err)
+	}
+
+	opts := Options{Environment: []string{"Shell: zsh"}, Examples: []Example{{Query: "q", Command: "c"}}}
+	copied := Options{Environment: opts.Environment, Examples: opts.Examples, Template: tmpl}
+
+	if got, want := build(t, "list", copied), build(t, "list", opts); got != want {
+		t.Errorf("a copy of the default template renders %+v, want %+v", got, want)
+	}
+
+	got, _ := Candidates("list", 3, copied)
+	if want, _ := Candidates("list", 3, opts); got != want {
+		t.Errorf("a copy of the default template renders candidates %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadExamples(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, "examples.toml", `
+[[example]]
+query = "find go files"
+command = "fd -e go"
+
+[[example]]
+query = "search for TODO"
+command = "rg TODO"
+`)
+
+	examples, err := LoadExamples(path)
+	if err != nil || len(examples) != 2 || examples[1] != (Example{Query: "search for TODO", Command: "rg TODO"}) {
+		t.Errorf("LoadExamples() = %+v, %v", examples, err)
+	}
+
+	if examples, err := LoadExamples(filepath.Join(t.TempDir(), "missing.toml")); err != nil || examples != nil {
+		t.Errorf("LoadExamples() of a missing file = %+v, %v; want none", examples, err)
+	}
+
+	for _, content := range []string{"[[example]]\nquery = \"x\"\n", "[[example]]\nquery = \"x\"\ncmd = \"y\"\n"} {
+		if _, err := LoadExamples(writeFile(t, "bad.toml", content)); err == nil {
+			t.Errorf("LoadExamples(%q) succeeded", content)
+		}
+	}
+}
+
+// writeFile writes content to name in a new temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSystem(t *testing.T) {
+	t.Parallel()
+
+	got, err := System(Options{Environment: []string{"Shell: zsh"}, Instructions: []string{"Prefer fd over find"}})
+	if err != nil {
+		t.Fatalf("System() error = %v", err)
+	}
+
+	for _, want := range []string{"refines your answer", "- Shell: zsh\n", "- Prefer fd over find\n", expectedOS()} {
+		if !strings.Contains(got, want) {
+			t.Errorf("System() = %q, want to contain %q", got, want)
+		}
+	}
+}
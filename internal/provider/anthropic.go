@@ -4,18 +4,40 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"io"
 	"net/http"
+	"strings"
 
 	pkgerrors "github.com/cockroachdb/errors"
 )
 
+// anthropicVersion is the API version sent with every Anthropic request.
+const anthropicVersion = "2023-06-01"
+
+// Anthropic is the Claude Messages API provider.
+var Anthropic = &Provider{
+	Name:         "Anthropic",
+	Endpoint:     "https://api.anthropic.com/v1/messages",
+	DefaultModel: "claude-sonnet-4-20250514",
+	EnvVar:       "ANTHROPIC_API_KEY",
+	AuthType:     AuthAPIKey,
+	Priority:     20,
+	Backend:      anthropicBackend{},
+}
+
+func init() {
+	Register(Anthropic)
+}
+
 // AnthropicRequest represents an Anthropic API request.
 type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"maxTokens"`
-	Messages  []AnthropicMessage `json:"messages"`
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
 }
 
 // AnthropicMessage represents a message in Anthropic format.
@@ -30,67 +52,241 @@ type AnthropicResponse struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
 	} `json:"content"`
-	Error *struct {
-		Type    string `json:"type"`
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+	Error *AnthropicError `json:"error,omitempty"`
 }
 
-func (p *Provider) queryAnthropic(ctx context.Context, apiKey, model, promptText string) (string, error) {
-	requestBody := AnthropicRequest{
-		Model:     model,
-		MaxTokens: 1000,
-		Messages:  []AnthropicMessage{{Role: "user", Content: promptText}},
-	}
+// AnthropicUsage is the token count of a message.
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicError represents an Anthropic API error object.
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// anthropicStreamEvent represents the data of a Messages streaming event.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	// Message is set on message_start and carries the input token count.
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"`
+	// Usage is set on message_delta and carries the output token count so far.
+	Usage AnthropicUsage  `json:"usage"`
+	Error *AnthropicError `json:"error,omitempty"`
+}
+
+// anthropicModelsResponse represents an Anthropic /v1/models response.
+type anthropicModelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// anthropicBackend speaks the Anthropic Messages API.
+type anthropicBackend struct{}
+
+func (anthropicBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true}
+}
 
-	jsonData, err := json.Marshal(requestBody)
+func (anthropicBackend) CheckAuth(p *Provider) (string, error) {
+	return envKeyAuth(p)
+}
+
+func (anthropicBackend) Query(ctx context.Context, p *Provider, apiKey string, r Request) (string, error) {
+	req, err := newAnthropicRequest(ctx, p, apiKey, r, false)
 	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to marshal request")
+		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewBuffer(jsonData))
+	resp, body, err := doRequestResponse(ctx, req)
 	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to create request")
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Api-Key", apiKey)
-	req.Header.Set("Anthropic-Version", "2023-06-01")
+	if resp.StatusCode != http.StatusOK {
+		return "", anthropicStatusError(resp, body)
+	}
 
-	client := &http.Client{}
+	var anthropicResp AnthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to parse response: %s", string(body))
+	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			return "", pkgerrors.New("request timed out")
+	if anthropicResp.Error != nil {
+		return "", apiError(anthropicResp.Error.Type, anthropicResp.Error.Message)
+	}
+
+	if len(anthropicResp.Content) > 0 && anthropicResp.Content[0].Type == "text" {
+		if u := anthropicResp.Usage; u != nil {
+			r.reportUsage(&Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens})
 		}
 
-		return "", pkgerrors.Wrap(err, "failed to send request")
+		return anthropicResp.Content[0].Text, nil
+	}
+
+	return "", pkgerrors.New("no response from Anthropic")
+}
+
+func (anthropicBackend) QueryStream(
+	ctx context.Context, p *Provider, apiKey string, r Request, onToken TokenFunc,
+) (string, error) {
+	req, err := newAnthropicRequest(ctx, p, apiKey, r, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return "", err
 	}
 
 	defer func() { _ = resp.Body.Close() }()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", requestError(ctx, err, "failed to read response")
+		}
+
+		return "", anthropicStatusError(resp, body)
+	}
+
+	var (
+		sb    strings.Builder
+		usage *Usage
+	)
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return pkgerrors.Wrap(err, "failed to parse stream event")
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage = &Usage{InputTokens: event.Message.Usage.InputTokens, OutputTokens: event.Message.Usage.OutputTokens}
+		case "message_delta":
+			if usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				sb.WriteString(event.Delta.Text)
+				onToken(event.Delta.Text)
+			}
+		case "error":
+			if event.Error != nil {
+				return apiError(event.Error.Type, event.Error.Message)
+			}
+
+			return pkgerrors.New("API error: unknown stream error")
+		case "message_stop":
+			return errStreamDone
+		}
+
+		return nil
+	})
 	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to read response")
+		return "", requestError(ctx, err, "stream failed")
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.New("no response from Anthropic")
 	}
 
+	r.reportUsage(usage)
+
+	return sb.String(), nil
+}
+
+// anthropicStatusError classifies an unsuccessful Messages API response.
+func anthropicStatusError(resp *http.Response, body []byte) error {
 	var anthropicResp AnthropicResponse
-	if err := json.Unmarshal(body, &anthropicResp); err != nil {
-		return "", pkgerrors.Wrapf(err, "failed to parse response: %s", string(body))
+	if json.Unmarshal(body, &anthropicResp) == nil && anthropicResp.Error != nil {
+		return statusError(resp, body, anthropicResp.Error.Type, anthropicResp.Error.Message)
 	}
 
-	if anthropicResp.Error != nil {
-		return "", pkgerrors.Newf("API error: %s", anthropicResp.Error.Message)
+	return statusError(resp, body, "", "")
+}
+
+// anthropicRequestBody translates r into a Messages API request body. The
+// API has no JSON mode, so FormatJSON relies on the prompt.
+func anthropicRequestBody(r Request, stream bool) AnthropicRequest {
+	system, messages := anthropicMessages(r.turns())
+
+	return AnthropicRequest{
+		Model:         r.Model,
+		MaxTokens:     r.maxTokens(),
+		System:        system,
+		Messages:      messages,
+		Temperature:   r.Temperature,
+		StopSequences: r.Stop,
+		Stream:        stream,
 	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", pkgerrors.Newf("API returned status %d: %s", resp.StatusCode, string(body))
+// newAnthropicRequest builds a Messages API HTTP request for r.
+func newAnthropicRequest(ctx context.Context, p *Provider, apiKey string, r Request, stream bool) (*http.Request, error) {
+	jsonData, err := json.Marshal(anthropicRequestBody(r, stream))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to marshal request")
 	}
 
-	if len(anthropicResp.Content) > 0 && anthropicResp.Content[0].Type == "text" {
-		return anthropicResp.Content[0].Text, nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
 	}
 
-	return "", pkgerrors.New("no response from Anthropic")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+func (anthropicBackend) ListModels(ctx context.Context, p *Provider, apiKey string) ([]string, error) {
+	endpoint := strings.TrimSuffix(p.Endpoint, "/messages") + "/models"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	body, status, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, pkgerrors.Newf("API returned status %d: %s", status, string(body))
+	}
+
+	var modelsResp anthropicModelsResponse
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	models := make([]string, 0, len(modelsResp.Data))
+	for _, m := range modelsResp.Data {
+		models = append(models, m.ID)
+	}
+
+	return models, nil
 }
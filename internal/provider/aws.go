@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// AWS environment variables, as read by the AWS CLI and SDKs.
+const (
+	awsAccessKeyIDEnvVar     = "AWS_ACCESS_KEY_ID"
+	awsSecretAccessKeyEnvVar = "AWS_SECRET_ACCESS_KEY"
+	awsSessionTokenEnvVar    = "AWS_SESSION_TOKEN"
+	awsProfileEnvVar         = "AWS_PROFILE"
+	awsRegionEnvVar          = "AWS_REGION"
+	awsDefaultRegionEnvVar   = "AWS_DEFAULT_REGION"
+	awsCredentialsEnvVar     = "AWS_SHARED_CREDENTIALS_FILE"
+	awsConfigEnvVar          = "AWS_CONFIG_FILE"
+)
+
+// awsDefaultProfile is the profile used when AWS_PROFILE is unset.
+const awsDefaultProfile = "default"
+
+// loadAWSCredentials resolves credentials like the AWS CLI: the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables, else the AWS_PROFILE
+// profile of ~/.aws/credentials.
+func loadAWSCredentials() (awsCredentials, error) {
+	if id, secret := os.Getenv(awsAccessKeyIDEnvVar), os.Getenv(awsSecretAccessKeyEnvVar); id != "" && secret != "" {
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv(awsSessionTokenEnvVar)}, nil
+	}
+
+	profile := awsProfile()
+
+	path, err := awsFile(awsCredentialsEnvVar, "credentials")
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	section, err := readINISection(path, profile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	creds := awsCredentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, pkgerrors.Newf(
+			"no AWS credentials: set %s and %s or add profile %q to %s",
+			awsAccessKeyIDEnvVar, awsSecretAccessKeyEnvVar, profile, path)
+	}
+
+	return creds, nil
+}
+
+// awsRegion resolves the region like the AWS CLI: AWS_REGION, then
+// AWS_DEFAULT_REGION, then the profile in ~/.aws/config. It returns "" when
+// none is set.
+func awsRegion() string {
+	for _, name := range []string{awsRegionEnvVar, awsDefaultRegionEnvVar} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+
+	path, err := awsFile(awsConfigEnvVar, "config")
+	if err != nil {
+		return ""
+	}
+
+	// The config file prefixes every profile but the default with "profile "
+	name := awsProfile()
+	if name != awsDefaultProfile {
+		name = "profile " + name
+	}
+
+	section, err := readINISection(path, name)
+	if err != nil {
+		return ""
+	}
+
+	return section["region"]
+}
+
+func awsProfile() string {
+	if profile := os.Getenv(awsProfileEnvVar); profile != "" {
+		return profile
+	}
+
+	return awsDefaultProfile
+}
+
+// awsFile returns the path in envVar, or ~/.aws/name.
+func awsFile(envVar, name string) (string, error) {
+	if path := os.Getenv(envVar); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".aws", name), nil
+}
+
+// readINISection returns the keys of section [name] of the INI file at path.
+// A missing file or section yields no keys.
+func readINISection(path, name string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+
+		return nil, pkgerrors.Wrap(err, "failed to read AWS configuration")
+	}
+
+	defer func() { _ = f.Close() }()
+
+	var (
+		section = map[string]string{}
+		inside  bool
+	)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			inside = strings.TrimSpace(line[1:len(line)-1]) == name
+		case inside:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				section[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to read AWS configuration")
+	}
+
+	return section, nil
+}
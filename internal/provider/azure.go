@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// Azure OpenAI environment variables, named as in Microsoft's SDKs.
+const (
+	// AzureEndpointEnvVar is the resource URL, e.g.
+	// https://contoso.openai.azure.com.
+	AzureEndpointEnvVar = "AZURE_OPENAI_ENDPOINT"
+	// AzureAPIVersionEnvVar overrides DefaultAzureAPIVersion.
+	AzureAPIVersionEnvVar = "AZURE_OPENAI_API_VERSION"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version sent when none is
+// configured.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureOpenAI is an Azure OpenAI resource. Requests go to the deployment
+// serving the model; Endpoint, when set (e.g. from a profile or
+// ConfigureAzure), is the resource URL and overrides AZURE_OPENAI_ENDPOINT.
+var AzureOpenAI = &Provider{
+	Name:         "Azure OpenAI",
+	Aliases:      []string{"Azure"},
+	DefaultModel: "gpt-4o",
+	EnvVar:       "AZURE_OPENAI_API_KEY",
+	AuthType:     AuthAPIKey,
+	AuthHeader:   "api-key",
+	Priority:     15,
+	Backend:      azureBackend{token: &azureToken{}},
+}
+
+func init() {
+	Register(AzureOpenAI)
+}
+
+// AzureSettings configures the AzureOpenAI provider.
+type AzureSettings struct {
+	// Endpoint is the resource URL, e.g. https://contoso.openai.azure.com.
+	Endpoint string
+	// APIVersion is sent as the api-version query parameter.
+	APIVersion string
+	// TokenCommand prints a Microsoft Entra ID access token. When set, the
+	// token is sent as a bearer token instead of an API key.
+	TokenCommand string
+	// Shell and ShellFlag run TokenCommand, e.g. /bin/sh and -c.
+	Shell     string
+	ShellFlag string
+	// Deployments maps model names to deployment names. A model without an
+	// entry is used as the deployment name.
+	Deployments map[string]string
+}
+
+// ConfigureAzure applies s to the AzureOpenAI provider.
+func ConfigureAzure(s AzureSettings) {
+	if s.Endpoint != "" {
+		AzureOpenAI.Endpoint = s.Endpoint
+	}
+
+	AzureOpenAI.Backend = azureBackend{settings: s, token: &azureToken{}}
+}
+
+// AzureResourceURL returns the endpoint of the Azure OpenAI resource named
+// resource.
+func AzureResourceURL(resource string) string {
+	return "https://" + resource + ".openai.azure.com"
+}
+
+// azureBackend speaks the OpenAI chat/completions wire format to Azure
+// deployments.
+type azureBackend struct {
+	settings AzureSettings
+	// token caches the Entra ID token for the rest of the process.
+	token *azureToken
+}
+
+// azureToken is the output of a token command, fetched at the first request
+// and then kept.
+type azureToken struct {
+	mu    sync.Mutex
+	value string
+}
+
+func (b azureBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: len(b.settings.Deployments) > 0, Streaming: true}
+}
+
+func (b azureBackend) CheckAuth(p *Provider) (string, error) {
+	if azureBaseURL(p) == "" {
+		return "", pkgerrors.Newf("provider %s requires %s or an [azure] endpoint to be set", p.Name, AzureEndpointEnvVar)
+	}
+
+	// The token command runs at the first request, not during detection or
+	// listing
+	if b.settings.TokenCommand != "" {
+		return "", nil
+	}
+
+	return envKeyAuth(p)
+}
+
+func (b azureBackend) Query(ctx context.Context, p *Provider, apiKey string, r Request) (string, error) {
+	key, err := b.credential(ctx, apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	return openAIBackend{maxCompletionTokens: true}.Query(ctx, b.deployment(p, r.Model), key, r)
+}
+
+func (b azureBackend) QueryStream(
+	ctx context.Context, p *Provider, apiKey string, r Request, onToken TokenFunc,
+) (string, error) {
+	key, err := b.credential(ctx, apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	return openAIBackend{maxCompletionTokens: true}.QueryStream(ctx, b.deployment(p, r.Model), key, r, onToken)
+}
+
+// credential returns the Entra ID token when a token command is configured,
+// and apiKey otherwise.
+func (b azureBackend) credential(ctx context.Context, apiKey string) (string, error) {
+	if b.settings.TokenCommand == "" {
+		return apiKey, nil
+	}
+
+	return b.token.get(ctx, b.settings)
+}
+
+// ListModels returns the models with a configured deployment; listing the
+// deployments of a resource needs the Azure management API.
+func (b azureBackend) ListModels(context.Context, *Provider, string) ([]string, error) {
+	if len(b.settings.Deployments) == 0 {
+		return nil, ErrModelListingUnsupported
+	}
+
+	models := make([]string, 0, len(b.settings.Deployments))
+	for model := range b.settings.Deployments {
+		models = append(models, model)
+	}
+
+	sort.Strings(models)
+
+	return models, nil
+}
+
+// deployment returns p set up to call the deployment serving model.
+func (b azureBackend) deployment(p *Provider, model string) *Provider {
+	name := model
+	if deployment, ok := b.settings.Deployments[model]; ok {
+		name = deployment
+	}
+
+	version := cmp.Or(b.settings.APIVersion, os.Getenv(AzureAPIVersionEnvVar), DefaultAzureAPIVersion)
+
+	deployment := *p
+	deployment.Endpoint = azureBaseURL(p) + "/openai/deployments/" + url.PathEscape(name) +
+		"/chat/completions?api-version=" + url.QueryEscape(version)
+
+	if b.settings.TokenCommand != "" {
+		deployment.AuthType = AuthBearer
+	}
+
+	return &deployment
+}
+
+// azureBaseURL returns the resource URL from the provider endpoint or
+// AZURE_OPENAI_ENDPOINT, or "" when neither is set.
+func azureBaseURL(p *Provider) string {
+	return strings.TrimSuffix(cmp.Or(p.Endpoint, os.Getenv(AzureEndpointEnvVar)), "/")
+}
+
+// get runs the token command of s, once, and returns what it printed.
+func (t *azureToken) get(ctx context.Context, s AzureSettings) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.value != "" {
+		return t.value, nil
+	}
+
+	if s.Shell == "" {
+		return "", pkgerrors.New("no shell to run the azure token command in")
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, s.Shell, s.ShellFlag, s.TokenCommand)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", pkgerrors.Newf("azure token command failed: %s", msg)
+		}
+
+		return "", pkgerrors.Wrap(err, "azure token command failed")
+	}
+
+	t.value = strings.TrimSpace(stdout.String())
+	if t.value == "" {
+		return "", pkgerrors.New("azure token command printed no token")
+	}
+
+	return t.value, nil
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// azureServer fakes an Azure OpenAI resource with one deployment, prod-4o,
+// and records the Authorization and api-key headers of the last request.
+func azureServer(t *testing.T) (*httptest.Server, *http.Header) {
+	t.Helper()
+
+	var headers http.Header
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /openai/deployments/{deployment}/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+
+		if r.URL.Query().Get("api-version") != "2025-01-01-preview" {
+			t.Errorf("request api-version = %q", r.URL.Query().Get("api-version"))
+		}
+
+		if r.PathValue("deployment") != "prod-4o" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`)
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ls"}}]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, &headers
+}
+
+func TestAzureBackend(t *testing.T) {
+	t.Parallel()
+
+	settings := AzureSettings{
+		APIVersion:  "2025-01-01-preview",
+		Shell:       "/bin/sh",
+		ShellFlag:   "-c",
+		Deployments: map[string]string{"gpt-4o": "prod-4o", "o3-mini": "reasoning"},
+	}
+
+	t.Run("api key", func(t *testing.T) {
+		t.Parallel()
+
+		srv, headers := azureServer(t)
+		p := *AzureOpenAI
+		p.Endpoint = srv.URL + "/"
+		p.Backend = azureBackend{settings: settings, token: &azureToken{}}
+
+		got, err := p.Query(context.Background(), "secret", Request{Model: "gpt-4o"})
+		if err != nil || got != "ls" {
+			t.Fatalf("Query() = %q, %v; want ls from deployment prod-4o", got, err)
+		}
+
+		if headers.Get("Api-Key") != "secret" || headers.Get("Authorization") != "" {
+			t.Errorf("Query() sent api-key %q and Authorization %q", headers.Get("Api-Key"), headers.Get("Authorization"))
+		}
+
+		if _, err := p.Query(context.Background(), "secret", Request{Model: "gpt-35-turbo"}); err == nil {
+			t.Error("Query() for a model without a deployment should fail")
+		}
+	})
+
+	t.Run("entra token", func(t *testing.T) {
+		t.Parallel()
+
+		srv, headers := azureServer(t)
+		withToken := settings
+		withToken.TokenCommand = "echo entra-token"
+		p := *AzureOpenAI
+		p.Endpoint = srv.URL
+		p.Backend = azureBackend{settings: withToken, token: &azureToken{}}
+
+		key, err := p.Backend.CheckAuth(&p)
+		if err != nil {
+			t.Fatalf("CheckAuth() error = %v", err)
+		}
+
+		if _, err := p.Query(context.Background(), key, Request{Model: "gpt-4o"}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if headers.Get("Authorization") != "Bearer entra-token" || headers.Get("Api-Key") != "" {
+			t.Errorf("Query() sent Authorization %q and api-key %q", headers.Get("Authorization"), headers.Get("Api-Key"))
+		}
+	})
+
+	t.Run("failing token command", func(t *testing.T) {
+		t.Parallel()
+
+		failing := settings
+		failing.TokenCommand = "echo 'run az login' >&2; exit 1"
+		p := *AzureOpenAI
+		p.Endpoint = "https://contoso.openai.azure.com"
+		p.Backend = azureBackend{settings: failing, token: &azureToken{}}
+
+		// Detection does not run the command; the first request does
+		if _, err := p.Backend.CheckAuth(&p); err != nil {
+			t.Errorf("CheckAuth() error = %v, want the token command deferred", err)
+		}
+
+		if _, err := p.Query(context.Background(), "", Request{Model: "gpt-4o"}); err == nil ||
+			err.Error() != "azure token command failed: run az login" {
+			t.Errorf("Query() error = %v, want the command's message", err)
+		}
+	})
+
+	t.Run("list models", func(t *testing.T) {
+		t.Parallel()
+
+		models, err := azureBackend{settings: settings}.ListModels(context.Background(), AzureOpenAI, "")
+		if want := []string{"gpt-4o", "o3-mini"}; err != nil || !reflect.DeepEqual(models, want) {
+			t.Errorf("ListModels() = %v, %v; want %v", models, err, want)
+		}
+	})
+}
+
+func TestAzureCheckAuth(t *testing.T) {
+	t.Setenv(AzureEndpointEnvVar, "")
+	t.Setenv("AZURE_OPENAI_API_KEY", "secret")
+
+	p := *AzureOpenAI
+	p.Endpoint = ""
+
+	if _, err := p.Backend.CheckAuth(&p); err == nil {
+		t.Error("CheckAuth() without an endpoint should fail")
+	}
+
+	t.Setenv(AzureEndpointEnvVar, AzureResourceURL("contoso"))
+
+	if key, err := p.Backend.CheckAuth(&p); err != nil || key != "secret" {
+		t.Errorf("CheckAuth() = %q, %v; want the API key", key, err)
+	}
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// ErrModelListingUnsupported is returned by backends that cannot enumerate models.
+var ErrModelListingUnsupported = pkgerrors.New("model listing not supported")
+
+// Backend implements the wire protocol of an AI provider.
+// A single backend may serve several providers (e.g. every OpenAI-compatible
+// endpoint), so each call receives the provider it is acting for.
+type Backend interface {
+	// Query sends a completion request and returns the raw response text.
+	Query(ctx context.Context, p *Provider, apiKey string, req Request) (string, error)
+	// Capabilities reports the optional features the backend supports.
+	Capabilities() Capabilities
+	// CheckAuth returns the credential for p, or an error describing what is missing.
+	CheckAuth(p *Provider) (string, error)
+	// ListModels returns the models available to the caller.
+	ListModels(ctx context.Context, p *Provider, apiKey string) ([]string, error)
+}
+
+// Streamer is implemented by backends that can deliver a response incrementally.
+type Streamer interface {
+	// QueryStream behaves like Query but calls onToken with each text fragment
+	// as it arrives. The returned string is the complete response.
+	QueryStream(ctx context.Context, p *Provider, apiKey string, req Request, onToken TokenFunc) (string, error)
+}
+
+// TokenFunc receives response text as it is generated.
+type TokenFunc func(token string)
+
+// Capabilities describes optional backend features.
+type Capabilities struct {
+	// ModelListing is true when ListModels is implemented.
+	ModelListing bool
+	// Streaming is true when the backend implements Streamer.
+	Streaming bool
+	// Local is true when the backend runs on the user's machine, so listing
+	// its models is cheap and it works without network access.
+	Local bool
+}
+
+// envKeyAuth resolves a credential from the provider's environment variable.
+func envKeyAuth(p *Provider) (string, error) {
+	key := os.Getenv(p.EnvVar)
+	if key == "" {
+		return "", pkgerrors.Newf("provider %s requires %s to be set", p.Name, p.EnvVar)
+	}
+
+	return key, nil
+}
+
+// sendRequest sends req and returns the response; the caller closes its body.
+func sendRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
+	client := &http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err, "failed to send request")
+	}
+
+	return resp, nil
+}
+
+// doRequest sends req and returns the response body and status code.
+func doRequest(ctx context.Context, req *http.Request) ([]byte, int, error) {
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// doRequestResponse sends req and returns the response, whose body has been
+// read and closed, along with the body.
+func doRequestResponse(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, requestError(ctx, err, "failed to read response")
+	}
+
+	return resp, body, nil
+}
+
+// requestError reports a timeout when ctx expired and classifies other
+// failures to get a response as network errors.
+func requestError(ctx context.Context, err error, msg string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &Error{Kind: KindTimeout}
+	}
+
+	// Errors reported by the provider keep their classification
+	var classified *Error
+	if errors.As(err, &classified) {
+		return pkgerrors.Wrap(err, msg)
+	}
+
+	return &Error{Kind: KindNetwork, err: pkgerrors.Wrap(err, msg)}
+}
+
+// setAuthHeaders adds the provider's credential and extra headers to req.
+func setAuthHeaders(req *http.Request, p *Provider, apiKey string) {
+	switch p.AuthType {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	case AuthAPIKey:
+		req.Header.Set(p.AuthHeader, apiKey)
+	case AuthCLI, AuthNone, AuthSigV4:
+	}
+
+	for name, value := range p.Headers {
+		req.Header.Set(name, value)
+	}
+}
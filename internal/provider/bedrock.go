@@ -0,0 +1,418 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// bedrockService is the SigV4 service name of the Bedrock APIs.
+const bedrockService = "bedrock"
+
+// Bedrock is Amazon Bedrock's Converse API, signed with the caller's AWS
+// credentials. Endpoint, when set (e.g. from a profile, for a VPC endpoint),
+// replaces the regional bedrock-runtime endpoint.
+var Bedrock = &Provider{
+	Name:         "Bedrock",
+	Aliases:      []string{"AWS Bedrock"},
+	DefaultModel: "claude-sonnet-4",
+	AuthType:     AuthSigV4,
+	AuthHint:     "AWS credentials (SigV4)",
+	Priority:     45,
+	Backend:      bedrockBackend{},
+}
+
+func init() {
+	Register(Bedrock)
+}
+
+// bedrockModel is a Bedrock model ID; newer models are only served through
+// cross-region inference profiles, whose IDs carry a geography prefix.
+type bedrockModel struct {
+	id      string
+	profile bool
+}
+
+// bedrockModels maps short model names to Bedrock model IDs.
+var bedrockModels = map[string]bedrockModel{
+	"claude-opus-4":     {"anthropic.claude-opus-4-20250514-v1:0", true},
+	"claude-sonnet-4":   {"anthropic.claude-sonnet-4-20250514-v1:0", true},
+	"claude-3-7-sonnet": {"anthropic.claude-3-7-sonnet-20250219-v1:0", true},
+	"claude-3-5-sonnet": {"anthropic.claude-3-5-sonnet-20240620-v1:0", false},
+	"claude-3-5-haiku":  {"anthropic.claude-3-5-haiku-20241022-v1:0", true},
+	"claude-3-haiku":    {"anthropic.claude-3-haiku-20240307-v1:0", false},
+	"llama3-3-70b":      {"meta.llama3-3-70b-instruct-v1:0", true},
+	"llama3-1-70b":      {"meta.llama3-1-70b-instruct-v1:0", false},
+	"llama3-1-8b":       {"meta.llama3-1-8b-instruct-v1:0", false},
+	"llama3-70b":        {"meta.llama3-70b-instruct-v1:0", false},
+	"llama3-8b":         {"meta.llama3-8b-instruct-v1:0", false},
+	"mistral-large":     {"mistral.mistral-large-2407-v1:0", false},
+	"mistral-small":     {"mistral.mistral-small-2402-v1:0", false},
+	"mixtral-8x7b":      {"mistral.mixtral-8x7b-instruct-v0:1", false},
+	"mistral-7b":        {"mistral.mistral-7b-instruct-v0:2", false},
+}
+
+// bedrockGeographies maps region prefixes to inference profile prefixes.
+var bedrockGeographies = map[string]string{"us": "us", "eu": "eu", "ap": "apac"}
+
+// BedrockRequest represents a Converse API request.
+type BedrockRequest struct {
+	Messages        []BedrockMessage       `json:"messages"`
+	System          []BedrockContent       `json:"system,omitempty"`
+	InferenceConfig BedrockInferenceConfig `json:"inferenceConfig"`
+}
+
+// BedrockMessage represents a message in Converse format.
+type BedrockMessage struct {
+	Role    string           `json:"role"`
+	Content []BedrockContent `json:"content"`
+}
+
+// BedrockContent is a text content block.
+type BedrockContent struct {
+	Text string `json:"text"`
+}
+
+// BedrockInferenceConfig holds Converse model parameters.
+type BedrockInferenceConfig struct {
+	MaxTokens     int      `json:"maxTokens"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+// BedrockResponse represents a Converse API response.
+type BedrockResponse struct {
+	Output struct {
+		Message BedrockMessage `json:"message"`
+	} `json:"output"`
+	// StopReason is e.g. "end_turn", "max_tokens" or "guardrail_intervened".
+	StopReason string        `json:"stopReason"`
+	Usage      *BedrockUsage `json:"usage,omitempty"`
+	// Message explains an error response.
+	Message string `json:"message,omitempty"`
+}
+
+// BedrockUsage is the token count of a response.
+type BedrockUsage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+}
+
+// bedrockStreamEvent represents the payload of a ConverseStream event.
+type bedrockStreamEvent struct {
+	// Delta is set on contentBlockDelta events.
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+	// StopReason is set on messageStop events.
+	StopReason string `json:"stopReason"`
+	// Usage is set on metadata events.
+	Usage   *BedrockUsage `json:"usage,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+// bedrockModelsResponse represents a ListFoundationModels response.
+type bedrockModelsResponse struct {
+	ModelSummaries []struct {
+		ModelID string `json:"modelId"`
+	} `json:"modelSummaries"`
+}
+
+// bedrockBlockedReasons are the stop reasons of an answer withheld by a
+// guardrail or content filter; the text then is a refusal, not a command.
+var bedrockBlockedReasons = map[string]bool{
+	"guardrail_intervened": true,
+	"content_filtered":     true,
+}
+
+// bedrockBackend speaks the Bedrock Converse API.
+type bedrockBackend struct{}
+
+func (bedrockBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true}
+}
+
+func (bedrockBackend) CheckAuth(p *Provider) (string, error) {
+	if awsRegion() == "" {
+		return "", pkgerrors.Newf("provider %s requires %s to be set", p.Name, awsRegionEnvVar)
+	}
+
+	if _, err := loadAWSCredentials(); err != nil {
+		return "", err
+	}
+
+	return "", nil
+}
+
+func (bedrockBackend) Query(ctx context.Context, p *Provider, _ string, r Request) (string, error) {
+	req, err := newBedrockRequest(ctx, p, r, "converse")
+	if err != nil {
+		return "", err
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	var bedrockResp BedrockResponse
+	if err := json.Unmarshal(body, &bedrockResp); err != nil && resp.StatusCode == http.StatusOK {
+		return "", pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp, body, bedrockErrorType(resp.Header), bedrockResp.Message)
+	}
+
+	if bedrockBlockedReasons[bedrockResp.StopReason] {
+		return "", &Error{Kind: KindBlocked, Message: "response blocked (" + bedrockResp.StopReason + ")"}
+	}
+
+	var sb strings.Builder
+	for _, block := range bedrockResp.Output.Message.Content {
+		sb.WriteString(block.Text)
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.Newf("no response from %s", p.Name)
+	}
+
+	if u := bedrockResp.Usage; u != nil {
+		r.reportUsage(&Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens})
+	}
+
+	return sb.String(), nil
+}
+
+func (bedrockBackend) QueryStream(
+	ctx context.Context, p *Provider, _ string, r Request, onToken TokenFunc,
+) (string, error) {
+	req, err := newBedrockRequest(ctx, p, r, "converse-stream")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", requestError(ctx, err, "failed to read response")
+		}
+
+		var bedrockResp BedrockResponse
+		_ = json.Unmarshal(body, &bedrockResp)
+
+		return "", statusError(resp, body, bedrockErrorType(resp.Header), bedrockResp.Message)
+	}
+
+	var (
+		sb    strings.Builder
+		usage *Usage
+	)
+
+	err = readEventStream(resp.Body, func(headers map[string]string, payload []byte) error {
+		var event bedrockStreamEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return pkgerrors.Wrap(err, "failed to parse stream event")
+		}
+
+		switch headers[":message-type"] {
+		case "exception":
+			return apiError(exceptionType(headers[":exception-type"]), event.Message)
+		case "error":
+			return apiError(headers[":error-code"], headers[":error-message"])
+		}
+
+		switch headers[":event-type"] {
+		case "contentBlockDelta":
+			if event.Delta.Text != "" {
+				sb.WriteString(event.Delta.Text)
+				onToken(event.Delta.Text)
+			}
+		case "messageStop":
+			if bedrockBlockedReasons[event.StopReason] {
+				return &Error{Kind: KindBlocked, Message: "response blocked (" + event.StopReason + ")"}
+			}
+		case "metadata":
+			if u := event.Usage; u != nil {
+				usage = &Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", requestError(ctx, err, "stream failed")
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.Newf("no response from %s", p.Name)
+	}
+
+	r.reportUsage(usage)
+
+	return sb.String(), nil
+}
+
+func (bedrockBackend) ListModels(ctx context.Context, p *Provider, _ string) ([]string, error) {
+	region := awsRegion()
+
+	endpoint := "https://bedrock." + region + ".amazonaws.com"
+	if p.Endpoint != "" {
+		endpoint = strings.Replace(strings.TrimSuffix(p.Endpoint, "/"), "bedrock-runtime", "bedrock", 1)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/foundation-models?byOutputModality=TEXT", nil)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	if err := signBedrockRequest(req, nil, region); err != nil {
+		return nil, err
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var bedrockResp BedrockResponse
+		_ = json.Unmarshal(body, &bedrockResp)
+
+		return nil, statusError(resp, body, bedrockErrorType(resp.Header), bedrockResp.Message)
+	}
+
+	var modelsResp bedrockModelsResponse
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	models := make([]string, 0, len(modelsResp.ModelSummaries))
+	for _, m := range modelsResp.ModelSummaries {
+		models = append(models, m.ModelID)
+	}
+
+	return models, nil
+}
+
+// newBedrockRequest builds a signed Converse API HTTP request for r; action
+// is "converse" or "converse-stream".
+func newBedrockRequest(ctx context.Context, p *Provider, r Request, action string) (*http.Request, error) {
+	region := awsRegion()
+
+	endpoint := strings.TrimSuffix(p.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://bedrock-runtime." + region + ".amazonaws.com"
+	}
+
+	jsonData, err := json.Marshal(bedrockRequestBody(r))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to marshal request")
+	}
+
+	url := endpoint + "/model/" + awsEscape(bedrockModelID(r.Model, region)) + "/" + action
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := signBedrockRequest(req, jsonData, region); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// signBedrockRequest signs req with the caller's AWS credentials.
+func signBedrockRequest(req *http.Request, body []byte, region string) error {
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return err
+	}
+
+	signRequest(req, body, creds, region, bedrockService, time.Now())
+
+	return nil
+}
+
+// bedrockRequestBody translates r into a Converse request body. System turns
+// move to the system blocks and consecutive turns of the same role are
+// merged, as for Anthropic. The API has no JSON mode, so FormatJSON relies
+// on the prompt.
+func bedrockRequestBody(r Request) BedrockRequest {
+	system, messages := anthropicMessages(r.turns())
+
+	body := BedrockRequest{
+		Messages: make([]BedrockMessage, 0, len(messages)),
+		InferenceConfig: BedrockInferenceConfig{
+			MaxTokens:     r.maxTokens(),
+			Temperature:   r.Temperature,
+			StopSequences: r.Stop,
+		},
+	}
+
+	if system != "" {
+		body.System = []BedrockContent{{Text: system}}
+	}
+
+	for _, m := range messages {
+		body.Messages = append(body.Messages, BedrockMessage{Role: m.Role, Content: []BedrockContent{{Text: m.Content}}})
+	}
+
+	return body
+}
+
+// bedrockModelID maps a short model name to its Bedrock model ID, prefixed
+// with the geography of region where only an inference profile serves it.
+// Model IDs, inference profile IDs and ARNs are used as they are.
+func bedrockModelID(model, region string) string {
+	m, ok := bedrockModels[model]
+	if !ok {
+		return model
+	}
+
+	if !m.profile {
+		return m.id
+	}
+
+	prefix, _, _ := strings.Cut(region, "-")
+	if geography, ok := bedrockGeographies[prefix]; ok {
+		return geography + "." + m.id
+	}
+
+	return m.id
+}
+
+// bedrockErrorType returns the error type AWS reports in the
+// X-Amzn-ErrorType header, e.g. "ThrottlingException".
+func bedrockErrorType(header http.Header) string {
+	errType, _, _ := strings.Cut(header.Get("X-Amzn-Errortype"), ":")
+
+	return errType
+}
+
+// exceptionType converts the exception type of a stream event, e.g.
+// "throttlingException", to the form of the X-Amzn-ErrorType header.
+func exceptionType(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return strings.ToUpper(name[:1]) + name[1:]
+}
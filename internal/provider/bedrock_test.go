@@ -0,0 +1,345 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// verifySignature recomputes the SigV4 signature of a request received by a
+// server and reports whether it matches the Authorization header.
+func verifySignature(r *http.Request, body []byte, creds awsCredentials) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ")
+	if !ok {
+		return errors.New("request is not signed")
+	}
+
+	fields := map[string]string{}
+	for field := range strings.SplitSeq(auth, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+
+	scope := strings.SplitN(fields["Credential"], "/", 2)
+	if len(scope) != 2 || scope[0] != creds.AccessKeyID {
+		return fmt.Errorf("credential %q", fields["Credential"])
+	}
+
+	parts := strings.Split(scope[1], "/") // date/region/service/aws4_request
+	if len(parts) != 4 {
+		return fmt.Errorf("credential scope %q", scope[1])
+	}
+
+	stringToSign := sigV4Algorithm + "\n" + r.Header.Get("X-Amz-Date") + "\n" + scope[1] + "\n" +
+		hashHex([]byte(canonicalRequest(r, strings.Split(fields["SignedHeaders"], ";"), hashHex(body))))
+	want := hex.EncodeToString(hmacSHA256(signingKey(creds.SecretAccessKey, parts[0], parts[1], parts[2]), stringToSign))
+
+	if fields["Signature"] != want {
+		return fmt.Errorf("signature %s, want %s", fields["Signature"], want)
+	}
+
+	if parts[1] != "eu-west-1" || parts[2] != bedrockService {
+		return fmt.Errorf("signed for %s/%s", parts[1], parts[2])
+	}
+
+	return nil
+}
+
+// eventStreamMessage encodes an event stream message with string headers.
+func eventStreamMessage(headers map[string]string, payload string) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(eventStreamStringHeader)
+		_ = binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+
+	total := eventStreamPrelude + h.Len() + len(payload) + 4
+	msg := binary.BigEndian.AppendUint32(nil, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(h.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, h.Bytes()...)
+	msg = append(msg, payload...)
+
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+// errorKind returns the kind of a provider error, or KindUnknown.
+func errorKind(err error) ErrorKind {
+	var e *Error
+	if !errors.As(err, &e) {
+		return KindUnknown
+	}
+
+	return e.Kind
+}
+
+// bedrockEvent encodes a ConverseStream event.
+func bedrockEvent(eventType, payload string) []byte {
+	return eventStreamMessage(map[string]string{":message-type": "event", ":event-type": eventType}, payload)
+}
+
+// bedrockServer fakes the Bedrock runtime and control plane APIs, rejecting
+// requests not signed with creds, and records the model of the last request.
+func bedrockServer(t *testing.T, creds awsCredentials) (*httptest.Server, *string) {
+	t.Helper()
+
+	var model string
+
+	verify := func(w http.ResponseWriter, r *http.Request) bool {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifySignature(r, body, creds); err != nil {
+			w.Header().Set("X-Amzn-Errortype", "InvalidSignatureException:http://internal.amazon.com/coral/com.amazon.coral.service/")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprintf(w, `{"message":%q}`, err.Error())
+
+			return false
+		}
+
+		if r.Header.Get("X-Amz-Security-Token") != creds.SessionToken {
+			t.Errorf("X-Amz-Security-Token = %q", r.Header.Get("X-Amz-Security-Token"))
+		}
+
+		model = r.PathValue("model")
+
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /model/{model}/converse", func(w http.ResponseWriter, r *http.Request) {
+		if !verify(w, r) {
+			return
+		}
+
+		switch model {
+		case "guarded":
+			_, _ = fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[{"text":"Sorry, the model cannot answer this question."}]}},"stopReason":"guardrail_intervened"}`)
+		case "throttled":
+			w.Header().Set("X-Amzn-Errortype", "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"message":"Too many requests, please wait before trying again."}`)
+		default:
+			_, _ = fmt.Fprint(w, `{"output":{"message":{"role":"assistant","content":[{"text":"ls -la"}]}},"stopReason":"end_turn","usage":{"inputTokens":12,"outputTokens":3}}`)
+		}
+	})
+	mux.HandleFunc("POST /model/{model}/converse-stream", func(w http.ResponseWriter, r *http.Request) {
+		if !verify(w, r) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(bedrockEvent("messageStart", `{"role":"assistant"}`))
+		_, _ = w.Write(bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"ls"}}`))
+
+		if model == "throttled" {
+			_, _ = w.Write(eventStreamMessage(map[string]string{":message-type": "exception", ":exception-type": "throttlingException"}, `{"message":"Too many requests"}`))
+
+			return
+		}
+
+		_, _ = w.Write(bedrockEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":" -la"}}`))
+		_, _ = w.Write(bedrockEvent("messageStop", `{"stopReason":"end_turn"}`))
+		_, _ = w.Write(bedrockEvent("metadata", `{"usage":{"inputTokens":12,"outputTokens":3,"totalTokens":15}}`))
+	})
+	mux.HandleFunc("GET /foundation-models", func(w http.ResponseWriter, r *http.Request) {
+		if !verify(w, r) {
+			return
+		}
+
+		if r.URL.Query().Get("byOutputModality") != "TEXT" {
+			t.Errorf("byOutputModality = %q", r.URL.Query().Get("byOutputModality"))
+		}
+
+		_, _ = fmt.Fprint(w, `{"modelSummaries":[{"modelId":"anthropic.claude-3-haiku-20240307-v1:0"},{"modelId":"meta.llama3-8b-instruct-v1:0"}]}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, &model
+}
+
+func TestBedrockBackend(t *testing.T) {
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}
+	t.Setenv(awsAccessKeyIDEnvVar, creds.AccessKeyID)
+	t.Setenv(awsSecretAccessKeyEnvVar, creds.SecretAccessKey)
+	t.Setenv(awsSessionTokenEnvVar, creds.SessionToken)
+	t.Setenv(awsRegionEnvVar, "eu-west-1")
+
+	srv, model := bedrockServer(t, creds)
+	p := *Bedrock
+	p.Endpoint = srv.URL
+
+	if _, err := p.Backend.CheckAuth(&p); err != nil {
+		t.Fatalf("CheckAuth() error = %v", err)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		var usage *Usage
+
+		got, err := p.Query(context.Background(), "", Request{Model: "claude-sonnet-4", OnUsage: func(u Usage) { usage = &u }})
+		if err != nil || got != "ls -la" {
+			t.Fatalf("Query() = %q, %v; want ls -la", got, err)
+		}
+
+		if *model != "eu.anthropic.claude-sonnet-4-20250514-v1:0" {
+			t.Errorf("Query() called model %q", *model)
+		}
+
+		if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 3 {
+			t.Errorf("Query() reported usage %+v", usage)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var tokens []string
+
+		got, err := p.QueryStream(context.Background(), "", Request{Model: "mistral-large"}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if err != nil || got != "ls -la" {
+			t.Fatalf("QueryStream() = %q, %v; want ls -la", got, err)
+		}
+
+		if !reflect.DeepEqual(tokens, []string{"ls", " -la"}) {
+			t.Errorf("QueryStream() tokens = %q", tokens)
+		}
+
+		if *model != "mistral.mistral-large-2407-v1:0" {
+			t.Errorf("QueryStream() called model %q", *model)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			model string
+			kind  ErrorKind
+		}{
+			{"guarded", KindBlocked},
+			{"throttled", KindRateLimit},
+		}
+
+		for _, tt := range tests {
+			_, err := p.Query(context.Background(), "", Request{Model: tt.model})
+			if kind := errorKind(err); kind != tt.kind {
+				t.Errorf("Query(%s) error = %v, kind %v; want %v", tt.model, err, kind, tt.kind)
+			}
+		}
+
+		_, err := p.QueryStream(context.Background(), "", Request{Model: "throttled"}, func(string) {})
+		if kind := errorKind(err); kind != KindRateLimit {
+			t.Errorf("QueryStream(throttled) error = %v, kind %v; want %v", err, kind, KindRateLimit)
+		}
+	})
+
+	t.Run("list models", func(t *testing.T) {
+		got, err := p.ListModels(context.Background(), "")
+		want := []string{"anthropic.claude-3-haiku-20240307-v1:0", "meta.llama3-8b-instruct-v1:0"}
+
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ListModels() = %q, %v; want %q", got, err, want)
+		}
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		t.Setenv(awsSecretAccessKeyEnvVar, "wrong")
+
+		if _, err := p.Query(context.Background(), "", Request{Model: "claude-3-haiku"}); errorKind(err) != KindAuth {
+			t.Errorf("Query() with a wrong secret error = %v, want an auth error", err)
+		}
+	})
+}
+
+func TestBedrockModelID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		model, region, want string
+	}{
+		{"claude-sonnet-4", "us-east-1", "us.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"claude-sonnet-4", "ap-northeast-1", "apac.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{"claude-3-haiku", "eu-central-1", "anthropic.claude-3-haiku-20240307-v1:0"},
+		{"llama3-3-70b", "us-west-2", "us.meta.llama3-3-70b-instruct-v1:0"},
+		{"mixtral-8x7b", "us-west-2", "mistral.mixtral-8x7b-instruct-v0:1"},
+		{"anthropic.claude-v2:1", "us-east-1", "anthropic.claude-v2:1"},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/x", "us-east-1", "arn:aws:bedrock:us-east-1:123456789012:inference-profile/x"},
+	}
+
+	for _, tt := range tests {
+		if got := bedrockModelID(tt.model, tt.region); got != tt.want {
+			t.Errorf("bedrockModelID(%q, %q) = %q, want %q", tt.model, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAWSCredentials(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	config := filepath.Join(dir, "config")
+
+	writeFile := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(credentials, "[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = default-secret\n\n"+
+		"[work]\naws_access_key_id = AKIDWORK\naws_secret_access_key = work-secret\naws_session_token = work-token\n")
+	writeFile(config, "[default]\nregion = us-east-1\n\n[profile work]\nregion = eu-west-3\n")
+
+	for _, name := range []string{awsAccessKeyIDEnvVar, awsSecretAccessKeyEnvVar, awsSessionTokenEnvVar, awsRegionEnvVar, awsDefaultRegionEnvVar} {
+		t.Setenv(name, "")
+	}
+
+	t.Setenv(awsCredentialsEnvVar, credentials)
+	t.Setenv(awsConfigEnvVar, config)
+
+	tests := []struct {
+		profile string
+		want    awsCredentials
+		region  string
+	}{
+		{"", awsCredentials{AccessKeyID: "AKIDDEFAULT", SecretAccessKey: "default-secret"}, "us-east-1"},
+		{"work", awsCredentials{AccessKeyID: "AKIDWORK", SecretAccessKey: "work-secret", SessionToken: "work-token"}, "eu-west-3"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv(awsProfileEnvVar, tt.profile)
+
+		got, err := loadAWSCredentials()
+		if err != nil || got != tt.want {
+			t.Errorf("loadAWSCredentials() for profile %q = %+v, %v; want %+v", tt.profile, got, err, tt.want)
+		}
+
+		if region := awsRegion(); region != tt.region {
+			t.Errorf("awsRegion() for profile %q = %q, want %q", tt.profile, region, tt.region)
+		}
+	}
+
+	t.Setenv(awsProfileEnvVar, "missing")
+
+	if _, err := loadAWSCredentials(); err == nil {
+		t.Error("loadAWSCredentials() for a missing profile should fail")
+	}
+
+	t.Setenv(awsAccessKeyIDEnvVar, "AKIDENV")
+	t.Setenv(awsSecretAccessKeyEnvVar, "env-secret")
+
+	if got, err := loadAWSCredentials(); err != nil || got.AccessKeyID != "AKIDENV" {
+		t.Errorf("loadAWSCredentials() = %+v, %v; want the environment credentials", got, err)
+	}
+}
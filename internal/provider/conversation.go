@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"strings"
+)
+
+// Role identifies the author of a conversation turn.
+type Role string
+
+// Conversation roles.
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Turn is one message of a conversation.
+type Turn struct {
+	Role    Role
+	Content string
+}
+
+// Conversation keeps the turns of a multi-turn exchange in order. Backends
+// serialize it into their own message format.
+type Conversation struct {
+	turns []Turn
+}
+
+// NewConversation starts a conversation with an optional system turn.
+func NewConversation(system string) *Conversation {
+	c := &Conversation{}
+	if system != "" {
+		c.turns = append(c.turns, Turn{Role: RoleSystem, Content: system})
+	}
+
+	return c
+}
+
+// AddUser appends a user turn.
+func (c *Conversation) AddUser(content string) {
+	c.turns = append(c.turns, Turn{Role: RoleUser, Content: content})
+}
+
+// AddAssistant appends an assistant turn.
+func (c *Conversation) AddAssistant(content string) {
+	c.turns = append(c.turns, Turn{Role: RoleAssistant, Content: content})
+}
+
+// Turns returns a copy of the turns.
+func (c *Conversation) Turns() []Turn {
+	turns := make([]Turn, len(c.turns))
+	copy(turns, c.turns)
+
+	return turns
+}
+
+// chatMessages converts turns to the OpenAI message format, which Ollama
+// shares.
+func chatMessages(turns []Turn) []Message {
+	messages := make([]Message, 0, len(turns))
+	for _, t := range turns {
+		messages = append(messages, Message{Role: string(t.Role), Content: t.Content})
+	}
+
+	return messages
+}
+
+// anthropicMessages converts turns to the Anthropic format: system turns move
+// to the top-level system prompt and consecutive turns of the same role are
+// merged, since the Messages API requires user and assistant to alternate.
+func anthropicMessages(turns []Turn) (string, []AnthropicMessage) {
+	var (
+		system   []string
+		messages []AnthropicMessage
+	)
+
+	for _, t := range turns {
+		switch {
+		case t.Role == RoleSystem:
+			system = append(system, t.Content)
+		case len(messages) > 0 && messages[len(messages)-1].Role == string(t.Role):
+			messages[len(messages)-1].Content += "\n\n" + t.Content
+		default:
+			messages = append(messages, AnthropicMessage{Role: string(t.Role), Content: t.Content})
+		}
+	}
+
+	return strings.Join(system, "\n\n"), messages
+}
+
+// transcript flattens turns into a single prompt for backends without
+// message support.
+func transcript(turns []Turn) string {
+	if len(turns) == 1 {
+		return turns[0].Content
+	}
+
+	var sb strings.Builder
+
+	for _, t := range turns {
+		sb.WriteString(strings.ToUpper(string(t.Role)) + ":\n" + t.Content + "\n\n")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func refinement() *Conversation {
+	c := NewConversation("Reply with a shell command only.")
+	c.AddUser("find go files")
+	c.AddAssistant("find . -name '*.go'")
+	c.AddUser("use fd instead")
+
+	return c
+}
+
+func TestConversation(t *testing.T) {
+	t.Parallel()
+
+	c := refinement()
+	turns := c.Turns()
+	turns[0].Content = "changed"
+
+	if c.Turns()[0].Content == "changed" {
+		t.Error("Turns() should return a copy")
+	}
+
+	if len(NewConversation("").Turns()) != 0 {
+		t.Error("NewConversation(\"\") should not add a system turn")
+	}
+}
+
+func TestAnthropicMessages(t *testing.T) {
+	t.Parallel()
+
+	turns := append(refinement().Turns(), Turn{Role: RoleUser, Content: "and skip vendor"})
+
+	system, messages := anthropicMessages(turns)
+	if system != "Reply with a shell command only." {
+		t.Errorf("anthropicMessages() system = %q", system)
+	}
+
+	want := []AnthropicMessage{
+		{Role: "user", Content: "find go files"},
+		{Role: "assistant", Content: "find . -name '*.go'"},
+		{Role: "user", Content: "use fd instead\n\nand skip vendor"},
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("anthropicMessages() = %+v, want %+v", messages, want)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	t.Parallel()
+
+	if got := transcript([]Turn{{Role: RoleUser, Content: "list files"}}); got != "list files" {
+		t.Errorf("transcript() single turn = %q", got)
+	}
+
+	want := "SYSTEM:\nReply with a shell command only.\n\nUSER:\nfind go files\n\nASSISTANT:\nfind . -name '*.go'\n\nUSER:\nuse fd instead"
+	if got := transcript(refinement().Turns()); got != want {
+		t.Errorf("transcript() = %q, want %q", got, want)
+	}
+}
+
+func TestQueryMessages(t *testing.T) {
+	t.Parallel()
+
+	var got ChatRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"fd -e go"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &Provider{Name: "Test", Endpoint: srv.URL, AuthType: AuthNone, Backend: openAIBackend{}}
+
+	if _, err := p.Query(context.Background(), "", Request{Model: "m", Messages: refinement().Turns()}); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	roles := make([]string, 0, len(got.Messages))
+	for _, m := range got.Messages {
+		roles = append(roles, m.Role)
+	}
+
+	if want := []string{"system", "user", "assistant", "user"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("Query() sent roles %v, want %v", roles, want)
+	}
+}
@@ -2,89 +2,546 @@ package provider
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
+	"maps"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	pkgerrors "github.com/cockroachdb/errors"
 )
 
-// queryCopilot uses the official GitHub Copilot CLI (gh copilot).
-// This requires:
-// 1. GitHub CLI (gh) to be installed
-// 2. Active GitHub Copilot subscription
-// 3. Being authenticated with: gh auth login
-//
-// The modern gh copilot CLI uses:
-//   - `-p` or `--prompt` for non-interactive mode
-//   - `-s` or `--silent` for output only (no stats)
-//   - `--model` for model selection
-func (p *Provider) queryCopilot(ctx context.Context, _ string, model string, promptText string) (string, error) {
-	// Check if gh is available
-	ghPath, err := exec.LookPath("gh")
+const (
+	// GitHubTokenEnvVar holds a GitHub token used instead of `gh auth token`;
+	// GITHUB_TOKEN is read when it is unset.
+	GitHubTokenEnvVar = "GH_TOKEN"
+	// CopilotTokenURL exchanges a GitHub token for a Copilot session token.
+	CopilotTokenURL = "https://api.github.com/copilot_internal/v2/token"
+
+	githubTokenFallbackEnvVar = "GITHUB_TOKEN"
+	copilotIntegrationID      = "vscode-chat"
+	copilotEditorVersion      = "howto/1.0.0"
+	// copilotTokenMargin is how long before its expiry a session token is
+	// replaced, so it does not expire during a request.
+	copilotTokenMargin = time.Minute
+)
+
+// GitHubCopilot calls the Copilot chat completions API with a session token
+// obtained for the user's GitHub token.
+var GitHubCopilot = &Provider{
+	Name:         "GitHub Copilot",
+	Endpoint:     "https://api.githubcopilot.com",
+	DefaultModel: "gpt-4o",
+	AuthType:     AuthCLI,
+	Aliases:      []string{"Copilot"},
+	AuthHint:     GitHubTokenEnvVar + " or gh auth token",
+	Priority:     100,
+	Backend:      copilotBackend{tokenURL: CopilotTokenURL, auth: &copilotAuth{}},
+}
+
+func init() {
+	Register(GitHubCopilot)
+}
+
+// copilotTokenResponse represents the response of the token exchange.
+type copilotTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	Endpoints struct {
+		API string `json:"api"`
+	} `json:"endpoints"`
+	// Message explains an error response.
+	Message      string `json:"message,omitempty"`
+	ErrorDetails *struct {
+		Message string `json:"message"`
+	} `json:"error_details,omitempty"`
+}
+
+// copilotModelsResponse represents the response of the models endpoint.
+type copilotModelsResponse struct {
+	Data []struct {
+		ID           string `json:"id"`
+		Capabilities struct {
+			Type string `json:"type"`
+		} `json:"capabilities"`
+	} `json:"data"`
+}
+
+// copilotSession is a Copilot session token, cached until it expires.
+type copilotSession struct {
+	// Key identifies the GitHub token the session was issued for.
+	Key       string `json:"key"`
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	// API is the chat API base URL of the account.
+	API string `json:"api"`
+}
+
+// valid reports whether s was issued for key and is usable at now.
+func (s copilotSession) valid(key string, now time.Time) bool {
+	return s.Token != "" && s.Key == key && now.Add(copilotTokenMargin).Before(time.Unix(s.ExpiresAt, 0))
+}
+
+// copilotAuth holds the GitHub token and the Copilot session of the process.
+type copilotAuth struct {
+	mu          sync.Mutex
+	githubToken string
+	session     copilotSession
+	// path is the file caching the session between runs; "" uses
+	// copilotSessionPath.
+	path string
+}
+
+// copilotBackend speaks the Copilot chat API, which follows the OpenAI
+// chat/completions wire format.
+type copilotBackend struct {
+	tokenURL string
+	auth     *copilotAuth
+}
+
+func (copilotBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true}
+}
+
+// CheckAuth looks for a GitHub token without running gh or contacting
+// GitHub, since detection and listing check every provider: in the
+// environment, in the GitHub CLI's hosts.yml, or a session cached by an
+// earlier run. When gh keeps its token in the system keyring, the key is
+// empty and the first request runs `gh auth token`. Whether the account has
+// a Copilot subscription only shows at the token exchange of that request.
+func (b copilotBackend) CheckAuth(*Provider) (string, error) {
+	if token := cmp.Or(os.Getenv(GitHubTokenEnvVar), os.Getenv(githubTokenFallbackEnvVar)); token != "" {
+		return token, nil
+	}
+
+	token, loggedIn := ghHostsLogin()
+	if token != "" {
+		return token, nil
+	}
+
+	if loggedIn || b.auth.cached() {
+		return "", nil
+	}
+
+	return "", pkgerrors.Newf(
+		"GitHub Copilot requires %s or the GitHub CLI: install it from https://cli.github.com/ and run gh auth login",
+		GitHubTokenEnvVar)
+}
+
+func (b copilotBackend) Query(ctx context.Context, p *Provider, githubToken string, r Request) (string, error) {
+	chat, token, err := b.chat(ctx, p, githubToken)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := openAIBackend{}.Query(ctx, chat, token, r)
+	if err != nil {
+		return "", b.auth.check(err)
+	}
+
+	// Clean up the response - remove any markdown formatting that might slip through
+	return CleanCopilotResponse(result), nil
+}
+
+func (b copilotBackend) QueryStream(
+	ctx context.Context, p *Provider, githubToken string, r Request, onToken TokenFunc,
+) (string, error) {
+	chat, token, err := b.chat(ctx, p, githubToken)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := openAIBackend{}.QueryStream(ctx, chat, token, r, onToken)
 	if err != nil {
-		return "", pkgerrors.New("GitHub CLI (gh) not found. Install it from https://cli.github.com/")
+		return "", b.auth.check(err)
 	}
 
-	// Build the command args
-	// Use -p for prompt mode (non-interactive) and -s for silent (only output, no stats)
-	// The prompt asks for a shell command specifically
-	shellPrompt := "Output only a shell command (no explanation, no markdown, no backticks) that: " + promptText
+	return CleanCopilotResponse(result), nil
+}
 
-	args := []string{"copilot", "--", "-p", shellPrompt, "-s"}
+// ListModels returns the chat models enabled for the account.
+func (b copilotBackend) ListModels(ctx context.Context, p *Provider, githubToken string) ([]string, error) {
+	chat, token, err := b.chat(ctx, p, githubToken)
+	if err != nil {
+		return nil, err
+	}
 
-	// Add model if specified and not the default
-	if model != "" && model != "gpt-4" {
-		args = append(args, "--model", model)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(chat.Endpoint, "/chat/completions")+"/models", nil)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
 	}
 
-	cmd := exec.CommandContext(ctx, ghPath, args...)
+	setAuthHeaders(req, chat, token)
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, b.auth.check(parseChatResponse(resp, body, &ChatResponse{}))
+	}
+
+	var modelsResp copilotModelsResponse
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	seen := make(map[string]bool)
+	models := make([]string, 0, len(modelsResp.Data))
+
+	for _, m := range modelsResp.Data {
+		if m.Capabilities.Type != "chat" || seen[m.ID] {
+			continue
+		}
+
+		seen[m.ID] = true
+		models = append(models, m.ID)
+	}
+
+	return models, nil
+}
+
+// chat returns p set up to call the chat completions API of the account of
+// githubToken, or of the GitHub CLI's when it is empty, and the session token
+// to send.
+func (b copilotBackend) chat(ctx context.Context, p *Provider, githubToken string) (*Provider, string, error) {
+	if githubToken == "" {
+		token, err := b.auth.token(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+
+		githubToken = token
+	}
+
+	session, err := b.auth.sessionFor(ctx, b.tokenURL, githubToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	chat := *p
+	chat.Endpoint = strings.TrimSuffix(cmp.Or(session.API, p.Endpoint), "/") + "/chat/completions"
+	chat.AuthType = AuthBearer
+	chat.Headers = maps.Clone(p.Headers)
+
+	if chat.Headers == nil {
+		chat.Headers = make(map[string]string)
+	}
+
+	maps.Copy(chat.Headers, copilotHeaders())
+
+	return &chat, session.Token, nil
+}
+
+// copilotHeaders are the headers the Copilot APIs require of clients.
+func copilotHeaders() map[string]string {
+	return map[string]string{
+		"Copilot-Integration-Id": copilotIntegrationID,
+		"Editor-Version":         copilotEditorVersion,
+	}
+}
+
+// token returns the GitHub token from GH_TOKEN or GITHUB_TOKEN, else from the
+// GitHub CLI's hosts.yml, else from `gh auth token`, which runs once per
+// process.
+func (a *copilotAuth) token(ctx context.Context) (string, error) {
+	if token := cmp.Or(os.Getenv(GitHubTokenEnvVar), os.Getenv(githubTokenFallbackEnvVar)); token != "" {
+		return token, nil
+	}
+
+	if token, _ := ghHostsLogin(); token != "" {
+		return token, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.githubToken != "" {
+		return a.githubToken, nil
+	}
+
+	ghPath, err := exec.LookPath("gh")
+	if err != nil {
+		return "", pkgerrors.Newf(
+			"GitHub Copilot requires %s or the GitHub CLI: install it from https://cli.github.com/ and run gh auth login",
+			GitHubTokenEnvVar)
+	}
 
 	var stdout, stderr bytes.Buffer
 
+	cmd := exec.CommandContext(ctx, ghPath, "auth", "token")
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return "", handleCopilotError(ctx, err, stderr.String())
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", pkgerrors.Newf("not authenticated with GitHub (run gh auth login): %s", msg)
+		}
+
+		return "", pkgerrors.Wrap(err, "not authenticated with GitHub (run gh auth login)")
 	}
 
-	result := strings.TrimSpace(stdout.String())
-	if result == "" {
-		return "", pkgerrors.New("no suggestion from GitHub Copilot")
+	a.githubToken = strings.TrimSpace(stdout.String())
+	if a.githubToken == "" {
+		return "", pkgerrors.New("not authenticated with GitHub (run gh auth login)")
 	}
 
-	// Clean up the response - remove any markdown formatting that might slip through
-	result = CleanCopilotResponse(result)
+	return a.githubToken, nil
+}
+
+// sessionFor returns a session token for githubToken: the cached one while it
+// is valid, else a new one from tokenURL, which is then cached.
+func (a *copilotAuth) sessionFor(ctx context.Context, tokenURL, githubToken string) (copilotSession, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := hashHex([]byte(githubToken))
+	now := time.Now()
+
+	if a.session.valid(key, now) {
+		return a.session, nil
+	}
+
+	if cached, err := a.load(); err == nil && cached.valid(key, now) {
+		a.session = cached
+
+		return cached, nil
+	}
+
+	session, err := exchangeCopilotToken(ctx, tokenURL, githubToken)
+	if err != nil {
+		return copilotSession{}, err
+	}
 
-	return result, nil
+	session.Key = key
+	a.session = session
+	a.save()
+
+	return session, nil
 }
 
-func handleCopilotError(ctx context.Context, err error, stderrStr string) error {
-	// Check for context cancellation/timeout
-	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-		return pkgerrors.New("request timed out")
+// cached reports whether a session cached by an earlier run is still valid,
+// whichever GitHub token it was issued for.
+func (a *copilotAuth) cached() bool {
+	session, err := a.load()
+
+	return err == nil && session.valid(session.Key, time.Now())
+}
+
+// check forgets the session when err shows the API rejected it, so the next
+// request exchanges a new one, and returns err.
+func (a *copilotAuth) check(err error) error {
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized {
+		return err
 	}
 
-	if errors.Is(ctx.Err(), context.Canceled) {
-		return pkgerrors.New("request canceled")
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.session = copilotSession{}
+
+	if path, pathErr := a.sessionPath(); pathErr == nil {
+		_ = os.Remove(path)
 	}
 
-	// Check for common errors
-	if strings.Contains(stderrStr, "not installed") || strings.Contains(stderrStr, "extension") {
-		return pkgerrors.New("GitHub Copilot CLI not available. Ensure gh copilot works")
+	return err
+}
+
+// load reads the cached session.
+func (a *copilotAuth) load() (copilotSession, error) {
+	path, err := a.sessionPath()
+	if err != nil {
+		return copilotSession{}, err
 	}
 
-	if strings.Contains(stderrStr, "auth") || strings.Contains(stderrStr, "login") {
-		return pkgerrors.New("Not authenticated with GitHub. Run: gh auth login")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return copilotSession{}, pkgerrors.Wrap(err, "failed to read copilot session")
+	}
+
+	var session copilotSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return copilotSession{}, pkgerrors.Wrap(err, "failed to parse copilot session")
+	}
+
+	return session, nil
+}
+
+// save caches the session for later runs. Failing to is not an error: the
+// next run exchanges a new token.
+func (a *copilotAuth) save() {
+	path, err := a.sessionPath()
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(a.session)
+	if err != nil {
+		return
 	}
 
-	if strings.Contains(stderrStr, "subscription") {
-		return pkgerrors.New("GitHub Copilot subscription required")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
 	}
 
-	return pkgerrors.Wrapf(err, "gh copilot failed: %s", stderrStr)
+	_ = os.WriteFile(path, data, 0o600)
+}
+
+func (a *copilotAuth) sessionPath() (string, error) {
+	if a.path != "" {
+		return a.path, nil
+	}
+
+	return copilotSessionPath()
+}
+
+// copilotSessionPath returns the file caching the Copilot session token,
+// under $XDG_CACHE_HOME/howto.
+func copilotSessionPath() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "howto", "copilot-session.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".cache", "howto", "copilot-session.json"), nil
+}
+
+// ghHostsLogin reads the github.com login of the GitHub CLI from its hosts.yml:
+// the token, unless gh keeps it in the system keyring, and whether there is a
+// login at all. Only the keys of the host itself are read, which is all the
+// YAML gh writes there needs.
+func ghHostsLogin() (string, bool) {
+	dir, err := ghConfigDir()
+	if err != nil {
+		return "", false
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return "", false
+	}
+
+	var (
+		token    string
+		loggedIn bool
+		inHost   bool
+		indent   int
+	)
+
+	for line := range strings.Lines(string(data)) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		depth := len(line) - len(strings.TrimLeft(line, " \t"))
+		if depth == 0 {
+			inHost, indent = strings.Trim(strings.TrimSuffix(trimmed, ":"), `"'`) == "github.com", 0
+			loggedIn = loggedIn || inHost
+
+			continue
+		}
+
+		if !inHost {
+			continue
+		}
+
+		// The first key sets the indentation of the host's own keys
+		if indent == 0 {
+			indent = depth
+		}
+
+		if key, value, ok := strings.Cut(trimmed, ":"); ok && depth == indent && key == "oauth_token" {
+			token = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+
+	return token, loggedIn
+}
+
+// ghConfigDir returns the configuration directory of the GitHub CLI.
+func ghConfigDir() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh"), nil
+	}
+
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to locate home directory")
+	}
+
+	return filepath.Join(home, ".config", "gh"), nil
+}
+
+// exchangeCopilotToken trades githubToken for a Copilot session token.
+func exchangeCopilotToken(ctx context.Context, tokenURL, githubToken string) (copilotSession, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return copilotSession{}, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Authorization", "token "+githubToken)
+	req.Header.Set("Accept", "application/json")
+
+	for name, value := range copilotHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return copilotSession{}, err
+	}
+
+	var tokenResp copilotTokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil && resp.StatusCode == http.StatusOK {
+		return copilotSession{}, pkgerrors.Wrap(err, "failed to parse copilot token")
+	}
+
+	message := tokenResp.Message
+	if tokenResp.ErrorDetails != nil && tokenResp.ErrorDetails.Message != "" {
+		message = tokenResp.ErrorDetails.Message
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return copilotSession{}, &Error{
+			Kind: KindAuth, StatusCode: resp.StatusCode,
+			Message: "GitHub token rejected (run gh auth login): " + message,
+		}
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		return copilotSession{}, &Error{
+			Kind: KindAuth, StatusCode: resp.StatusCode,
+			Message: "no GitHub Copilot access for this account (a Copilot subscription is required): " + message,
+		}
+	case resp.StatusCode != http.StatusOK:
+		return copilotSession{}, statusError(resp, body, "", message)
+	case tokenResp.Token == "":
+		return copilotSession{}, pkgerrors.New("no copilot token in response")
+	}
+
+	return copilotSession{Token: tokenResp.Token, ExpiresAt: tokenResp.ExpiresAt, API: tokenResp.Endpoints.API}, nil
 }
 
 // CleanCopilotResponse removes markdown formatting from Copilot's response.
@@ -123,17 +580,3 @@ func CleanCopilotResponse(response string) string {
 
 	return strings.TrimSpace(result)
 }
-
-// IsCopilotAvailable checks if GitHub Copilot CLI is available.
-func IsCopilotAvailable() bool {
-	// Check if gh is installed
-	ghPath, err := exec.LookPath("gh")
-	if err != nil {
-		return false
-	}
-
-	// Check if copilot works (the modern gh copilot is built-in, not an extension)
-	cmd := exec.Command(ghPath, "copilot", "--", "--version")
-
-	return cmd.Run() == nil
-}
@@ -0,0 +1,359 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// copilotServer fakes the token exchange and the Copilot chat API. GitHub
+// token "gh-token" has Copilot access, "no-copilot" has none; session tokens
+// are "session-<n>" for the nth exchange and "revoked" is rejected.
+func copilotServer(t *testing.T, expiresIn time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var exchanges atomic.Int32
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	checkHeaders := func(r *http.Request) {
+		if r.Header.Get("Copilot-Integration-Id") == "" || r.Header.Get("Editor-Version") == "" {
+			t.Errorf("%s sent no Copilot-Integration-Id or Editor-Version", r.URL.Path)
+		}
+	}
+
+	mux.HandleFunc("GET /copilot_internal/v2/token", func(w http.ResponseWriter, r *http.Request) {
+		checkHeaders(r)
+
+		switch r.Header.Get("Authorization") {
+		case "token gh-token", "token other-token":
+			n := exchanges.Add(1)
+			_, _ = fmt.Fprintf(w, `{"token":"session-%d","expires_at":%d,"refresh_in":1500,"endpoints":{"api":%q}}`,
+				n, time.Now().Add(expiresIn).Unix(), srv.URL)
+		case "token no-copilot":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"message":"Not Found","documentation_url":"https://docs.github.com/rest"}`)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"message":"Bad credentials","documentation_url":"https://docs.github.com/rest"}`)
+		}
+	})
+	mux.HandleFunc("POST /chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		checkHeaders(r)
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer session-") {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `unauthorized: token expired`)
+
+			return
+		}
+
+		if r.Header.Get("Accept") == "text/event-stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"```\\nls\"}}]}\n\n"+
+				"data: {\"choices\":[{\"delta\":{\"content\":\" -la\\n```\"}}]}\n\ndata: [DONE]\n\n")
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, "{\"choices\":[{\"message\":{\"role\":\"assistant\",\"content\":\"```bash\\nls -la\\n```\"}}]}")
+	})
+	mux.HandleFunc("GET /models", func(w http.ResponseWriter, r *http.Request) {
+		checkHeaders(r)
+		_, _ = fmt.Fprint(w, `{"data":[{"id":"gpt-4o","capabilities":{"type":"chat"}},`+
+			`{"id":"text-embedding-3-small","capabilities":{"type":"embeddings"}},`+
+			`{"id":"claude-sonnet-4","capabilities":{"type":"chat"}},{"id":"gpt-4o","capabilities":{"type":"chat"}}]}`)
+	})
+
+	return srv, &exchanges
+}
+
+// copilotProvider returns GitHubCopilot exchanging tokens at srv and caching
+// the session at path.
+func copilotProvider(srv *httptest.Server, path string) *Provider {
+	p := *GitHubCopilot
+	p.Backend = copilotBackend{tokenURL: srv.URL + "/copilot_internal/v2/token", auth: &copilotAuth{path: path}}
+
+	return &p
+}
+
+func TestCopilotBackend(t *testing.T) {
+	t.Parallel()
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+
+		srv, exchanges := copilotServer(t, time.Hour)
+		path := filepath.Join(t.TempDir(), "session.json")
+		p := copilotProvider(srv, path)
+
+		for range 2 {
+			got, err := p.Query(context.Background(), "gh-token", Request{Model: "gpt-4o"})
+			if err != nil || got != "ls -la" {
+				t.Fatalf("Query() = %q, %v; want ls -la", got, err)
+			}
+		}
+
+		if n := exchanges.Load(); n != 1 {
+			t.Errorf("two queries exchanged %d tokens, want 1", n)
+		}
+
+		// A later run reuses the session cached on disk
+		if _, err := copilotProvider(srv, path).Query(context.Background(), "gh-token", Request{Model: "gpt-4o"}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if n := exchanges.Load(); n != 1 {
+			t.Errorf("a new process exchanged a token despite the cached session (%d exchanges)", n)
+		}
+
+		// Another GitHub account needs its own session
+		if _, err := p.Query(context.Background(), "other-token", Request{Model: "gpt-4o"}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if n := exchanges.Load(); n != 2 {
+			t.Errorf("a query for another GitHub token made %d exchanges in all, want 2", n)
+		}
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		t.Parallel()
+
+		srv, exchanges := copilotServer(t, 30*time.Second)
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		for range 2 {
+			if _, err := p.Query(context.Background(), "gh-token", Request{Model: "gpt-4o"}); err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+		}
+
+		if n := exchanges.Load(); n != 2 {
+			t.Errorf("queries with an expiring session exchanged %d tokens, want 2", n)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		srv, _ := copilotServer(t, time.Hour)
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		var tokens []string
+
+		got, err := p.QueryStream(context.Background(), "gh-token", Request{Model: "gpt-4o"}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if err != nil || got != "ls -la" || !reflect.DeepEqual(tokens, []string{"```\nls", " -la\n```"}) {
+			t.Errorf("QueryStream() = %q, %v with tokens %q; want ls -la", got, err, tokens)
+		}
+	})
+
+	t.Run("list models", func(t *testing.T) {
+		t.Parallel()
+
+		srv, _ := copilotServer(t, time.Hour)
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		got, err := p.ListModels(context.Background(), "gh-token")
+		if want := []string{"gpt-4o", "claude-sonnet-4"}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ListModels() = %q, %v; want %q", got, err, want)
+		}
+	})
+
+	t.Run("token errors", func(t *testing.T) {
+		t.Parallel()
+
+		srv, _ := copilotServer(t, time.Hour)
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		tests := map[string]string{
+			"no-copilot": "no GitHub Copilot access for this account",
+			"bad-token":  "GitHub token rejected",
+		}
+
+		for githubToken, want := range tests {
+			_, err := p.Query(context.Background(), githubToken, Request{Model: "gpt-4o"})
+
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindAuth || !strings.Contains(err.Error(), want) {
+				t.Errorf("Query() with %s error = %v, want an auth error about %q", githubToken, err, want)
+			}
+		}
+	})
+
+	t.Run("rejected session", func(t *testing.T) {
+		t.Parallel()
+
+		srv, exchanges := copilotServer(t, time.Hour)
+		path := filepath.Join(t.TempDir(), "session.json")
+		p := copilotProvider(srv, path)
+
+		// A session revoked before its expiry is forgotten once rejected
+		auth := p.Backend.(copilotBackend).auth
+		auth.session = copilotSession{Key: hashHex([]byte("gh-token")), Token: "revoked", ExpiresAt: time.Now().Add(time.Hour).Unix(), API: srv.URL}
+
+		if _, err := p.Query(context.Background(), "gh-token", Request{Model: "gpt-4o"}); err == nil {
+			t.Fatal("Query() with a revoked session should fail")
+		}
+
+		if _, err := p.Query(context.Background(), "gh-token", Request{Model: "gpt-4o"}); err != nil {
+			t.Fatalf("Query() after a rejected session error = %v", err)
+		}
+
+		if n := exchanges.Load(); n != 1 {
+			t.Errorf("exchanged %d tokens after the rejected session, want 1", n)
+		}
+
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("session cache = %v, %v; want a file only the user can read", info, err)
+		}
+	})
+}
+
+// useGHConfig clears the GitHub tokens of the environment and gives the GitHub
+// CLI a config directory with hosts, the content of hosts.yml, if not empty.
+// PATH is cleared, so that gh cannot run.
+func useGHConfig(t *testing.T, hosts string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if hosts != "" {
+		if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	t.Setenv("GH_CONFIG_DIR", dir)
+	t.Setenv(GitHubTokenEnvVar, "")
+	t.Setenv(githubTokenFallbackEnvVar, "")
+	t.Setenv("PATH", "")
+}
+
+func TestCopilotCheckAuth(t *testing.T) {
+	srv, exchanges := copilotServer(t, time.Hour)
+
+	t.Run("environment token", func(t *testing.T) {
+		useGHConfig(t, "")
+		t.Setenv(GitHubTokenEnvVar, "gh-token")
+
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+		before := exchanges.Load()
+
+		if got, err := p.Backend.CheckAuth(p); err != nil || got != "gh-token" {
+			t.Fatalf("CheckAuth() = %q, %v; want gh-token", got, err)
+		}
+
+		if n := exchanges.Load() - before; n != 0 {
+			t.Errorf("CheckAuth() exchanged %d tokens, want none", n)
+		}
+
+		if _, err := p.Query(context.Background(), "gh-token", Request{Model: "gpt-4o"}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if n := exchanges.Load() - before; n != 1 {
+			t.Errorf("Query() exchanged %d tokens, want 1", n)
+		}
+	})
+
+	t.Run("hosts.yml token", func(t *testing.T) {
+		useGHConfig(t, "github.example.com:\n    oauth_token: other-token\ngithub.com:\n    users:\n        octocat:\n"+
+			"            oauth_token: old-token\n    git_protocol: https\n    user: octocat\n    oauth_token: gh-token\n")
+
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		if got, err := p.Backend.CheckAuth(p); err != nil || got != "gh-token" {
+			t.Errorf("CheckAuth() = %q, %v; want gh-token", got, err)
+		}
+	})
+
+	t.Run("hosts.yml login with the token in the keyring", func(t *testing.T) {
+		useGHConfig(t, "github.com:\n    git_protocol: https\n    user: octocat\n")
+
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		got, err := p.Backend.CheckAuth(p)
+		if err != nil || got != "" {
+			t.Fatalf("CheckAuth() = %q, %v; want no key and no error", got, err)
+		}
+
+		// The first request runs gh, which is not on PATH
+		if _, err := p.Query(context.Background(), got, Request{Model: "gpt-4o"}); err == nil ||
+			!strings.Contains(err.Error(), "GitHub CLI") {
+			t.Errorf("Query() without gh error = %v", err)
+		}
+	})
+
+	t.Run("cached session", func(t *testing.T) {
+		useGHConfig(t, "")
+
+		path := filepath.Join(t.TempDir(), "session.json")
+		p := copilotProvider(srv, path)
+
+		if _, err := p.Backend.CheckAuth(p); err == nil {
+			t.Fatal("CheckAuth() without a token or session error = nil")
+		}
+
+		session := fmt.Sprintf(`{"key":"k","token":"session-0","expires_at":%d}`, time.Now().Add(time.Hour).Unix())
+		if err := os.WriteFile(path, []byte(session), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+
+		if got, err := p.Backend.CheckAuth(p); err != nil || got != "" {
+			t.Errorf("CheckAuth() with a cached session = %q, %v; want no key and no error", got, err)
+		}
+	})
+
+	t.Run("no Copilot subscription", func(t *testing.T) {
+		useGHConfig(t, "")
+		t.Setenv(GitHubTokenEnvVar, "no-copilot")
+
+		p := copilotProvider(srv, filepath.Join(t.TempDir(), "session.json"))
+
+		// A GitHub token alone passes the check; the first request tells
+		if _, err := p.Backend.CheckAuth(p); err != nil {
+			t.Fatalf("CheckAuth() error = %v", err)
+		}
+
+		if _, err := p.Query(context.Background(), "no-copilot", Request{Model: "gpt-4o"}); err == nil ||
+			!strings.Contains(err.Error(), "no GitHub Copilot access") {
+			t.Errorf("Query() without Copilot access error = %v", err)
+		}
+	})
+}
+
+func TestCopilotGitHubToken(t *testing.T) {
+	t.Setenv(GitHubTokenEnvVar, "")
+	t.Setenv(githubTokenFallbackEnvVar, "from-github-token")
+
+	auth := &copilotAuth{}
+
+	if got, err := auth.token(context.Background()); err != nil || got != "from-github-token" {
+		t.Errorf("token() = %q, %v; want the GITHUB_TOKEN token", got, err)
+	}
+
+	t.Setenv(GitHubTokenEnvVar, "from-gh-token")
+
+	if got, err := auth.token(context.Background()); err != nil || got != "from-gh-token" {
+		t.Errorf("token() = %q, %v; want the GH_TOKEN token", got, err)
+	}
+
+	useGHConfig(t, "github.com:\n    oauth_token: from-hosts\n    user: octocat\n")
+
+	if got, err := auth.token(context.Background()); err != nil || got != "from-hosts" {
+		t.Errorf("token() = %q, %v; want the hosts.yml token", got, err)
+	}
+}
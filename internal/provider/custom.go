@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// Auth styles accepted by CustomEndpoint.Auth.
+const (
+	// AuthStyleBearer sends "Authorization: Bearer <key>".
+	AuthStyleBearer = "bearer"
+	// AuthStyleHeader sends the key verbatim in AuthHeader.
+	AuthStyleHeader = "header"
+	// AuthStyleNone sends no credentials.
+	AuthStyleNone = "none"
+)
+
+// DefaultCustomPriority places custom endpoints after the built-in API
+// providers and before GitHub Copilot during detection.
+const DefaultCustomPriority = 60
+
+// defaultAuthHeader is used by AuthStyleHeader when no header is named.
+const defaultAuthHeader = "api-key"
+
+// CustomEndpoint declares an OpenAI-compatible provider such as LM Studio,
+// vLLM, a llama.cpp server, OpenRouter or Groq.
+type CustomEndpoint struct {
+	Name string
+	// BaseURL is the API root; "/chat/completions" and "/models" are appended.
+	BaseURL string
+	// Auth is one of AuthStyleBearer, AuthStyleHeader or AuthStyleNone. It
+	// defaults to bearer when KeyEnv is set and none otherwise.
+	Auth string
+	// AuthHeader names the header carrying the key for AuthStyleHeader.
+	AuthHeader string
+	// KeyEnv is the environment variable holding the API key.
+	KeyEnv       string
+	DefaultModel string
+	// Headers are sent with every request (e.g. OpenRouter's HTTP-Referer).
+	Headers  map[string]string
+	Priority int
+}
+
+// Provider builds the provider described by c.
+func (c CustomEndpoint) Provider() (*Provider, error) {
+	if c.Name == "" {
+		return nil, pkgerrors.New("custom provider needs a name")
+	}
+
+	if c.BaseURL == "" {
+		return nil, pkgerrors.Newf("custom provider %s needs a base URL", c.Name)
+	}
+
+	p := &Provider{
+		Name:         c.Name,
+		Endpoint:     strings.TrimSuffix(c.BaseURL, "/") + "/chat/completions",
+		DefaultModel: c.DefaultModel,
+		EnvVar:       c.KeyEnv,
+		AuthHint:     c.BaseURL,
+		Headers:      c.Headers,
+		Priority:     c.Priority,
+		Backend:      openAIBackend{},
+	}
+
+	if p.Priority == 0 {
+		p.Priority = DefaultCustomPriority
+	}
+
+	auth := strings.ToLower(c.Auth)
+	if auth == "" {
+		auth = AuthStyleNone
+		if c.KeyEnv != "" {
+			auth = AuthStyleBearer
+		}
+	}
+
+	switch auth {
+	case AuthStyleBearer:
+		p.AuthType = AuthBearer
+	case AuthStyleHeader:
+		p.AuthType = AuthAPIKey
+
+		p.AuthHeader = c.AuthHeader
+		if p.AuthHeader == "" {
+			p.AuthHeader = defaultAuthHeader
+		}
+	case AuthStyleNone:
+		p.AuthType = AuthNone
+	default:
+		return nil, pkgerrors.Newf("custom provider %s: unknown auth style %q (use bearer, header or none)", c.Name, c.Auth)
+	}
+
+	if p.AuthType != AuthNone && c.KeyEnv == "" {
+		return nil, pkgerrors.Newf("custom provider %s: auth %q needs a key environment variable", c.Name, auth)
+	}
+
+	return p, nil
+}
+
+// RegisterCustom registers a custom endpoint. Unlike Register it reports
+// invalid declarations and name clashes as errors, since they come from user
+// configuration.
+func RegisterCustom(c CustomEndpoint) error {
+	p, err := c.Provider()
+	if err != nil {
+		return err
+	}
+
+	if lookup(p.Name) != nil {
+		return pkgerrors.Newf("custom provider %s clashes with an existing provider", p.Name)
+	}
+
+	Register(p)
+
+	return nil
+}
+
+// CustomEndpointsFromEnv applies HOWTO_<NAME>_BASE_URL, HOWTO_<NAME>_API_KEY
+// and HOWTO_<NAME>_MODEL environment variables to endpoints, adding a new
+// endpoint for every name that is only declared in the environment.
+// Environment values override configured ones. Names of built-in providers
+// are left to BuiltinOverridesFromEnv.
+func CustomEndpointsFromEnv(endpoints []CustomEndpoint) []CustomEndpoint {
+	result := make([]CustomEndpoint, len(endpoints))
+	copy(result, endpoints)
+
+	for _, name := range baseURLNames() {
+		prefix := "HOWTO_" + name
+
+		i := indexOfEndpoint(result, name)
+		if i < 0 {
+			if builtinForEnv(name) != nil {
+				continue
+			}
+
+			result = append(result, CustomEndpoint{Name: strings.ToLower(name)})
+			i = len(result) - 1
+		}
+
+		result[i].BaseURL = os.Getenv(prefix + "_BASE_URL")
+
+		if result[i].KeyEnv == "" && os.Getenv(prefix+"_API_KEY") != "" {
+			result[i].KeyEnv = prefix + "_API_KEY"
+		}
+
+		if model := os.Getenv(prefix + "_MODEL"); model != "" {
+			result[i].DefaultModel = model
+		}
+	}
+
+	return result
+}
+
+// BuiltinOverridesFromEnv points built-in providers speaking the OpenAI wire
+// format at HOWTO_<NAME>_BASE_URL, e.g. HOWTO_OPENAI_BASE_URL for a proxy,
+// and applies HOWTO_<NAME>_MODEL. Such a variable naming any other built-in
+// provider cannot be honored; a warning is returned for each instead.
+// Endpoints declared in the config file under a built-in name are skipped:
+// RegisterCustom reports those.
+func BuiltinOverridesFromEnv(endpoints []CustomEndpoint) []string {
+	var warnings []string
+
+	for _, name := range baseURLNames() {
+		p := builtinForEnv(name)
+		if p == nil || indexOfEndpoint(endpoints, name) >= 0 {
+			continue
+		}
+
+		prefix := "HOWTO_" + name
+
+		if _, ok := p.Backend.(openAIBackend); !ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s_BASE_URL is ignored: %s does not use the OpenAI API; set endpoint in a profile instead",
+				prefix, p.Name))
+
+			continue
+		}
+
+		p.Endpoint = strings.TrimSuffix(os.Getenv(prefix+"_BASE_URL"), "/") + "/chat/completions"
+
+		if model := os.Getenv(prefix + "_MODEL"); model != "" {
+			p.DefaultModel = model
+		}
+	}
+
+	return warnings
+}
+
+// baseURLNames returns the sorted <NAME> parts of all non-empty
+// HOWTO_<NAME>_BASE_URL variables.
+func baseURLNames() []string {
+	var names []string
+
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if value == "" || !strings.HasPrefix(key, "HOWTO_") || !strings.HasSuffix(key, "_BASE_URL") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "HOWTO_"), "_BASE_URL")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// builtinForEnv finds the registered provider an env var name part refers
+// to, treating "_" as a space or a dash.
+func builtinForEnv(envName string) *Provider {
+	for _, name := range []string{
+		envName,
+		strings.ReplaceAll(envName, "_", " "),
+		strings.ReplaceAll(envName, "_", "-"),
+	} {
+		if p := lookup(name); p != nil {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// indexOfEndpoint finds an endpoint whose name matches an env var name part,
+// treating "-" in configured names as "_".
+func indexOfEndpoint(endpoints []CustomEndpoint, envName string) int {
+	for i, c := range endpoints {
+		if strings.EqualFold(strings.ReplaceAll(c.Name, "-", "_"), envName) {
+			return i
+		}
+	}
+
+	return -1
+}
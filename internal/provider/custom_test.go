@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCustomEndpointProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		endpoint   CustomEndpoint
+		wantAuth   AuthType
+		wantHeader string
+		wantErr    bool
+	}{
+		{
+			name:     "key implies bearer",
+			endpoint: CustomEndpoint{Name: "groq", BaseURL: "https://api.groq.com/openai/v1/", KeyEnv: "GROQ_API_KEY"},
+			wantAuth: AuthBearer,
+		},
+		{
+			name:     "no key implies none",
+			endpoint: CustomEndpoint{Name: "lmstudio", BaseURL: "http://localhost:1234/v1"},
+			wantAuth: AuthNone,
+		},
+		{
+			name:       "header auth defaults to api-key",
+			endpoint:   CustomEndpoint{Name: "gw", BaseURL: "https://gw", Auth: "header", KeyEnv: "GW_KEY"},
+			wantAuth:   AuthAPIKey,
+			wantHeader: "api-key",
+		},
+		{
+			name:     "missing base URL",
+			endpoint: CustomEndpoint{Name: "broken"},
+			wantErr:  true,
+		},
+		{
+			name:     "bearer without key",
+			endpoint: CustomEndpoint{Name: "broken", BaseURL: "https://x", Auth: "bearer"},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown auth style",
+			endpoint: CustomEndpoint{Name: "broken", BaseURL: "https://x", Auth: "basic", KeyEnv: "K"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := tt.endpoint.Provider()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if p.AuthType != tt.wantAuth || p.AuthHeader != tt.wantHeader {
+				t.Errorf("Provider() auth = %v/%q, want %v/%q", p.AuthType, p.AuthHeader, tt.wantAuth, tt.wantHeader)
+			}
+
+			if p.Priority != DefaultCustomPriority {
+				t.Errorf("Provider() priority = %d, want %d", p.Priority, DefaultCustomPriority)
+			}
+		})
+	}
+}
+
+func TestCustomEndpointsFromEnv(t *testing.T) {
+	t.Setenv("HOWTO_OPENROUTER_BASE_URL", "https://openrouter.ai/api/v1")
+	t.Setenv("HOWTO_OPENROUTER_API_KEY", "or-key")
+	t.Setenv("HOWTO_OPENROUTER_MODEL", "meta-llama/llama-3.1-70b-instruct")
+	t.Setenv("HOWTO_INTERNAL_VLLM_BASE_URL", "https://vllm.env/v1")
+
+	configured := []CustomEndpoint{
+		{Name: "internal-vllm", BaseURL: "https://vllm.config/v1", KeyEnv: "VLLM_KEY", DefaultModel: "llama"},
+	}
+
+	got := CustomEndpointsFromEnv(configured)
+	if len(got) != 2 {
+		t.Fatalf("CustomEndpointsFromEnv() returned %d endpoints, want 2: %+v", len(got), got)
+	}
+
+	vllm := got[0]
+	if vllm.BaseURL != "https://vllm.env/v1" || vllm.KeyEnv != "VLLM_KEY" || vllm.DefaultModel != "llama" {
+		t.Errorf("env should override only the base URL of internal-vllm, got %+v", vllm)
+	}
+
+	router := got[1]
+	if router.Name != "openrouter" || router.KeyEnv != "HOWTO_OPENROUTER_API_KEY" ||
+		router.DefaultModel != "meta-llama/llama-3.1-70b-instruct" {
+		t.Errorf("env-only endpoint = %+v", router)
+	}
+
+	if configured[0].BaseURL != "https://vllm.config/v1" {
+		t.Error("CustomEndpointsFromEnv() modified its input")
+	}
+}
+
+func TestBuiltinOverridesFromEnv(t *testing.T) {
+	t.Setenv("HOWTO_OPENAI_BASE_URL", "https://proxy.internal/v1/")
+	t.Setenv("HOWTO_OPENAI_MODEL", "gpt-4o-mini")
+	t.Setenv("HOWTO_ANTHROPIC_BASE_URL", "https://proxy.internal/anthropic")
+
+	endpoint, model := OpenAI.Endpoint, OpenAI.DefaultModel
+	anthropicEndpoint := Anthropic.Endpoint
+
+	t.Cleanup(func() {
+		OpenAI.Endpoint, OpenAI.DefaultModel = endpoint, model
+	})
+
+	if got := CustomEndpointsFromEnv(nil); len(got) != 0 {
+		t.Fatalf("CustomEndpointsFromEnv() = %+v, want no custom endpoints for built-in names", got)
+	}
+
+	warnings := BuiltinOverridesFromEnv(nil)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "HOWTO_ANTHROPIC_BASE_URL") {
+		t.Errorf("warnings = %q, want one about HOWTO_ANTHROPIC_BASE_URL", warnings)
+	}
+
+	if OpenAI.Endpoint != "https://proxy.internal/v1/chat/completions" || OpenAI.DefaultModel != "gpt-4o-mini" {
+		t.Errorf("OpenAI = %s %s, want the proxy endpoint and gpt-4o-mini", OpenAI.Endpoint, OpenAI.DefaultModel)
+	}
+
+	if Anthropic.Endpoint != anthropicEndpoint {
+		t.Errorf("Anthropic endpoint changed to %s", Anthropic.Endpoint)
+	}
+
+	// A config entry under a built-in name is a clash RegisterCustom reports,
+	// not an override.
+	configured := []CustomEndpoint{{Name: "openai", BaseURL: "https://config/v1"}}
+
+	OpenAI.Endpoint = endpoint
+	if warnings := BuiltinOverridesFromEnv(configured); len(warnings) != 1 || OpenAI.Endpoint != endpoint {
+		t.Errorf("config entry named openai should not be treated as an override, got %s", OpenAI.Endpoint)
+	}
+
+	if got := CustomEndpointsFromEnv(configured); len(got) != 1 || got[0].BaseURL != "https://proxy.internal/v1/" {
+		t.Errorf("CustomEndpointsFromEnv() = %+v", got)
+	}
+}
+
+func TestRegisterCustom(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %q, want /v1/chat/completions", r.URL.Path)
+		}
+
+		if got := r.Header.Get("X-Gateway-Key"); got != "gw-secret" {
+			t.Errorf("X-Gateway-Key = %q, want gw-secret", got)
+		}
+
+		if r.Header.Get("Authorization") != "" {
+			t.Error("unexpected Authorization header")
+		}
+
+		if got := r.Header.Get("X-Team"); got != "infra" {
+			t.Errorf("X-Team = %q, want infra", got)
+		}
+
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"uptime"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GW_KEY", "gw-secret")
+
+	err := RegisterCustom(CustomEndpoint{
+		Name:         "gateway",
+		BaseURL:      srv.URL + "/v1",
+		Auth:         AuthStyleHeader,
+		AuthHeader:   "X-Gateway-Key",
+		KeyEnv:       "GW_KEY",
+		DefaultModel: "llama",
+		Headers:      map[string]string{"X-Team": "infra"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterCustom() error = %v", err)
+	}
+
+	t.Cleanup(func() { unregister("gateway") })
+
+	if err := RegisterCustom(CustomEndpoint{Name: "openai", BaseURL: "https://x"}); err == nil {
+		t.Error("RegisterCustom() expected clash with built-in OpenAI")
+	}
+
+	p, key, err := GetByName("Gateway")
+	if err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+
+	got, err := p.Query(context.Background(), key, Request{Model: p.DefaultModel, Prompt: "how long up"})
+	if err != nil || got != "uptime" {
+		t.Errorf("Query() = %q, %v; want %q", got, err, "uptime")
+	}
+
+	found := false
+
+	for _, info := range ListAll() {
+		if info.Name == "gateway" {
+			found = info.Configured
+		}
+	}
+
+	if !found {
+		t.Error("ListAll() does not report gateway as configured")
+	}
+}
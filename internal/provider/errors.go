@@ -0,0 +1,275 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies provider failures.
+type ErrorKind int
+
+const (
+	// KindUnknown is a failure that could not be classified.
+	KindUnknown ErrorKind = iota
+	// KindRateLimit means too many requests or tokens were sent recently.
+	KindRateLimit
+	// KindOverloaded means the provider is temporarily over capacity.
+	KindOverloaded
+	// KindServer is any other server-side failure (5xx).
+	KindServer
+	// KindNetwork means no response arrived, e.g. the connection was reset.
+	KindNetwork
+	// KindTimeout means the request ran past its deadline.
+	KindTimeout
+	// KindAuth means the credential was missing, invalid or lacks access.
+	KindAuth
+	// KindQuota means the account is out of credit or over its spending cap.
+	KindQuota
+	// KindInvalidRequest means the provider rejected the request itself, e.g.
+	// an unknown model or a prompt that is too long.
+	KindInvalidRequest
+	// KindBlocked means the provider's safety filters withheld the answer.
+	KindBlocked
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindRateLimit:
+		return "rate limited"
+	case KindOverloaded:
+		return "provider overloaded"
+	case KindServer:
+		return "server error"
+	case KindNetwork:
+		return "network error"
+	case KindTimeout:
+		return "request timed out"
+	case KindAuth:
+		return "authentication failed"
+	case KindQuota:
+		return "quota exhausted"
+	case KindInvalidRequest:
+		return "invalid request"
+	case KindBlocked:
+		return "blocked by safety filters"
+	case KindUnknown:
+	}
+
+	return "API error"
+}
+
+// Error is a classified provider failure.
+type Error struct {
+	Kind ErrorKind
+	// StatusCode is the HTTP status, or zero when none applies.
+	StatusCode int
+	// Message is the provider's explanation, if it gave one.
+	Message string
+	// RetryAfter is how long the provider asked to wait before retrying;
+	// zero when it gave no hint.
+	RetryAfter time.Duration
+
+	err error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" && e.err != nil {
+		msg = e.err.Error()
+	}
+
+	switch {
+	case e.StatusCode != 0:
+		return fmt.Sprintf("%s (status %d): %s", e.Kind, e.StatusCode, msg)
+	case msg == "":
+		return e.Kind.String()
+	default:
+		return e.Kind.String() + ": " + msg
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Retryable reports whether repeating the request may succeed. Rate limits,
+// overloaded or failing servers and network errors are transient; every other
+// kind is permanent.
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case KindRateLimit, KindOverloaded, KindServer, KindNetwork:
+		return true
+	case KindUnknown, KindTimeout, KindAuth, KindQuota, KindInvalidRequest, KindBlocked:
+	}
+
+	return false
+}
+
+// IsRetryable reports whether err is a provider failure worth retrying.
+func IsRetryable(err error) bool {
+	var e *Error
+
+	return errors.As(err, &e) && e.Retryable()
+}
+
+// ShouldFallback reports whether another provider might answer a request that
+// failed with err. Requests the provider rejected as invalid would fail
+// everywhere, and canceled ones were stopped by the user, so neither is
+// passed on; anything else (timeouts, auth, quota, rate limits, safety
+// blocks, server and network errors) is.
+func ShouldFallback(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var e *Error
+
+	return !errors.As(err, &e) || e.Kind != KindInvalidRequest
+}
+
+// maxErrorBodySize caps how much of an unparseable error body is reported.
+const maxErrorBodySize = 300
+
+// statusError classifies an unsuccessful HTTP response. errType is the error
+// type or code from the body (e.g. "overloaded_error" or
+// "insufficient_quota") and message its explanation; without one, the body
+// itself is reported.
+func statusError(resp *http.Response, body []byte, errType, message string) *Error {
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+		if len(message) > maxErrorBodySize {
+			message = message[:maxErrorBodySize] + "..."
+		}
+	}
+
+	return &Error{
+		Kind:       classify(resp.StatusCode, errType),
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: retryAfter(resp.Header, time.Now()),
+	}
+}
+
+// apiError classifies an error reported inside a successful response, e.g.
+// an error event in the middle of a stream.
+func apiError(errType, message string) *Error {
+	return &Error{Kind: classify(0, errType), Message: message}
+}
+
+// classify maps an HTTP status and a provider error type to a kind. The type
+// wins where it is more precise, e.g. OpenAI reports an exhausted quota as a
+// 429 with type insufficient_quota, Google an invalid key as a 400 with
+// reason API_KEY_INVALID and Azure a filtered prompt as a 400 with code
+// content_filter.
+func classify(status int, errType string) ErrorKind {
+	switch errType {
+	case "insufficient_quota", "billing_error":
+		return KindQuota
+	case "overloaded_error", "UNAVAILABLE", "ServiceUnavailableException", "ModelNotReadyException":
+		return KindOverloaded
+	case "rate_limit_error", "rate_limit_exceeded", "ThrottlingException":
+		return KindRateLimit
+	case "authentication_error", "permission_error", "invalid_api_key",
+		"API_KEY_INVALID", "UNAUTHENTICATED", "PERMISSION_DENIED",
+		"AccessDeniedException", "UnrecognizedClientException", "ExpiredTokenException":
+		return KindAuth
+	case "api_error", "server_error", "INTERNAL", "InternalServerException", "ModelStreamErrorException":
+		return KindServer
+	case "content_filter":
+		return KindBlocked
+	case "invalid_request_error", "not_found_error", "request_too_large", "model_not_found",
+		"INVALID_ARGUMENT", "NOT_FOUND", "FAILED_PRECONDITION",
+		"ValidationException", "ResourceNotFoundException":
+		if status == 0 || status < http.StatusInternalServerError {
+			return KindInvalidRequest
+		}
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return KindRateLimit
+	case status == http.StatusServiceUnavailable, status == statusOverloaded:
+		return KindOverloaded
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return KindAuth
+	case status == http.StatusPaymentRequired:
+		return KindQuota
+	case status == http.StatusRequestTimeout, status >= http.StatusInternalServerError:
+		return KindServer
+	case status >= http.StatusBadRequest:
+		return KindInvalidRequest
+	}
+
+	return KindUnknown
+}
+
+// statusOverloaded is Anthropic's status for an overloaded API.
+const statusOverloaded = 529
+
+// rateLimitResets pairs OpenAI-style reset headers with the remaining counts
+// they belong to.
+var rateLimitResets = [][2]string{
+	{"X-Ratelimit-Remaining-Requests", "X-Ratelimit-Reset-Requests"},
+	{"X-Ratelimit-Remaining-Tokens", "X-Ratelimit-Reset-Tokens"},
+}
+
+// retryAfter reads the server's hint on when to retry: Retry-After (seconds
+// or an HTTP date), else the reset time of an exhausted rate limit from the
+// x-ratelimit-reset-* headers, else the soonest reset.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+
+		if t, err := http.ParseTime(value); err == nil {
+			return max(t.Sub(now), 0)
+		}
+	}
+
+	var exhausted, soonest time.Duration
+
+	for _, pair := range rateLimitResets {
+		reset, ok := parseReset(header.Get(pair[1]))
+		if !ok {
+			continue
+		}
+
+		if header.Get(pair[0]) == "0" {
+			exhausted = max(exhausted, reset)
+		}
+
+		if soonest == 0 || reset < soonest {
+			soonest = reset
+		}
+	}
+
+	if exhausted > 0 {
+		return exhausted
+	}
+
+	return soonest
+}
+
+// parseReset parses a reset header value such as "1s", "6m0s" or "20ms", or
+// a plain number of seconds.
+func parseReset(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, true
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	return 0, false
+}
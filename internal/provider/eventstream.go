@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// maxEventStreamMessage bounds a single event stream message.
+const maxEventStreamMessage = 16 * 1024 * 1024
+
+// eventStreamPrelude is the size of the total and header lengths and their
+// checksum that start every message.
+const eventStreamPrelude = 12
+
+// eventStreamStringHeader is the type of string header values; other types
+// are skipped.
+const eventStreamStringHeader = 7
+
+// readEventStream parses an AWS event stream (application/vnd.amazon.eventstream)
+// and calls fn with the string headers and payload of every message.
+// Returning errStreamDone from fn ends the stream successfully.
+func readEventStream(r io.Reader, fn func(headers map[string]string, payload []byte) error) error {
+	prelude := make([]byte, eventStreamPrelude)
+
+	for {
+		if _, err := io.ReadFull(r, prelude); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return pkgerrors.Wrap(err, "failed to read stream")
+		}
+
+		// int64 holds the lengths and their sum without overflowing
+		total := int64(binary.BigEndian.Uint32(prelude[0:4]))
+		headersLen := int64(binary.BigEndian.Uint32(prelude[4:8]))
+
+		if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+			return pkgerrors.New("corrupt event stream: prelude checksum mismatch")
+		}
+
+		if total < eventStreamPrelude+4+headersLen || total > maxEventStreamMessage {
+			return pkgerrors.Newf("corrupt event stream: message of %d bytes", total)
+		}
+
+		message := make([]byte, total)
+		copy(message, prelude)
+
+		if _, err := io.ReadFull(r, message[eventStreamPrelude:]); err != nil {
+			return pkgerrors.Wrap(err, "failed to read stream")
+		}
+
+		if crc32.ChecksumIEEE(message[:total-4]) != binary.BigEndian.Uint32(message[total-4:]) {
+			return pkgerrors.New("corrupt event stream: message checksum mismatch")
+		}
+
+		headers, err := eventStreamHeaders(message[eventStreamPrelude : eventStreamPrelude+headersLen])
+		if err != nil {
+			return err
+		}
+
+		if err := fn(headers, message[eventStreamPrelude+headersLen:total-4]); err != nil {
+			return stopOnDone(err)
+		}
+	}
+}
+
+// eventStreamHeaders decodes the string headers of a message.
+func eventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := map[string]string{}
+
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, pkgerrors.New("corrupt event stream: truncated header")
+		}
+
+		name := string(b[1 : 1+nameLen])
+		valueType := b[1+nameLen]
+		b = b[2+nameLen:]
+
+		size, ok := eventStreamValueSize(valueType, b)
+		if !ok || len(b) < size {
+			return nil, pkgerrors.Newf("corrupt event stream: bad value for header %s", name)
+		}
+
+		if valueType == eventStreamStringHeader {
+			headers[name] = string(b[2:size])
+		}
+
+		b = b[size:]
+	}
+
+	return headers, nil
+}
+
+// eventStreamValueSize returns the encoded size of a header value of the
+// given type at the start of b.
+func eventStreamValueSize(valueType byte, b []byte) (int, bool) {
+	switch valueType {
+	case 0, 1: // true, false
+		return 0, true
+	case 2: // byte
+		return 1, true
+	case 3: // int16
+		return 2, true
+	case 4: // int32
+		return 4, true
+	case 5, 8: // int64, timestamp
+		return 8, true
+	case 6, eventStreamStringHeader: // byte array, string
+		if len(b) < 2 {
+			return 0, false
+		}
+
+		return 2 + int(binary.BigEndian.Uint16(b)), true
+	case 9: // uuid
+		return 16, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// eventStreamFrame encodes a message of total bytes with valid checksums,
+// whatever the lengths claim.
+func eventStreamFrame(total, headersLen uint32) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, total)
+	frame = binary.BigEndian.AppendUint32(frame, headersLen)
+	frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame))
+
+	for len(frame) < int(min(total, 64))-4 {
+		frame = append(frame, 0)
+	}
+
+	return binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(frame))
+}
+
+func TestReadEventStream(t *testing.T) {
+	t.Parallel()
+
+	valid := eventStreamMessage(map[string]string{":event-type": "chunk"}, `{"a":1}`)
+
+	tests := []struct {
+		name    string
+		stream  []byte
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"empty", nil, false},
+		{"oversized header length", eventStreamFrame(16, 0xFFFFFFF0), true},
+		{"header length past the message", eventStreamFrame(24, 12), true},
+		{"oversized message", eventStreamFrame(0xFFFFFFFF, 0), true},
+		{"bad prelude checksum", append([]byte{0, 0, 0, 16, 0, 0, 0, 0, 1, 2, 3, 4}, valid...), true},
+		{"bad message checksum", append(valid[:len(valid)-1:len(valid)-1], valid[len(valid)-1]^0xFF), true},
+		{"truncated message", valid[:len(valid)-2], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var events int
+
+			err := readEventStream(bytes.NewReader(tt.stream), func(map[string]string, []byte) error {
+				events++
+
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readEventStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && len(tt.stream) > 0 && events != 1 {
+				t.Errorf("readEventStream() delivered %d events, want 1", events)
+			}
+		})
+	}
+}
+
+func FuzzReadEventStream(f *testing.F) {
+	f.Add(eventStreamMessage(map[string]string{":message-type": "event"}, `{"delta":{"text":"ls"}}`))
+	f.Add(eventStreamFrame(16, 0xFFFFFFF0))
+
+	f.Fuzz(func(_ *testing.T, stream []byte) {
+		// Corrupt streams must fail, never panic
+		_ = readEventStream(bytes.NewReader(stream), func(map[string]string, []byte) error { return nil })
+	})
+}
@@ -0,0 +1,502 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// Gemini is Google's Gemini API, spoken natively. An endpoint ending in
+// /chat/completions, e.g. from a profile, selects Google's OpenAI-compatible
+// API instead.
+var Gemini = &Provider{
+	Name:         "Gemini",
+	Endpoint:     "https://generativelanguage.googleapis.com/v1beta",
+	DefaultModel: "gemini-2.0-flash",
+	EnvVar:       "GEMINI_API_KEY",
+	AuthType:     AuthAPIKey,
+	AuthHeader:   "X-Goog-Api-Key",
+	Priority:     30,
+	Backend:      geminiBackend{},
+}
+
+func init() {
+	Register(Gemini)
+}
+
+// GeminiSettings configures the Gemini provider.
+type GeminiSettings struct {
+	// SafetySettings are sent with every generateContent request.
+	SafetySettings []GeminiSafetySetting
+}
+
+// ConfigureGemini applies settings from the config file to Gemini.
+func ConfigureGemini(s GeminiSettings) {
+	Gemini.Backend = geminiBackend{settings: s}
+}
+
+// GeminiRequest represents a Gemini generateContent request.
+type GeminiRequest struct {
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent        `json:"contents"`
+	SafetySettings    []GeminiSafetySetting  `json:"safetySettings,omitempty"`
+	GenerationConfig  GeminiGenerationConfig `json:"generationConfig"`
+}
+
+// GeminiSafetySetting sets the threshold at which Gemini blocks content in a
+// harm category, e.g. BLOCK_ONLY_HIGH for HARM_CATEGORY_DANGEROUS_CONTENT.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// GeminiContent is a message in Gemini format; Role is "user" or "model".
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a piece of a Gemini message.
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GeminiGenerationConfig holds Gemini model parameters.
+type GeminiGenerationConfig struct {
+	MaxOutputTokens  int      `json:"maxOutputTokens"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse represents a generateContent response or stream chunk.
+type GeminiResponse struct {
+	Candidates     []GeminiCandidate     `json:"candidates"`
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *GeminiUsage          `json:"usageMetadata,omitempty"`
+	Error          *GeminiError          `json:"error,omitempty"`
+}
+
+// GeminiCandidate is one generated answer.
+type GeminiCandidate struct {
+	Content GeminiContent `json:"content"`
+	// FinishReason is e.g. "STOP", "MAX_TOKENS" or "SAFETY".
+	FinishReason  string               `json:"finishReason,omitempty"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GeminiPromptFeedback explains why a prompt was blocked.
+type GeminiPromptFeedback struct {
+	BlockReason   string               `json:"blockReason,omitempty"`
+	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GeminiSafetyRating rates content in one harm category.
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// GeminiUsage is the token count of a response.
+type GeminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+// GeminiError represents a Google API error object.
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Status is the canonical error code, e.g. "INVALID_ARGUMENT".
+	Status  string `json:"status"`
+	Details []struct {
+		// Reason is set on ErrorInfo details, e.g. "API_KEY_INVALID".
+		Reason string `json:"reason,omitempty"`
+	} `json:"details,omitempty"`
+}
+
+// kind returns the most specific of the error's reason and status; Google
+// reports an invalid key as INVALID_ARGUMENT with reason API_KEY_INVALID.
+func (e *GeminiError) kind() string {
+	for _, d := range e.Details {
+		if d.Reason != "" {
+			return d.Reason
+		}
+	}
+
+	return e.Status
+}
+
+// geminiModelsResponse represents a models.list response.
+type geminiModelsResponse struct {
+	Models []struct {
+		// Name is e.g. "models/gemini-2.0-flash".
+		Name                       string   `json:"name"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string       `json:"nextPageToken"`
+	Error         *GeminiError `json:"error,omitempty"`
+}
+
+// blockedFinishReasons are the finish reasons of a candidate withheld by
+// Google's filters rather than completed.
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// geminiBackend speaks the Gemini generateContent API.
+type geminiBackend struct {
+	settings GeminiSettings
+}
+
+func (geminiBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true}
+}
+
+func (geminiBackend) CheckAuth(p *Provider) (string, error) {
+	return envKeyAuth(p)
+}
+
+func (b geminiBackend) Query(ctx context.Context, p *Provider, apiKey string, r Request) (string, error) {
+	if compat, ok := geminiCompat(p); ok {
+		return openAIBackend{}.Query(ctx, compat, apiKey, r)
+	}
+
+	req, err := b.newRequest(ctx, p, apiKey, r, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", geminiStatusError(resp, body)
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return "", pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	if geminiResp.Error != nil {
+		return "", apiError(geminiResp.Error.kind(), geminiResp.Error.Message)
+	}
+
+	if err := geminiResp.blocked(); err != nil {
+		return "", err
+	}
+
+	text := geminiResp.text()
+	if text == "" {
+		return "", pkgerrors.Newf("no response from %s%s", p.Name, geminiResp.finishReason())
+	}
+
+	r.reportUsage(geminiResp.UsageMetadata.usage())
+
+	return text, nil
+}
+
+func (b geminiBackend) QueryStream(
+	ctx context.Context, p *Provider, apiKey string, r Request, onToken TokenFunc,
+) (string, error) {
+	if compat, ok := geminiCompat(p); ok {
+		return openAIBackend{}.QueryStream(ctx, compat, apiKey, r, onToken)
+	}
+
+	req, err := b.newRequest(ctx, p, apiKey, r, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", requestError(ctx, err, "failed to read response")
+		}
+
+		return "", geminiStatusError(resp, body)
+	}
+
+	var (
+		sb    strings.Builder
+		usage *Usage
+		last  GeminiResponse
+	)
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return pkgerrors.Wrap(err, "failed to parse stream chunk")
+		}
+
+		if chunk.Error != nil {
+			return apiError(chunk.Error.kind(), chunk.Error.Message)
+		}
+
+		if err := chunk.blocked(); err != nil {
+			return err
+		}
+
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata.usage()
+		}
+
+		if text := chunk.text(); text != "" {
+			sb.WriteString(text)
+			onToken(text)
+		}
+
+		last = chunk
+
+		return nil
+	})
+	if err != nil {
+		return "", requestError(ctx, err, "stream failed")
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.Newf("no response from %s%s", p.Name, last.finishReason())
+	}
+
+	r.reportUsage(usage)
+
+	return sb.String(), nil
+}
+
+func (geminiBackend) ListModels(ctx context.Context, p *Provider, apiKey string) ([]string, error) {
+	if compat, ok := geminiCompat(p); ok {
+		return openAIBackend{}.ListModels(ctx, compat, apiKey)
+	}
+
+	var (
+		models []string
+		page   string
+	)
+
+	for {
+		endpoint := strings.TrimSuffix(p.Endpoint, "/") + "/models?pageSize=1000"
+		if page != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(page)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to create request")
+		}
+
+		setAuthHeaders(req, p, apiKey)
+
+		resp, body, err := doRequestResponse(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, geminiStatusError(resp, body)
+		}
+
+		var modelsResp geminiModelsResponse
+		if err := json.Unmarshal(body, &modelsResp); err != nil {
+			return nil, pkgerrors.Wrap(err, "failed to parse response")
+		}
+
+		for _, m := range modelsResp.Models {
+			for _, method := range m.SupportedGenerationMethods {
+				if method == "generateContent" {
+					models = append(models, strings.TrimPrefix(m.Name, "models/"))
+
+					break
+				}
+			}
+		}
+
+		if page = modelsResp.NextPageToken; page == "" {
+			return models, nil
+		}
+	}
+}
+
+// geminiCompat returns p set up for Google's OpenAI-compatible API when its
+// endpoint points there.
+func geminiCompat(p *Provider) (*Provider, bool) {
+	if !strings.HasSuffix(p.Endpoint, "/chat/completions") {
+		return nil, false
+	}
+
+	compat := *p
+	compat.AuthType = AuthBearer
+
+	return &compat, true
+}
+
+// newRequest builds a generateContent or streamGenerateContent HTTP request
+// for r.
+func (b geminiBackend) newRequest(ctx context.Context, p *Provider, apiKey string, r Request, stream bool) (*http.Request, error) {
+	body := geminiRequestBody(r)
+	body.SafetySettings = b.settings.SafetySettings
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to marshal request")
+	}
+
+	endpoint := strings.TrimSuffix(p.Endpoint, "/") + "/models/" + url.PathEscape(r.Model) + ":generateContent"
+	if stream {
+		endpoint = strings.TrimSuffix(endpoint, ":generateContent") + ":streamGenerateContent?alt=sse"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeaders(req, p, apiKey)
+
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+// geminiRequestBody translates r into a generateContent request body: system
+// turns move to the system instruction and assistant turns become "model"
+// turns.
+func geminiRequestBody(r Request) GeminiRequest {
+	var system []string
+
+	body := GeminiRequest{
+		GenerationConfig: GeminiGenerationConfig{
+			MaxOutputTokens: r.maxTokens(),
+			Temperature:     r.Temperature,
+			StopSequences:   r.Stop,
+		},
+	}
+
+	for _, t := range r.turns() {
+		role := "user"
+
+		switch t.Role {
+		case RoleSystem:
+			system = append(system, t.Content)
+
+			continue
+		case RoleAssistant:
+			role = "model"
+		case RoleUser:
+		}
+
+		body.Contents = append(body.Contents, GeminiContent{Role: role, Parts: []GeminiPart{{Text: t.Content}}})
+	}
+
+	if len(system) > 0 {
+		body.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: strings.Join(system, "\n\n")}}}
+	}
+
+	if r.Format == FormatJSON {
+		body.GenerationConfig.ResponseMimeType = "application/json"
+	}
+
+	return body
+}
+
+// geminiStatusError classifies an unsuccessful Gemini API response.
+func geminiStatusError(resp *http.Response, body []byte) error {
+	var geminiResp GeminiResponse
+	if json.Unmarshal(body, &geminiResp) == nil && geminiResp.Error != nil {
+		return statusError(resp, body, geminiResp.Error.kind(), geminiResp.Error.Message)
+	}
+
+	return statusError(resp, body, "", "")
+}
+
+// text returns the text of the first candidate.
+func (r *GeminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		sb.WriteString(part.Text)
+	}
+
+	return sb.String()
+}
+
+// blocked reports a prompt or answer withheld by Google's safety filters,
+// naming the reason and the harm categories that tripped them.
+func (r *GeminiResponse) blocked() error {
+	if f := r.PromptFeedback; f != nil && f.BlockReason != "" {
+		return &Error{Kind: KindBlocked, Message: "prompt blocked" + blockDetail(f.BlockReason, f.SafetyRatings)}
+	}
+
+	if len(r.Candidates) > 0 && r.text() == "" {
+		c := r.Candidates[0]
+		if blockedFinishReasons[c.FinishReason] {
+			return &Error{Kind: KindBlocked, Message: "response blocked" + blockDetail(c.FinishReason, c.SafetyRatings)}
+		}
+	}
+
+	return nil
+}
+
+// finishReason describes why the first candidate ended, for reporting an
+// empty answer.
+func (r *GeminiResponse) finishReason() string {
+	if len(r.Candidates) == 0 || r.Candidates[0].FinishReason == "" {
+		return ""
+	}
+
+	return " (finish reason " + r.Candidates[0].FinishReason + ")"
+}
+
+// blockDetail formats a block reason and the categories rated as blocked or
+// at least medium risk, e.g. " (SAFETY: HARM_CATEGORY_DANGEROUS_CONTENT)".
+func blockDetail(reason string, ratings []GeminiSafetyRating) string {
+	var categories []string
+
+	for _, rating := range ratings {
+		if rating.Blocked || rating.Probability == "MEDIUM" || rating.Probability == "HIGH" {
+			categories = append(categories, rating.Category)
+		}
+	}
+
+	if len(categories) == 0 {
+		return " (" + reason + ")"
+	}
+
+	return " (" + reason + ": " + strings.Join(categories, ", ") + ")"
+}
+
+// usage converts u, which may be nil, to a Usage.
+func (u *GeminiUsage) usage() *Usage {
+	if u == nil {
+		return nil
+	}
+
+	return &Usage{InputTokens: u.PromptTokenCount, OutputTokens: u.CandidatesTokenCount}
+}
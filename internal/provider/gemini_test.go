@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// geminiServer fakes the Gemini API endpoints used by the backend. The model
+// name picks the answer.
+func geminiServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1beta/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = fmt.Fprint(w, `{"models":[`+
+				`{"name":"models/gemini-2.0-flash","supportedGenerationMethods":["generateContent","countTokens"]},`+
+				`{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}`+
+				`],"nextPageToken":"next"}`)
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"models":[{"name":"models/gemini-2.5-pro","supportedGenerationMethods":["generateContent"]}]}`)
+	})
+	mux.HandleFunc("POST /v1beta/models/{call}", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Goog-Api-Key"); got != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT",`+
+				`"details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"API_KEY_INVALID"}]}}`)
+
+			return
+		}
+
+		var req GeminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "Reply with a command." {
+			t.Errorf("request system instruction = %+v", req.SystemInstruction)
+		}
+
+		model, method, _ := strings.Cut(r.PathValue("call"), ":")
+
+		switch model {
+		case "blocked-prompt":
+			_, _ = fmt.Fprint(w, `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[`+
+				`{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"},`+
+				`{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}}`)
+		case "blocked-response":
+			_, _ = fmt.Fprint(w, `{"candidates":[{"content":{"role":"model"},"finishReason":"SAFETY","safetyRatings":[`+
+				`{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"MEDIUM","blocked":true}]}]}`)
+		default:
+			if method == "streamGenerateContent" {
+				if r.URL.Query().Get("alt") != "sse" {
+					t.Errorf("stream request without alt=sse: %s", r.URL)
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"du"}]}}]}`+"\n\n"+
+					`data: {"candidates":[{"content":{"role":"model","parts":[{"text":" -sh"}]},"finishReason":"STOP"}],`+
+					`"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":3}}`+"\n\n")
+
+				return
+			}
+
+			_, _ = fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"df -h"}]},"finishReason":"STOP"}],`+
+				`"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":4}}`)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGeminiBackend(t *testing.T) {
+	t.Parallel()
+
+	srv := geminiServer(t)
+	p := &Provider{
+		Name: "Gemini", Endpoint: srv.URL + "/v1beta", AuthType: AuthAPIKey, AuthHeader: "X-Goog-Api-Key",
+		Backend: geminiBackend{}, Retry: RetryPolicy{MaxAttempts: 1},
+	}
+	ctx := context.Background()
+	request := func(model string) Request {
+		return Request{Model: model, System: "Reply with a command.", Prompt: "disk usage"}
+	}
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+
+		var usage Usage
+
+		r := request("gemini-2.0-flash")
+		r.OnUsage = func(u Usage) { usage = u }
+
+		got, err := p.Query(ctx, "key", r)
+		if err != nil || got != "df -h" {
+			t.Fatalf("Query() = %q, %v; want df -h", got, err)
+		}
+
+		if usage != (Usage{InputTokens: 12, OutputTokens: 4}) {
+			t.Errorf("Query() reported usage %+v", usage)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			tokens []string
+			usage  Usage
+		)
+
+		r := request("gemini-2.0-flash")
+		r.OnUsage = func(u Usage) { usage = u }
+
+		got, err := p.QueryStream(ctx, "key", r, func(token string) { tokens = append(tokens, token) })
+		if err != nil || got != "du -sh" || len(tokens) != 2 {
+			t.Fatalf("QueryStream() = %q, %v with tokens %q; want du -sh in 2 tokens", got, err, tokens)
+		}
+
+		if usage != (Usage{InputTokens: 12, OutputTokens: 3}) {
+			t.Errorf("QueryStream() reported usage %+v", usage)
+		}
+	})
+
+	t.Run("safety blocks", func(t *testing.T) {
+		t.Parallel()
+
+		for model, want := range map[string]string{
+			"blocked-prompt":   "blocked by safety filters: prompt blocked (SAFETY: HARM_CATEGORY_DANGEROUS_CONTENT)",
+			"blocked-response": "blocked by safety filters: response blocked (SAFETY: HARM_CATEGORY_DANGEROUS_CONTENT)",
+		} {
+			_, err := p.Query(ctx, "key", request(model))
+
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindBlocked || err.Error() != want {
+				t.Errorf("Query(%s) error = %v, want %q", model, err, want)
+			}
+
+			if !ShouldFallback(err) || IsRetryable(err) {
+				t.Errorf("Query(%s) error should fall back without retrying", model)
+			}
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := p.Query(ctx, "wrong", request("gemini-2.0-flash"))
+
+		var e *Error
+		if !errors.As(err, &e) || e.Kind != KindAuth || !strings.Contains(err.Error(), "API key not valid") {
+			t.Errorf("Query() error = %v, want an auth error", err)
+		}
+	})
+
+	t.Run("list models", func(t *testing.T) {
+		t.Parallel()
+
+		models, err := p.ListModels(ctx, "key")
+		if want := []string{"gemini-2.0-flash", "gemini-2.5-pro"}; err != nil || !reflect.DeepEqual(models, want) {
+			t.Errorf("ListModels() = %v, %v; want %v", models, err, want)
+		}
+	})
+}
+
+func TestGeminiCompat(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/openai/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("request to %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ls"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := *Gemini
+	p.Endpoint = srv.URL + "/v1beta/openai/chat/completions"
+
+	if got, err := p.Query(context.Background(), "key", Request{Model: "gemini-2.0-flash"}); err != nil || got != "ls" {
+		t.Errorf("Query() = %q, %v; want ls from the OpenAI-compatible API", got, err)
+	}
+}
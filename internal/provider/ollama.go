@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// OllamaHostEnvVar points at the Ollama daemon, as with the ollama CLI.
+const OllamaHostEnvVar = "OLLAMA_HOST"
+
+// ollamaDefaultHost is where the Ollama daemon listens by default.
+const ollamaDefaultHost = "http://localhost:11434"
+
+// ollamaProbeTimeout bounds the health probe run during detection.
+const ollamaProbeTimeout = 500 * time.Millisecond
+
+// Ollama is a local Ollama daemon. It needs no API key; Endpoint, when set
+// (e.g. from a profile), is the daemon's base URL and overrides OLLAMA_HOST.
+var Ollama = &Provider{
+	Name:         "Ollama",
+	DefaultModel: "llama3.2",
+	AuthType:     AuthNone,
+	AuthHint:     OllamaHostEnvVar + " (local)",
+	Priority:     50,
+	Backend:      ollamaBackend{},
+}
+
+func init() {
+	Register(Ollama)
+}
+
+// OllamaChatRequest represents an Ollama /api/chat request.
+type OllamaChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	// Format is "json" to constrain the response to JSON.
+	Format  string         `json:"format,omitempty"`
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions holds Ollama model parameters.
+type OllamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// OllamaChatResponse represents an Ollama /api/chat response or stream line.
+type OllamaChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	// PromptEvalCount and EvalCount are the input and output token counts,
+	// set once the response is done.
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// usage returns the token counts of a finished response.
+func (r *OllamaChatResponse) usage() *Usage {
+	if !r.Done {
+		return nil
+	}
+
+	return &Usage{InputTokens: r.PromptEvalCount, OutputTokens: r.EvalCount}
+}
+
+// ollamaTagsResponse represents an Ollama /api/tags response.
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// ollamaBackend speaks the native Ollama API.
+type ollamaBackend struct{}
+
+func (ollamaBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true, Local: true}
+}
+
+// CheckAuth reports the daemon as available when it answers a health probe.
+func (ollamaBackend) CheckAuth(p *Provider) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaProbeTimeout)
+	defer cancel()
+
+	base := ollamaBaseURL(p)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/version", nil)
+	if err != nil {
+		return "", pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	_, status, err := doRequest(ctx, req)
+	if err != nil || status != http.StatusOK {
+		return "", pkgerrors.Newf("Ollama is not running at %s (set %s or run: ollama serve)", base, OllamaHostEnvVar)
+	}
+
+	return "", nil
+}
+
+func (ollamaBackend) Query(ctx context.Context, p *Provider, _ string, r Request) (string, error) {
+	req, err := newOllamaChatRequest(ctx, p, r, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", ollamaStatusError(resp, body)
+	}
+
+	var chatResp OllamaChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", pkgerrors.Wrapf(err, "failed to parse response: %s", string(body))
+	}
+
+	if chatResp.Error != "" {
+		return "", apiError("", chatResp.Error)
+	}
+
+	if chatResp.Message.Content == "" {
+		return "", pkgerrors.New("no response from Ollama")
+	}
+
+	r.reportUsage(chatResp.usage())
+
+	return chatResp.Message.Content, nil
+}
+
+// QueryStream reads Ollama's newline-delimited JSON stream.
+func (ollamaBackend) QueryStream(
+	ctx context.Context, p *Provider, _ string, r Request, onToken TokenFunc,
+) (string, error) {
+	req, err := newOllamaChatRequest(ctx, p, r, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", requestError(ctx, err, "failed to read response")
+		}
+
+		return "", ollamaStatusError(resp, body)
+	}
+
+	var (
+		sb    strings.Builder
+		usage *Usage
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", pkgerrors.Wrap(err, "failed to parse stream chunk")
+		}
+
+		if chunk.Error != "" {
+			return "", apiError("", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			sb.WriteString(chunk.Message.Content)
+			onToken(chunk.Message.Content)
+		}
+
+		if chunk.Done {
+			usage = chunk.usage()
+
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", requestError(ctx, err, "stream failed")
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.New("no response from Ollama")
+	}
+
+	r.reportUsage(usage)
+
+	return sb.String(), nil
+}
+
+// ollamaStatusError classifies an unsuccessful chat response.
+func ollamaStatusError(resp *http.Response, body []byte) error {
+	var chatResp OllamaChatResponse
+	if json.Unmarshal(body, &chatResp) == nil && chatResp.Error != "" {
+		return statusError(resp, body, "", chatResp.Error)
+	}
+
+	return statusError(resp, body, "", "")
+}
+
+// ListModels returns the locally pulled models.
+func (ollamaBackend) ListModels(ctx context.Context, p *Provider, _ string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ollamaBaseURL(p)+"/api/tags", nil)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	body, status, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		return nil, pkgerrors.Newf("API returned status %d: %s", status, string(body))
+	}
+
+	var tags ollamaTagsResponse
+	if err := json.Unmarshal(body, &tags); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	models := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+
+	return models, nil
+}
+
+// ollamaChatRequestBody translates r into an /api/chat request body.
+func ollamaChatRequestBody(r Request, stream bool) OllamaChatRequest {
+	body := OllamaChatRequest{
+		Model:    r.Model,
+		Messages: chatMessages(r.turns()),
+		Stream:   stream,
+		Options:  &OllamaOptions{NumPredict: r.maxTokens(), Temperature: r.Temperature, Stop: r.Stop},
+	}
+
+	if r.Format == FormatJSON {
+		body.Format = "json"
+	}
+
+	return body
+}
+
+// newOllamaChatRequest builds an /api/chat HTTP request for r.
+func newOllamaChatRequest(ctx context.Context, p *Provider, r Request, stream bool) (*http.Request, error) {
+	jsonData, err := json.Marshal(ollamaChatRequestBody(r, stream))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to marshal request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ollamaBaseURL(p)+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// ollamaBaseURL returns the daemon URL from the provider endpoint, OLLAMA_HOST
+// or the default, accepting the bare host:port form the ollama CLI allows.
+func ollamaBaseURL(p *Provider) string {
+	host := p.Endpoint
+	if host == "" {
+		host = os.Getenv(OllamaHostEnvVar)
+	}
+
+	if host == "" {
+		return ollamaDefaultHost
+	}
+
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	return strings.TrimSuffix(host, "/")
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ollamaServer fakes the Ollama daemon endpoints used by the backend.
+func ollamaServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/version", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"version":"0.5.0"}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest"},{"name":"qwen2.5-coder:7b"}]}`)
+	})
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"error":"model \"missing\" not found, try pulling it first"}`)
+
+			return
+		}
+
+		if !req.Stream {
+			_, _ = fmt.Fprint(w, `{"message":{"role":"assistant","content":"ls -la"},"done":true,"prompt_eval_count":30,"eval_count":4}`)
+
+			return
+		}
+
+		for _, part := range []string{"ls", " -la"} {
+			_, _ = fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", part)
+		}
+
+		_, _ = fmt.Fprint(w, `{"message":{"role":"assistant","content":""},"done":true}`+"\n")
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOllamaBackend(t *testing.T) {
+	t.Parallel()
+
+	srv := ollamaServer(t)
+	p := &Provider{Name: "Ollama", Endpoint: srv.URL, Backend: ollamaBackend{}}
+	ctx := context.Background()
+
+	t.Run("health probe", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := p.Backend.CheckAuth(p); err != nil {
+			t.Errorf("CheckAuth() error = %v", err)
+		}
+
+		down := &Provider{Name: "Ollama", Endpoint: "http://127.0.0.1:1", Backend: ollamaBackend{}}
+		if _, err := down.Backend.CheckAuth(down); err == nil {
+			t.Error("CheckAuth() expected error for unreachable daemon")
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+
+		var usage Usage
+
+		got, err := p.Query(ctx, "", Request{Model: "llama3.2", OnUsage: func(u Usage) { usage = u }})
+		if err != nil || got != "ls -la" {
+			t.Errorf("Query() = %q, %v; want %q", got, err, "ls -la")
+		}
+
+		if want := (Usage{InputTokens: 30, OutputTokens: 4}); usage != want {
+			t.Errorf("Query() usage = %+v, want %+v", usage, want)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		var tokens []string
+
+		got, err := p.QueryStream(ctx, "", Request{Model: "llama3.2"}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if err != nil || got != "ls -la" || len(tokens) != 2 {
+			t.Errorf("QueryStream() = %q (%q), %v; want %q in 2 tokens", got, tokens, err, "ls -la")
+		}
+	})
+
+	t.Run("missing model", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := p.Query(ctx, "", Request{Model: "missing"})
+		if err == nil || !strings.Contains(err.Error(), "try pulling it first") {
+			t.Errorf("Query() error = %v, want model not found", err)
+		}
+	})
+
+	t.Run("list models", func(t *testing.T) {
+		t.Parallel()
+
+		models, err := p.ListModels(ctx, "")
+		if err != nil || strings.Join(models, ",") != "llama3.2:latest,qwen2.5-coder:7b" {
+			t.Errorf("ListModels() = %v, %v", models, err)
+		}
+	})
+}
+
+func TestOllamaBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		env      string
+		want     string
+	}{
+		{name: "default", want: "http://localhost:11434"},
+		{name: "bare host from env", env: "gpu-box:11434", want: "http://gpu-box:11434"},
+		{name: "url from env", env: "https://ollama.internal/", want: "https://ollama.internal"},
+		{name: "endpoint beats env", endpoint: "http://profile:1", env: "env:2", want: "http://profile:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(OllamaHostEnvVar, tt.env)
+
+			got := ollamaBaseURL(&Provider{Endpoint: tt.endpoint})
+			if got != tt.want {
+				t.Errorf("ollamaBaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// ChatRequest represents a chat completion request.
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	// MaxTokens is understood by every compatible server, while OpenAI's
+	// reasoning models only accept MaxCompletionTokens.
+	MaxTokens           int                 `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                 `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64            `json:"temperature,omitempty"`
+	Stop                []string            `json:"stop,omitempty"`
+	ResponseFormat      *ChatResponseFormat `json:"response_format,omitempty"`
+	Stream              bool                `json:"stream,omitempty"`
+	// StreamOptions asks for a final chunk carrying the usage of a stream.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions configures a streamed chat completion.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatResponseFormat constrains a chat completion, e.g. to a JSON object.
+type ChatResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// Message represents a chat message.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatResponse represents a chat completion response.
+type ChatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage,omitempty"`
+	Error *APIError  `json:"error,omitempty"`
+}
+
+// ChatUsage is the token count of a chat completion.
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// usage converts u, which may be nil, to a Usage.
+func (u *ChatUsage) usage() *Usage {
+	if u == nil {
+		return nil
+	}
+
+	return &Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+}
+
+// APIError represents an API error response.
+type APIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	// Code is a string such as "insufficient_quota" for OpenAI, but some
+	// compatible servers send a number.
+	Code any `json:"code"`
+}
+
+// kind returns the most specific of the error's type and code.
+func (e *APIError) kind() string {
+	if code, ok := e.Code.(string); ok && code != "" {
+		return code
+	}
+
+	return e.Type
+}
+
+// chatStreamChunk represents one chat.completion.chunk event.
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	// Usage is only set on the last chunk.
+	Usage *ChatUsage `json:"usage,omitempty"`
+	Error *APIError  `json:"error,omitempty"`
+}
+
+// modelsResponse represents an OpenAI-compatible /models response.
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+// OpenAI-compatible providers.
+var (
+	OpenAI = &Provider{
+		Name:         "OpenAI",
+		Endpoint:     "https://api.openai.com/v1/chat/completions",
+		DefaultModel: "gpt-4o",
+		EnvVar:       "OPENAI_API_KEY",
+		AuthType:     AuthBearer,
+		Priority:     10,
+		Backend:      openAIBackend{maxCompletionTokens: true},
+	}
+
+	DeepSeek = &Provider{
+		Name:         "DeepSeek",
+		Endpoint:     "https://api.deepseek.com/chat/completions",
+		DefaultModel: "deepseek-chat",
+		EnvVar:       "DEEPSEEK_API_KEY",
+		AuthType:     AuthBearer,
+		Priority:     40,
+		Backend:      openAIBackend{},
+	}
+)
+
+func init() {
+	Register(OpenAI)
+	Register(DeepSeek)
+}
+
+// openAIBackend speaks the OpenAI chat/completions wire format.
+type openAIBackend struct {
+	// maxCompletionTokens sends the response cap as max_completion_tokens,
+	// which OpenAI itself requires but compatible servers may not know.
+	maxCompletionTokens bool
+}
+
+func (openAIBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: true, Streaming: true}
+}
+
+func (openAIBackend) CheckAuth(p *Provider) (string, error) {
+	if p.AuthType == AuthNone {
+		return "", nil
+	}
+
+	return envKeyAuth(p)
+}
+
+func (b openAIBackend) Query(ctx context.Context, p *Provider, apiKey string, r Request) (string, error) {
+	req, err := b.newChatRequest(ctx, p, apiKey, r, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, body, err := doRequestResponse(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	var chatResp ChatResponse
+	if err := parseChatResponse(resp, body, &chatResp); err != nil {
+		return "", err
+	}
+
+	if len(chatResp.Choices) > 0 {
+		r.reportUsage(chatResp.Usage.usage())
+
+		return chatResp.Choices[0].Message.Content, nil
+	}
+
+	return "", pkgerrors.Newf("no response from %s", p.Name)
+}
+
+func (b openAIBackend) QueryStream(
+	ctx context.Context, p *Provider, apiKey string, r Request, onToken TokenFunc,
+) (string, error) {
+	req, err := b.newChatRequest(ctx, p, apiKey, r, true)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := sendRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", requestError(ctx, err, "failed to read response")
+		}
+
+		return "", parseChatResponse(resp, body, &ChatResponse{})
+	}
+
+	var (
+		sb    strings.Builder
+		usage *Usage
+	)
+
+	err = readSSE(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return errStreamDone
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return pkgerrors.Wrap(err, "failed to parse stream chunk")
+		}
+
+		if chunk.Error != nil {
+			return apiError(chunk.Error.kind(), chunk.Error.Message)
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+
+			sb.WriteString(choice.Delta.Content)
+			onToken(choice.Delta.Content)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", requestError(ctx, err, "stream failed")
+	}
+
+	if sb.Len() == 0 {
+		return "", pkgerrors.Newf("no response from %s", p.Name)
+	}
+
+	r.reportUsage(usage)
+
+	return sb.String(), nil
+}
+
+// chatRequestBody translates r into a chat/completions request body.
+func (b openAIBackend) chatRequestBody(r Request, stream bool) ChatRequest {
+	body := ChatRequest{
+		Model:       r.Model,
+		Messages:    chatMessages(r.turns()),
+		Temperature: r.Temperature,
+		Stop:        r.Stop,
+		Stream:      stream,
+	}
+
+	if b.maxCompletionTokens {
+		body.MaxCompletionTokens = r.maxTokens()
+	} else {
+		body.MaxTokens = r.maxTokens()
+	}
+
+	if r.Format == FormatJSON {
+		body.ResponseFormat = &ChatResponseFormat{Type: "json_object"}
+	}
+
+	if stream {
+		body.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+
+	return body
+}
+
+// newChatRequest builds a chat/completions HTTP request for r.
+func (b openAIBackend) newChatRequest(ctx context.Context, p *Provider, apiKey string, r Request, stream bool) (*http.Request, error) {
+	jsonData, err := json.Marshal(b.chatRequestBody(r, stream))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to marshal request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeaders(req, p, apiKey)
+
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+// parseChatResponse decodes body into chatResp and reports API errors.
+func parseChatResponse(resp *http.Response, body []byte, chatResp *ChatResponse) error {
+	err := json.Unmarshal(body, chatResp)
+
+	switch {
+	case resp.StatusCode != http.StatusOK && chatResp.Error != nil:
+		return statusError(resp, body, chatResp.Error.kind(), chatResp.Error.Message)
+	case resp.StatusCode != http.StatusOK:
+		return statusError(resp, body, "", "")
+	case err != nil:
+		return pkgerrors.Wrap(err, "failed to parse response")
+	case chatResp.Error != nil:
+		return apiError(chatResp.Error.kind(), chatResp.Error.Message)
+	}
+
+	return nil
+}
+
+func (openAIBackend) ListModels(ctx context.Context, p *Provider, apiKey string) ([]string, error) {
+	endpoint := strings.TrimSuffix(p.Endpoint, "/chat/completions") + "/models"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to create request")
+	}
+
+	setAuthHeaders(req, p, apiKey)
+
+	body, status, err := doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var modelsResp modelsResponse
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, pkgerrors.Wrap(err, "failed to parse response")
+	}
+
+	if modelsResp.Error != nil {
+		return nil, pkgerrors.Newf("API error: %s", modelsResp.Error.Message)
+	}
+
+	if status != http.StatusOK {
+		return nil, pkgerrors.Newf("API returned status %d: %s", status, string(body))
+	}
+
+	models := make([]string, 0, len(modelsResp.Data))
+	for _, m := range modelsResp.Data {
+		models = append(models, m.ID)
+	}
+
+	return models, nil
+}
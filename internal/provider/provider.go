@@ -1,13 +1,9 @@
 package provider
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	pkgerrors "github.com/cockroachdb/errors"
@@ -27,6 +23,21 @@ type Provider struct {
 	EnvVar       string
 	AuthType     AuthType
 	Configured   bool
+	// Aliases are alternative names accepted by GetByName.
+	Aliases []string
+	// AuthHint is shown instead of EnvVar when the provider does not use one.
+	AuthHint string
+	// AuthHeader names the header carrying the key when AuthType is AuthAPIKey
+	// and the backend does not define its own.
+	AuthHeader string
+	// Headers are extra HTTP headers sent with every request.
+	Headers map[string]string
+	// Priority orders providers during detection; lower values win.
+	Priority int
+	// Backend implements the provider's wire protocol.
+	Backend Backend
+	// Retry controls how transient failures are retried.
+	Retry RetryPolicy
 }
 
 // AuthType defines how the provider authenticates requests.
@@ -37,8 +48,12 @@ const (
 	AuthBearer AuthType = iota
 	// AuthAPIKey uses a custom API key header or query param.
 	AuthAPIKey
-	// AuthCLI uses an external CLI tool (like gh copilot).
+	// AuthCLI takes the credential from an external CLI tool (like gh auth token).
 	AuthCLI
+	// AuthNone sends no credentials (local daemons like Ollama).
+	AuthNone
+	// AuthSigV4 signs requests with AWS Signature Version 4.
+	AuthSigV4
 )
 
 // ProviderInfo contains provider information for display.
@@ -47,161 +62,86 @@ type ProviderInfo struct {
 	DefaultModel string
 	EnvVar       string
 	Configured   bool
-}
-
-// ChatRequest represents a chat completion request.
-type ChatRequest struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	MaxTokens int       `json:"maxTokens"`
-}
-
-// Message represents a chat message.
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// ChatResponse represents a chat completion response.
-type ChatResponse struct {
-	Choices []struct {
-		Message Message `json:"message"`
-	} `json:"choices"`
-	Error *APIError `json:"error,omitempty"`
-}
-
-// APIError represents an API error response.
-type APIError struct {
-	Message string `json:"message"`
-}
-
-// Providers configuration.
-var (
-	OpenAI = &Provider{
-		Name:         "OpenAI",
-		Endpoint:     "https://api.openai.com/v1/chat/completions",
-		DefaultModel: "gpt-4o",
-		EnvVar:       "OPENAI_API_KEY",
-		AuthType:     AuthBearer,
-	}
-
-	Anthropic = &Provider{
-		Name:         "Anthropic",
-		Endpoint:     "https://api.anthropic.com/v1/messages",
-		DefaultModel: "claude-sonnet-4-20250514",
-		EnvVar:       "ANTHROPIC_API_KEY",
-		AuthType:     AuthAPIKey,
-	}
-
-	Gemini = &Provider{
-		Name:         "Gemini",
-		Endpoint:     "https://generativelanguage.googleapis.com/v1beta/openai/chat/completions",
-		DefaultModel: "gemini-2.0-flash",
-		EnvVar:       "GEMINI_API_KEY",
-		AuthType:     AuthBearer,
-	}
-
-	DeepSeek = &Provider{
-		Name:         "DeepSeek",
-		Endpoint:     "https://api.deepseek.com/chat/completions",
-		DefaultModel: "deepseek-chat",
-		EnvVar:       "DEEPSEEK_API_KEY",
-		AuthType:     AuthBearer,
-	}
-
-	GitHubCopilot = &Provider{
-		Name:         "GitHub Copilot",
-		Endpoint:     "", // Uses gh CLI
-		DefaultModel: "gpt-4",
-		EnvVar:       "", // No env var needed, uses gh auth
-		AuthType:     AuthCLI,
-	}
-)
-
-// apiProviders are providers that use API keys.
-var apiProviders = []*Provider{
-	OpenAI,
-	Anthropic,
-	Gemini,
-	DeepSeek,
+	// Local is true for providers served from the user's machine.
+	Local bool
 }
 
 // Detect automatically detects the first available provider.
 func Detect() (*Provider, string) {
-	// First check API-based providers
-	for _, p := range apiProviders {
-		if key := os.Getenv(p.EnvVar); key != "" {
-			p.Configured = true
-
-			return p, key
+	for _, p := range Registered() {
+		key, err := p.Backend.CheckAuth(p)
+		if err != nil {
+			continue
 		}
-	}
 
-	// Then check if GitHub Copilot CLI is available
-	if IsCopilotAvailable() {
-		GitHubCopilot.Configured = true
+		p.Configured = true
 
-		return GitHubCopilot, ""
+		return p, key
 	}
 
 	return nil, ""
 }
 
-// GetByName returns a provider by name.
+// GetByName returns a provider by name or alias.
 func GetByName(name string) (*Provider, string, error) {
-	// Check API providers
-	for _, p := range apiProviders {
-		if p.Name == name {
-			key := os.Getenv(p.EnvVar)
-			if key == "" {
-				return nil, "", pkgerrors.Newf("provider %s requires %s to be set", name, p.EnvVar)
-			}
-
-			p.Configured = true
+	p, err := Lookup(name)
+	if err != nil {
+		return nil, "", err
+	}
 
-			return p, key, nil
-		}
+	key, err := Check(p)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Check GitHub Copilot
-	if name == GitHubCopilot.Name || name == "Copilot" || name == "copilot" {
-		if !IsCopilotAvailable() {
-			return nil, "", pkgerrors.New(
-				"GitHub Copilot CLI not available. Install with: gh extension install github/gh-copilot",
-			)
-		}
+	return p, key, nil
+}
+
+// Lookup returns a provider by name or alias without checking its
+// credentials, for callers that configure it first.
+func Lookup(name string) (*Provider, error) {
+	p := lookup(name)
+	if p == nil {
+		return nil, pkgerrors.Newf("unknown provider: %s", name)
+	}
 
-		GitHubCopilot.Configured = true
+	return p, nil
+}
 
-		return GitHubCopilot, "", nil
+// Check checks p's credentials and returns its API key.
+func Check(p *Provider) (string, error) {
+	key, err := p.Backend.CheckAuth(p)
+	if err != nil {
+		return "", err
 	}
 
-	return nil, "", pkgerrors.Newf("unknown provider: %s", name)
+	p.Configured = true
+
+	return key, nil
 }
 
 // ListAll returns information about all providers.
 func ListAll() []ProviderInfo {
-	result := make([]ProviderInfo, 0, len(apiProviders)+1)
+	providers := Registered()
+	result := make([]ProviderInfo, 0, len(providers))
+
+	for _, p := range providers {
+		_, err := p.Backend.CheckAuth(p)
 
-	// API-based providers
-	for _, p := range apiProviders {
-		info := ProviderInfo{
+		envVar := p.EnvVar
+		if envVar == "" {
+			envVar = p.AuthHint
+		}
+
+		result = append(result, ProviderInfo{
 			Name:         p.Name,
 			DefaultModel: p.DefaultModel,
-			EnvVar:       p.EnvVar,
-			Configured:   os.Getenv(p.EnvVar) != "",
-		}
-		result = append(result, info)
+			EnvVar:       envVar,
+			Configured:   err == nil,
+			Local:        p.Backend.Capabilities().Local,
+		})
 	}
 
-	// GitHub Copilot (CLI-based)
-	result = append(result, ProviderInfo{
-		Name:         GitHubCopilot.Name,
-		DefaultModel: GitHubCopilot.DefaultModel,
-		EnvVar:       "gh copilot (CLI)",
-		Configured:   IsCopilotAvailable(),
-	})
-
 	return result
 }
 
@@ -209,6 +149,13 @@ func ListAll() []ProviderInfo {
 // It checks the HOWTO_TIMEOUT environment variable first,
 // then falls back to the provided default or DefaultTimeout.
 func GetTimeout(flagTimeout time.Duration) time.Duration {
+	return ResolveTimeout(flagTimeout, 0)
+}
+
+// ResolveTimeout returns the timeout using the precedence
+// flag > HOWTO_TIMEOUT > profile > DefaultTimeout.
+// Zero values for flagTimeout and profileTimeout mean unset.
+func ResolveTimeout(flagTimeout, profileTimeout time.Duration) time.Duration {
 	// Flag takes precedence
 	if flagTimeout > 0 {
 		return flagTimeout
@@ -221,76 +168,70 @@ func GetTimeout(flagTimeout time.Duration) time.Duration {
 		}
 	}
 
-	return DefaultTimeout
-}
-
-// Query sends a chat completion request to the provider.
-func (p *Provider) Query(ctx context.Context, apiKey, model, promptText string) (string, error) {
-	if p.AuthType == AuthCLI {
-		return p.queryCopilot(ctx, apiKey, model, promptText)
+	if profileTimeout > 0 {
+		return profileTimeout
 	}
 
-	if p.Name == "Anthropic" {
-		return p.queryAnthropic(ctx, apiKey, model, promptText)
-	}
+	return DefaultTimeout
+}
 
-	return p.queryOpenAICompatible(ctx, apiKey, model, promptText)
+// Query sends a completion request to the provider's backend, retrying
+// transient failures according to p.Retry.
+func (p *Provider) Query(ctx context.Context, apiKey string, req Request) (string, error) {
+	return p.Retry.do(ctx, func() (string, error) {
+		return p.Backend.Query(ctx, p, apiKey, req)
+	}, nil)
 }
 
-func (p *Provider) queryOpenAICompatible(ctx context.Context, apiKey, model, promptText string) (string, error) {
-	requestBody := ChatRequest{
-		Model:     model,
-		Messages:  []Message{{Role: "user", Content: promptText}},
-		MaxTokens: 1000,
-	}
+// QueryStream sends a completion request and reports text through onToken as
+// it arrives. Backends without streaming support deliver the whole response
+// in a single call once it is complete. Failures are only retried until the
+// first token arrives.
+func (p *Provider) QueryStream(ctx context.Context, apiKey string, req Request, onToken TokenFunc) (string, error) {
+	s, ok := p.Backend.(Streamer)
+	if !ok || !p.Backend.Capabilities().Streaming {
+		response, err := p.Query(ctx, apiKey, req)
+		if err != nil {
+			return "", err
+		}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to marshal request")
-	}
+		onToken(response)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to create request")
+		return response, nil
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			return "", pkgerrors.New("request timed out")
-		}
+	streamed := false
+	forward := func(token string) {
+		streamed = true
 
-		return "", pkgerrors.Wrap(err, "failed to send request")
+		onToken(token)
 	}
 
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", pkgerrors.Wrap(err, "failed to read response")
-	}
+	return p.Retry.do(ctx, func() (string, error) {
+		return s.QueryStream(ctx, p, apiKey, req, forward)
+	}, func() bool { return !streamed })
+}
 
-	var chatResp ChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return "", pkgerrors.Wrap(err, "failed to parse response")
+// ListModels returns the models the provider offers.
+func (p *Provider) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	if !p.Backend.Capabilities().ModelListing {
+		return nil, ErrModelListingUnsupported
 	}
 
-	if chatResp.Error != nil {
-		return "", pkgerrors.Newf("API error: %s", chatResp.Error.Message)
-	}
+	return p.Backend.ListModels(ctx, p, apiKey)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", pkgerrors.Newf("API returned status %d: %s", resp.StatusCode, string(body))
+// Matches reports whether name refers to this provider (by name or alias).
+func (p *Provider) Matches(name string) bool {
+	if strings.EqualFold(p.Name, name) {
+		return true
 	}
 
-	if len(chatResp.Choices) > 0 {
-		return chatResp.Choices[0].Message.Content, nil
+	for _, alias := range p.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
 	}
 
-	return "", pkgerrors.Newf("no response from %s", p.Name)
+	return false
 }
@@ -1,7 +1,13 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -250,18 +256,15 @@ func TestGetByName(t *testing.T) {
 	})
 
 	t.Run("copilot aliases work", func(t *testing.T) {
-		// This test may fail if gh copilot is not installed
+		// This test may fail if gh is not installed or logged in
 		// We just verify the alias matching works
 		aliases := []string{"GitHub Copilot", "Copilot", "copilot"}
-		expectedErr := "GitHub Copilot CLI not available. " +
-			"Install with: gh extension install github/gh-copilot"
 
 		for _, alias := range aliases {
 			_, _, err := GetByName(alias)
-			// We expect either success (if installed) or specific error about CLI
-			if err != nil && err.Error() != expectedErr {
-				// This is fine - just means copilot isn't installed
-				continue
+			// We expect either success (if logged in) or an error about the GitHub token
+			if err != nil && strings.HasPrefix(err.Error(), "unknown provider") {
+				t.Errorf("GetByName(%q) error = %v", alias, err)
 			}
 		}
 	})
@@ -272,12 +275,12 @@ func TestListAll(t *testing.T) {
 
 	providers := ListAll()
 
-	if len(providers) < 5 {
-		t.Errorf("ListAll() returned %d providers, want at least 5", len(providers))
+	if len(providers) < 8 {
+		t.Errorf("ListAll() returned %d providers, want at least 8", len(providers))
 	}
 
 	// Check that all expected providers are present
-	expectedNames := []string{"OpenAI", "Anthropic", "Gemini", "DeepSeek", "GitHub Copilot"}
+	expectedNames := []string{"OpenAI", "Azure OpenAI", "Anthropic", "Gemini", "DeepSeek", "Bedrock", "Ollama", "GitHub Copilot"}
 	providerNames := make(map[string]bool)
 
 	for _, p := range providers {
@@ -310,3 +313,183 @@ func TestProviderConstants(t *testing.T) {
 		}
 	})
 }
+
+// fakeBackend is a Backend used to exercise the registry.
+type fakeBackend struct {
+	key    string
+	models []string
+}
+
+func (f fakeBackend) Query(_ context.Context, p *Provider, apiKey string, req Request) (string, error) {
+	return p.Name + ":" + apiKey + ":" + req.Model + ":" + req.Prompt, nil
+}
+
+func (f fakeBackend) Capabilities() Capabilities {
+	return Capabilities{ModelListing: f.models != nil}
+}
+
+func (f fakeBackend) CheckAuth(p *Provider) (string, error) {
+	if f.key == "" {
+		return "", errors.New(p.Name + " not configured")
+	}
+
+	return f.key, nil
+}
+
+func (f fakeBackend) ListModels(context.Context, *Provider, string) ([]string, error) {
+	return f.models, nil
+}
+
+func TestRegistry(t *testing.T) {
+	inHouse := &Provider{
+		Name:         "InHouse",
+		DefaultModel: "house-1",
+		Aliases:      []string{"house"},
+		Priority:     1,
+		Backend:      fakeBackend{key: "house-key", models: []string{"house-1", "house-2"}},
+	}
+	Register(inHouse)
+	t.Cleanup(func() { unregister(inHouse.Name) })
+
+	t.Run("Detect honors priority", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "openai-key")
+
+		p, key := Detect()
+		if p != inHouse {
+			t.Fatalf("Detect() provider = %v, want %q", p, inHouse.Name)
+		}
+
+		if key != "house-key" {
+			t.Errorf("Detect() key = %q, want %q", key, "house-key")
+		}
+	})
+
+	t.Run("GetByName matches aliases case-insensitively", func(t *testing.T) {
+		p, _, err := GetByName("HOUSE")
+		if err != nil {
+			t.Fatalf("GetByName() error = %v", err)
+		}
+
+		if p != inHouse {
+			t.Errorf("GetByName() provider = %q, want %q", p.Name, inHouse.Name)
+		}
+	})
+
+	t.Run("Query dispatches to the backend", func(t *testing.T) {
+		got, err := inHouse.Query(context.Background(), "k", Request{Model: "m", Prompt: "p"})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+
+		if got != "InHouse:k:m:p" {
+			t.Errorf("Query() = %q, want %q", got, "InHouse:k:m:p")
+		}
+	})
+
+	t.Run("ListModels requires the capability", func(t *testing.T) {
+		models, err := inHouse.ListModels(context.Background(), "")
+		if err != nil || len(models) != 2 {
+			t.Errorf("ListModels() = %v, %v; want 2 models", models, err)
+		}
+
+		unlisted := &Provider{Name: "Unlisted", Backend: fakeBackend{key: "k"}}
+
+		_, err = unlisted.ListModels(context.Background(), "")
+		if !errors.Is(err, ErrModelListingUnsupported) {
+			t.Errorf("ListModels() error = %v, want %v", err, ErrModelListingUnsupported)
+		}
+	})
+
+	t.Run("ListAll includes registered backends", func(t *testing.T) {
+		providers := ListAll()
+		if providers[0].Name != inHouse.Name || !providers[0].Configured {
+			t.Errorf("ListAll()[0] = %+v, want configured %q", providers[0], inHouse.Name)
+		}
+	})
+
+	t.Run("duplicate names panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Register() expected panic for duplicate name")
+			}
+		}()
+
+		Register(&Provider{Name: "inhouse", Backend: fakeBackend{}})
+	})
+}
+
+func TestResolveTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    time.Duration
+		env     string
+		profile time.Duration
+		want    time.Duration
+	}{
+		{name: "flag beats everything", flag: time.Second, env: "2s", profile: 3 * time.Second, want: time.Second},
+		{name: "env beats profile", env: "2s", profile: 3 * time.Second, want: 2 * time.Second},
+		{name: "profile beats default", profile: 3 * time.Second, want: 3 * time.Second},
+		{name: "default", want: DefaultTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(TimeoutEnvVar, tt.env)
+
+			if got := ResolveTimeout(tt.flag, tt.profile); got != tt.want {
+				t.Errorf("ResolveTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		backend Backend
+		body    string
+		want    *Usage
+	}{
+		{
+			"openai", openAIBackend{},
+			`{"choices":[{"message":{"role":"assistant","content":"ls"}}],"usage":{"prompt_tokens":120,"completion_tokens":5,"total_tokens":125}}`,
+			&Usage{InputTokens: 120, OutputTokens: 5},
+		},
+		{
+			"anthropic", anthropicBackend{},
+			`{"content":[{"type":"text","text":"ls"}],"usage":{"input_tokens":98,"output_tokens":6}}`,
+			&Usage{InputTokens: 98, OutputTokens: 6},
+		},
+		{
+			"not reported", openAIBackend{},
+			`{"choices":[{"message":{"role":"assistant","content":"ls"}}]}`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprint(w, tt.body)
+			}))
+			t.Cleanup(srv.Close)
+
+			p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: tt.backend}
+
+			var got *Usage
+
+			req := Request{OnUsage: func(u Usage) { got = &u }}
+			if _, err := p.Query(context.Background(), "key", req); err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("Query() usage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// registry holds every provider known to howto.
+var registry struct {
+	sync.RWMutex
+
+	providers []*Provider
+}
+
+// Register adds a provider to the registry. Backends call it from init().
+// It panics if the provider has no backend or its name is already taken,
+// since both are programming errors.
+func Register(p *Provider) {
+	if p == nil || p.Backend == nil {
+		panic("provider: Register called with nil provider or backend")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, existing := range registry.providers {
+		if strings.EqualFold(existing.Name, p.Name) {
+			panic("provider: Register called twice for " + p.Name)
+		}
+	}
+
+	registry.providers = append(registry.providers, p)
+}
+
+// Registered returns all registered providers ordered by priority.
+func Registered() []*Provider {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	result := slices.Clone(registry.providers)
+	slices.SortStableFunc(result, func(a, b *Provider) int {
+		return a.Priority - b.Priority
+	})
+
+	return result
+}
+
+// lookup finds a registered provider by name or alias (case-insensitive).
+func lookup(name string) *Provider {
+	for _, p := range Registered() {
+		if p.Matches(name) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// unregister removes a provider by name. It exists for tests.
+func unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.providers = slices.DeleteFunc(registry.providers, func(p *Provider) bool {
+		return p.Name == name
+	})
+}
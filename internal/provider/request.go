@@ -0,0 +1,75 @@
+package provider
+
+// DefaultMaxTokens caps the response length when a request does not set one.
+const DefaultMaxTokens = 1000
+
+// ResponseFormat constrains the shape of a response.
+type ResponseFormat int
+
+const (
+	// FormatText is free-form text.
+	FormatText ResponseFormat = iota
+	// FormatJSON asks for a single JSON object. Backends without a JSON mode
+	// rely on the prompt asking for one.
+	FormatJSON
+)
+
+// Request is a backend-neutral completion request. Each backend translates
+// it into its own wire schema, e.g. the system prompt becomes a system
+// message for OpenAI and the top-level system field for Anthropic.
+type Request struct {
+	Model string
+	// System is the system prompt; it precedes any system turns of Messages.
+	System string
+	// Prompt is sent as a single user turn when Messages is empty.
+	Prompt string
+	// Messages is a multi-turn conversation, see Conversation.
+	Messages []Turn
+	// MaxTokens caps the response length; zero means DefaultMaxTokens.
+	MaxTokens int
+	// Temperature is the sampling temperature; nil leaves the backend default.
+	Temperature *float64
+	// Stop ends the response at the first of these sequences.
+	Stop []string
+	// Format constrains the shape of the response.
+	Format ResponseFormat
+	// OnUsage, if set, receives the token counts the provider reports for a
+	// successful response.
+	OnUsage func(Usage)
+}
+
+// Usage is the token count a provider reported for a request.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// reportUsage passes u to OnUsage, if both are set.
+func (r Request) reportUsage(u *Usage) {
+	if u != nil && r.OnUsage != nil {
+		r.OnUsage(*u)
+	}
+}
+
+// turns returns the conversation to send, starting with the system prompt.
+func (r Request) turns() []Turn {
+	turns := r.Messages
+	if len(turns) == 0 {
+		turns = []Turn{{Role: RoleUser, Content: r.Prompt}}
+	}
+
+	if r.System == "" {
+		return turns
+	}
+
+	return append([]Turn{{Role: RoleSystem, Content: r.System}}, turns...)
+}
+
+// maxTokens returns the effective response length cap.
+func (r Request) maxTokens() int {
+	if r.MaxTokens > 0 {
+		return r.MaxTokens
+	}
+
+	return DefaultMaxTokens
+}
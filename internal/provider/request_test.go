@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// golden compares got with testdata/requests/name, rewriting it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "requests", name)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestRequestTurns(t *testing.T) {
+	t.Parallel()
+
+	r := Request{System: "Reply with a shell command only.", Prompt: "list files"}
+
+	turns := r.turns()
+	if len(turns) != 2 || turns[0].Role != RoleSystem || turns[1] != (Turn{Role: RoleUser, Content: "list files"}) {
+		t.Errorf("turns() = %+v, want the system prompt and then the user prompt", turns)
+	}
+
+	if turns := (Request{Prompt: "list files"}).turns(); len(turns) != 1 {
+		t.Errorf("turns() without a system prompt = %+v, want a single user turn", turns)
+	}
+}
+
+func TestRequestSchemas(t *testing.T) {
+	t.Parallel()
+
+	temperature := 0.2
+
+	requests := map[string]Request{
+		"prompt": {
+			Model:       "m",
+			System:      "You explain shell commands. Respond with JSON only.",
+			Prompt:      "ls -la",
+			MaxTokens:   2000,
+			Temperature: &temperature,
+			Stop:        []string{"\n\n\n"},
+			Format:      FormatJSON,
+		},
+		"conversation": {
+			Model:    "m",
+			System:   "Use the tools of the environment.",
+			Messages: refinement().Turns(),
+		},
+	}
+
+	backends := map[string]func(Request) any{
+		"openai":            func(r Request) any { return openAIBackend{maxCompletionTokens: true}.chatRequestBody(r, false) },
+		"openai_compatible": func(r Request) any { return openAIBackend{}.chatRequestBody(r, true) },
+		"anthropic":         func(r Request) any { return anthropicRequestBody(r, false) },
+		"ollama":            func(r Request) any { return ollamaChatRequestBody(r, false) },
+		"gemini":            func(r Request) any { return geminiRequestBody(r) },
+		"gemini_safety": func(r Request) any {
+			body := geminiRequestBody(r)
+			body.SafetySettings = []GeminiSafetySetting{
+				{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+				{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"},
+			}
+
+			return body
+		},
+		"bedrock": func(r Request) any { return bedrockRequestBody(r) },
+	}
+
+	for backend, body := range backends {
+		for name, r := range requests {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				t.Parallel()
+
+				got, err := json.MarshalIndent(body(r), "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				golden(t, backend+"_"+name+".json", append(got, '\n'))
+			})
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// Retry defaults, used for zero fields of RetryPolicy.
+const (
+	DefaultMaxAttempts = 4
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 20 * time.Second
+)
+
+// RetryPolicy controls how transient failures are retried. Delays grow
+// exponentially from BaseDelay with random jitter, unless the provider names
+// a time to wait; a retry that would run past the deadline of the request's
+// context is not attempted.
+type RetryPolicy struct {
+	// MaxAttempts caps the number of requests, including the first; one
+	// disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// MaxDelay caps the computed backoff (not a delay the provider asks for).
+	MaxDelay time.Duration
+	// OnRetry, if set, is called before waiting to retry after err.
+	OnRetry func(err error, delay time.Duration)
+}
+
+// do calls fn until it succeeds, fails permanently or attempts run out.
+// canRetry, if set, can veto a retry, e.g. once a stream delivered tokens.
+func (r RetryPolicy) do(ctx context.Context, fn func() (string, error), canRetry func() bool) (string, error) {
+	maxAttempts := r.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		response, err := fn()
+		if err == nil || !IsRetryable(err) || (canRetry != nil && !canRetry()) {
+			return response, err
+		}
+
+		delay := r.delay(attempt, err)
+
+		deadline, ok := ctx.Deadline()
+		if attempt == maxAttempts || (ok && time.Now().Add(delay).After(deadline)) {
+			if attempt == 1 {
+				return "", err
+			}
+
+			return "", pkgerrors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+
+		if r.OnRetry != nil {
+			r.OnRetry(err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return "", err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the retry following attempt: the
+// provider's hint if it gave one, else exponential backoff with jitter.
+func (r RetryPolicy) delay(attempt int, err error) time.Duration {
+	var e *Error
+	if pkgerrors.As(err, &e) && e.RetryAfter > 0 {
+		return e.RetryAfter
+	}
+
+	base := r.BaseDelay
+	if base <= 0 {
+		base = DefaultBaseDelay
+	}
+
+	maxDelay := r.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+
+	backoff := min(base<<(attempt-1), maxDelay)
+
+	// Jitter spreads out clients that were limited at the same moment
+	return backoff/2 + rand.N(backoff/2+1)
+}
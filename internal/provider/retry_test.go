@@ -0,0 +1,305 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly so tests do not wait on real backoff.
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+// flakyServer fails the first failures requests with status, headers and
+// body, then answers with an OpenAI-style completion.
+func flakyServer(t *testing.T, failures int32, status int, headers map[string]string, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) <= failures {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+
+			w.WriteHeader(status)
+			_, _ = fmt.Fprint(w, body)
+
+			return
+		}
+
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ls"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status  int
+		errType string
+		want    ErrorKind
+	}{
+		{http.StatusTooManyRequests, "", KindRateLimit},
+		{http.StatusTooManyRequests, "insufficient_quota", KindQuota},
+		{statusOverloaded, "overloaded_error", KindOverloaded},
+		{http.StatusServiceUnavailable, "", KindOverloaded},
+		{http.StatusBadGateway, "", KindServer},
+		{http.StatusUnauthorized, "authentication_error", KindAuth},
+		{http.StatusForbidden, "", KindAuth},
+		{http.StatusPaymentRequired, "", KindQuota},
+		{http.StatusBadRequest, "invalid_request_error", KindInvalidRequest},
+		{http.StatusNotFound, "", KindInvalidRequest},
+		{0, "overloaded_error", KindOverloaded},
+		{0, "", KindUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := classify(tt.status, tt.errType); got != tt.want {
+			t.Errorf("classify(%d, %q) = %v, want %v", tt.status, tt.errType, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"seconds", map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{"http date", map[string]string{"Retry-After": now.Add(5 * time.Second).Format(http.TimeFormat)}, 5 * time.Second},
+		{"date in the past", map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)}, 0},
+		{
+			"exhausted limit",
+			map[string]string{
+				"X-Ratelimit-Remaining-Requests": "10", "X-Ratelimit-Reset-Requests": "1s",
+				"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "6m0s",
+			},
+			6 * time.Minute,
+		},
+		{
+			"soonest reset",
+			map[string]string{"X-Ratelimit-Reset-Requests": "20ms", "X-Ratelimit-Reset-Tokens": "2.5"},
+			20 * time.Millisecond,
+		},
+		{"retry-after wins", map[string]string{"Retry-After": "1", "X-Ratelimit-Reset-Requests": "9s"}, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+
+			if got := retryAfter(header, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRetries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries rate limits until success", func(t *testing.T) {
+		t.Parallel()
+
+		srv, calls := flakyServer(t, 2, http.StatusTooManyRequests, nil,
+			`{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+
+		var retries []error
+
+		policy := fastRetries
+		policy.OnRetry = func(err error, _ time.Duration) { retries = append(retries, err) }
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}, Retry: policy}
+
+		got, err := p.Query(context.Background(), "key", Request{})
+		if err != nil || got != "ls" {
+			t.Fatalf("Query() = %q, %v; want success", got, err)
+		}
+
+		if calls.Load() != 3 || len(retries) != 2 {
+			t.Errorf("Query() made %d requests with %d retries, want 3 and 2", calls.Load(), len(retries))
+		}
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		t.Parallel()
+
+		srv, calls := flakyServer(t, 1, http.StatusUnauthorized, nil,
+			`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}, Retry: fastRetries}
+
+		_, err := p.Query(context.Background(), "key", Request{})
+
+		var e *Error
+		if !errors.As(err, &e) || e.Kind != KindAuth || IsRetryable(err) {
+			t.Fatalf("Query() error = %v, want a permanent auth error", err)
+		}
+
+		if want := "authentication failed (status 401): Incorrect API key provided"; err.Error() != want {
+			t.Errorf("Query() error = %q, want %q", err, want)
+		}
+
+		if calls.Load() != 1 {
+			t.Errorf("Query() made %d requests, want 1", calls.Load())
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		t.Parallel()
+
+		srv, calls := flakyServer(t, 10, http.StatusBadGateway, nil, "<html>Bad Gateway</html>")
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}, Retry: fastRetries}
+
+		_, err := p.Query(context.Background(), "key", Request{})
+		if err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") || !IsRetryable(err) {
+			t.Errorf("Query() error = %v, want a server error after 3 attempts", err)
+		}
+
+		if calls.Load() != 3 {
+			t.Errorf("Query() made %d requests, want 3", calls.Load())
+		}
+	})
+
+	t.Run("does not wait past the deadline", func(t *testing.T) {
+		t.Parallel()
+
+		srv, calls := flakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "30"}, "")
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}, Retry: fastRetries}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		start := time.Now()
+
+		_, err := p.Query(ctx, "key", Request{})
+
+		var e *Error
+		if !errors.As(err, &e) || e.Kind != KindRateLimit || e.RetryAfter != 30*time.Second {
+			t.Errorf("Query() error = %v, want the rate limit error", err)
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second || calls.Load() != 1 {
+			t.Errorf("Query() took %v and %d requests, want an immediate failure", elapsed, calls.Load())
+		}
+	})
+
+	t.Run("retries overloaded Anthropic", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(statusOverloaded)
+				_, _ = fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+
+				return
+			}
+
+			_, _ = fmt.Fprint(w, `{"content":[{"type":"text","text":"df -h"}]}`)
+		}))
+		t.Cleanup(srv.Close)
+
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: anthropicBackend{}, Retry: fastRetries}
+
+		got, err := p.Query(context.Background(), "key", Request{})
+		if err != nil || got != "df -h" || calls.Load() != 2 {
+			t.Errorf("Query() = %q, %v after %d requests; want df -h after 2", got, err, calls.Load())
+		}
+	})
+}
+
+func TestQueryStreamRetries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries before the first token", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, `data: {"choices":[{"delta":{"content":"ls"}}]}`+"\n\ndata: [DONE]\n\n")
+		}))
+		t.Cleanup(srv.Close)
+
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}, Retry: fastRetries}
+
+		got, err := p.QueryStream(context.Background(), "key", Request{}, func(string) {})
+		if err != nil || got != "ls" || calls.Load() != 2 {
+			t.Errorf("QueryStream() = %q, %v after %d requests; want ls after 2", got, err, calls.Load())
+		}
+	})
+
+	t.Run("does not retry once tokens arrived", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			"event: content_block_delta\n"+
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"du"}}`+"\n\n",
+			"event: error\n"+
+				`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`+"\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: anthropicBackend{}, Retry: fastRetries}
+
+		var tokens []string
+
+		_, err := p.QueryStream(context.Background(), "key", Request{}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if !IsRetryable(err) || len(tokens) != 1 {
+			t.Errorf("QueryStream() error = %v with tokens %q, want one overloaded attempt", err, tokens)
+		}
+	})
+}
+
+func TestShouldFallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, false},
+		{"timeout", &Error{Kind: KindTimeout}, true},
+		{"auth", &Error{Kind: KindAuth, StatusCode: http.StatusUnauthorized}, true},
+		{"quota", fmt.Errorf("query: %w", &Error{Kind: KindQuota}), true},
+		{"server", &Error{Kind: KindServer, StatusCode: http.StatusInternalServerError}, true},
+		{"unclassified", errors.New("gh copilot failed"), true},
+		{"invalid request", &Error{Kind: KindInvalidRequest, StatusCode: http.StatusBadRequest}, false},
+		{"canceled", fmt.Errorf("stream failed: %w", context.Canceled), false},
+	}
+
+	for _, tt := range tests {
+		if got := ShouldFallback(tt.err); got != tt.want {
+			t.Errorf("ShouldFallback(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
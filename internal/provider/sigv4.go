@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 constants.
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+)
+
+// awsCredentials are the keys requests are signed with.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials, e.g. from SSO or STS.
+	SessionToken string
+}
+
+// signRequest signs req, whose body is body, with AWS Signature Version 4
+// for service in region, adding the X-Amz-Date, X-Amz-Security-Token and
+// Authorization headers.
+func signRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signed := signedHeaders(req)
+	scope := now.Format(sigV4DateFormat) + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" +
+		hashHex([]byte(canonicalRequest(req, signed, hashHex(body))))
+	key := signingKey(creds.SecretAccessKey, now.Format(sigV4DateFormat), region, service)
+
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+strings.Join(signed, ";")+
+		", Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign)))
+}
+
+// signedHeaders returns the lowercased names of the headers to sign: host
+// and every header already set on req, sorted.
+func signedHeaders(req *http.Request) []string {
+	names := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); lower != "authorization" && lower != "user-agent" {
+			names = append(names, lower)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// canonicalRequest builds the SigV4 canonical form of req covering the
+// headers named in signed. It works for outgoing requests and for requests
+// received by a server alike.
+func canonicalRequest(req *http.Request, signed []string, payloadHash string) string {
+	var sb strings.Builder
+
+	sb.WriteString(req.Method + "\n")
+
+	// Every path segment is encoded once more, as for all services but S3
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+
+	path := strings.Join(segments, "/")
+	if path == "" {
+		path = "/"
+	}
+
+	sb.WriteString(path + "\n")
+
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+
+	sort.Strings(pairs)
+	sb.WriteString(strings.Join(pairs, "&") + "\n")
+
+	for _, name := range signed {
+		value := strings.Join(req.Header.Values(name), ",")
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+
+		sb.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+
+	sb.WriteString("\n" + strings.Join(signed, ";") + "\n" + payloadHash)
+
+	return sb.String()
+}
+
+// signingKey derives the SigV4 key for a day, region and service.
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape percent-encodes everything but the unreserved characters
+// A-Z, a-z, 0-9, '-', '.', '_' and '~', as SigV4 requires.
+func awsEscape(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var sb strings.Builder
+
+	for i := range len(s) {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			sb.WriteByte(c)
+
+			continue
+		}
+
+		sb.WriteByte('%')
+		sb.WriteByte(hexDigits[c>>4])
+		sb.WriteByte(hexDigits[c&0xf])
+	}
+
+	return sb.String()
+}
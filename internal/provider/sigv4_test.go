@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// exampleCredentials are the credentials of the AWS Signature Version 4 test
+// suite.
+var exampleCredentials = awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+func TestSignRequest(t *testing.T) {
+	t.Parallel()
+
+	// The get-vanilla case of the AWS test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signRequest(req, nil, exampleCredentials, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestAWSEscape(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"anthropic.claude-3-haiku-20240307-v1:0": "anthropic.claude-3-haiku-20240307-v1%3A0",
+		"a b/c~d_e":                              "a%20b%2Fc~d_e",
+		"%3A":                                    "%253A",
+	}
+
+	for in, want := range tests {
+		if got := awsEscape(in); got != want {
+			t.Errorf("awsEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
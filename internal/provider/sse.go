@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	pkgerrors "github.com/cockroachdb/errors"
+)
+
+// maxSSELineSize bounds a single server-sent event line.
+const maxSSELineSize = 1024 * 1024
+
+// errStreamDone stops readSSE without reporting an error.
+var errStreamDone = errors.New("stream done")
+
+// readSSE parses a text/event-stream body and calls fn for every event.
+// Multi-line data fields are joined with newlines as the SSE spec requires.
+// Returning errStreamDone from fn ends the stream successfully.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var (
+		event string
+		data  []string
+	)
+
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+
+			return nil
+		}
+
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if err := dispatch(); err != nil {
+				return stopOnDone(err)
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment line, used by servers as keep-alive.
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return pkgerrors.Wrap(err, "failed to read stream")
+	}
+
+	return stopOnDone(dispatch())
+}
+
+func stopOnDone(err error) error {
+	if errors.Is(err, errStreamDone) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseServer returns a server that replies with the given SSE events, flushing
+// after each one so the client sees them incrementally.
+func sseServer(t *testing.T, status int, events ...string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !body.Stream {
+			t.Errorf("request did not ask for a stream: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(status)
+
+		for _, event := range events {
+			_, _ = fmt.Fprint(w, event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestReadSSE(t *testing.T) {
+	t.Parallel()
+
+	input := ": keep-alive\n" +
+		"event: first\ndata: hello\n\n" +
+		"data: multi\ndata: line\n\n" +
+		"data:no-space\n\n" +
+		"data: trailing"
+
+	type event struct{ name, data string }
+
+	var got []event
+
+	err := readSSE(strings.NewReader(input), func(name, data string) error {
+		got = append(got, event{name, data})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readSSE() error = %v", err)
+	}
+
+	want := []event{{"first", "hello"}, {"", "multi\nline"}, {"", "no-space"}, {"", "trailing"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("readSSE() events = %v, want %v", got, want)
+	}
+}
+
+func TestOpenAIQueryStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delivers chunks in order", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			`data: {"choices":[{"delta":{"role":"assistant"}}]}`+"\n\n",
+			`data: {"choices":[{"delta":{"content":"ls"}}]}`+"\n\n",
+			`data: {"choices":[{"delta":{"content":" -la"}}]}`+"\n\n",
+			"data: [DONE]\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}}
+
+		var tokens []string
+
+		got, err := p.QueryStream(context.Background(), "key", Request{Model: "m", Prompt: "list"}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+
+		if got != "ls -la" {
+			t.Errorf("QueryStream() = %q, want %q", got, "ls -la")
+		}
+
+		if strings.Join(tokens, "|") != "ls| -la" {
+			t.Errorf("QueryStream() tokens = %q, want [ls  -la]", tokens)
+		}
+	})
+
+	t.Run("reports usage from the last chunk", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			`data: {"choices":[{"delta":{"content":"ls"}}]}`+"\n\n",
+			`data: {"choices":[],"usage":{"prompt_tokens":42,"completion_tokens":3}}`+"\n\n",
+			"data: [DONE]\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}}
+
+		var usage Usage
+
+		req := Request{Model: "m", Prompt: "list", OnUsage: func(u Usage) { usage = u }}
+		if _, err := p.QueryStream(context.Background(), "key", req, func(string) {}); err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+
+		if want := (Usage{InputTokens: 42, OutputTokens: 3}); usage != want {
+			t.Errorf("QueryStream() usage = %+v, want %+v", usage, want)
+		}
+	})
+
+	t.Run("reports API errors", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusUnauthorized, `{"error":{"message":"bad key"}}`)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: openAIBackend{}}
+
+		_, err := p.QueryStream(context.Background(), "key", Request{}, func(string) {})
+		if err == nil || !strings.Contains(err.Error(), "bad key") {
+			t.Errorf("QueryStream() error = %v, want API error", err)
+		}
+	})
+}
+
+func TestAnthropicQueryStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delivers text deltas", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n",
+			"event: ping\ndata: {\"type\":\"ping\"}\n\n",
+			"event: content_block_delta\n"+
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"du"}}`+"\n\n",
+			"event: content_block_delta\n"+
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":" -sh"}}`+"\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: anthropicBackend{}}
+
+		var tokens []string
+
+		got, err := p.QueryStream(context.Background(), "key", Request{Model: "m", Prompt: "disk"}, func(token string) {
+			tokens = append(tokens, token)
+		})
+		if err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+
+		if got != "du -sh" || len(tokens) != 2 {
+			t.Errorf("QueryStream() = %q with tokens %q, want %q in 2 tokens", got, tokens, "du -sh")
+		}
+	})
+
+	t.Run("reports usage", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			"event: message_start\n"+
+				`data: {"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}`+"\n\n",
+			"event: content_block_delta\n"+
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"df -h"}}`+"\n\n",
+			"event: message_delta\n"+
+				`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`+"\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: anthropicBackend{}}
+
+		var usage Usage
+
+		req := Request{Model: "m", Prompt: "disk", OnUsage: func(u Usage) { usage = u }}
+		if _, err := p.QueryStream(context.Background(), "key", req, func(string) {}); err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+
+		if want := (Usage{InputTokens: 25, OutputTokens: 7}); usage != want {
+			t.Errorf("QueryStream() usage = %+v, want %+v", usage, want)
+		}
+	})
+
+	t.Run("reports error events", func(t *testing.T) {
+		t.Parallel()
+
+		srv := sseServer(t, http.StatusOK,
+			"event: error\n"+
+				`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`+"\n\n",
+		)
+		p := &Provider{Name: "Test", Endpoint: srv.URL, Backend: anthropicBackend{}, Retry: RetryPolicy{MaxAttempts: 1}}
+
+		_, err := p.QueryStream(context.Background(), "key", Request{}, func(string) {})
+		if err == nil || !strings.Contains(err.Error(), "Overloaded") {
+			t.Errorf("QueryStream() error = %v, want overloaded error", err)
+		}
+	})
+}
+
+func TestQueryStreamFallback(t *testing.T) {
+	t.Parallel()
+
+	p := &Provider{Name: "Plain", Backend: fakeBackend{key: "k"}}
+
+	var tokens []string
+
+	got, err := p.QueryStream(context.Background(), "k", Request{Model: "m", Prompt: "p"}, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("QueryStream() error = %v", err)
+	}
+
+	if len(tokens) != 1 || tokens[0] != got {
+		t.Errorf("QueryStream() tokens = %q, want the full response once", tokens)
+	}
+}
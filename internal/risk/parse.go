@@ -0,0 +1,102 @@
+package risk
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// parse parses src as a bash script, a superset of the POSIX shell.
+func parse(src string) (*syntax.File, error) {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse command")
+	}
+
+	return file, nil
+}
+
+// words returns the literal text of each word.
+func words(ws []*syntax.Word) []string {
+	args := make([]string, 0, len(ws))
+	for _, w := range ws {
+		args = append(args, literal(w))
+	}
+
+	return args
+}
+
+// literal returns the text of word after quote removal. Expansions are kept
+// as written, e.g. "$HOME" or "$(date)", since their values are unknown.
+func literal(word *syntax.Word) string {
+	if word == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	writeParts(&sb, word.Parts, false)
+
+	return sb.String()
+}
+
+func writeParts(sb *strings.Builder, parts []syntax.WordPart, quoted bool) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescape(p.Value, quoted))
+		case *syntax.SglQuoted:
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			writeParts(sb, p.Parts, true)
+		default:
+			_ = syntax.NewPrinter().Print(sb, part)
+		}
+	}
+}
+
+// unescape removes the backslashes quoting the next character. Inside double
+// quotes only $, `, ", \ and newlines are escaped.
+func unescape(s string, quoted bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) || (quoted && !strings.ContainsRune("$`\"\\\n", rune(s[i+1]))) {
+			sb.WriteByte(s[i])
+
+			continue
+		}
+
+		i++
+		if s[i] != '\n' {
+			sb.WriteByte(s[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// pipelineStmts returns the statements of a pipeline, left to right.
+func pipelineStmts(cmd syntax.Command) []*syntax.Stmt {
+	b, ok := cmd.(*syntax.BinaryCmd)
+	if !ok || (b.Op != syntax.Pipe && b.Op != syntax.PipeAll) {
+		return nil
+	}
+
+	var stmts []*syntax.Stmt
+
+	for _, side := range []*syntax.Stmt{b.X, b.Y} {
+		if nested := pipelineStmts(side.Cmd); nested != nil {
+			stmts = append(stmts, nested...)
+		} else {
+			stmts = append(stmts, side)
+		}
+	}
+
+	return stmts
+}
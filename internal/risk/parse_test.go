@@ -0,0 +1,109 @@
+package risk
+
+import (
+	"reflect"
+	"testing"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// calls returns the arguments of every simple command in src, in the order
+// they are walked.
+func calls(t *testing.T, src string) [][]string {
+	t.Helper()
+
+	file, err := parse(src)
+	if err != nil {
+		t.Fatalf("parse(%q) error = %v", src, err)
+	}
+
+	var got [][]string
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			got = append(got, words(call.Args))
+		}
+
+		return true
+	})
+
+	return got
+}
+
+func TestWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		want [][]string
+	}{
+		{
+			name: "quotes",
+			src:  `echo 'a b' "c $d \"e\"" f\ g "h\i"`,
+			want: [][]string{{"echo", "a b", `c $d "e"`, "f g", `h\i`}},
+		},
+		{
+			name: "pipelines and lists",
+			src:  "a | b && c; d || e & f",
+			want: [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}, {"f"}},
+		},
+		{
+			name: "comments",
+			src:  "a # comment | b\nc",
+			want: [][]string{{"a"}, {"c"}},
+		},
+		{
+			name: "substitutions",
+			src:  `echo "$(date +%s)" ` + "`whoami`" + ` ${HOME}/x $((1 + 2))`,
+			want: [][]string{{"echo", "$(date +%s)", "$(whoami)", "${HOME}/x", "$((1 + 2))"}, {"date", "+%s"}, {"whoami"}},
+		},
+		{
+			name: "process substitution",
+			src:  "diff <(ls a) <(ls b)",
+			want: [][]string{{"diff", "<(ls a)", "<(ls b)"}, {"ls", "a"}, {"ls", "b"}},
+		},
+		{
+			name: "compound commands",
+			src:  "for f in *; do rm \"$f\"; done; f() { touch x; }; case $1 in a) ls;; esac",
+			want: [][]string{{"rm", "$f"}, {"touch", "x"}, {"ls"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := calls(t, tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commands of %q = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineStmts(t *testing.T) {
+	t.Parallel()
+
+	file, err := parse("a | b |& c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+
+	for _, stmt := range pipelineStmts(file.Stmts[0].Cmd) {
+		got = append(got, words(stmt.Cmd.(*syntax.CallExpr).Args))
+	}
+
+	if want := [][]string{{"a"}, {"b"}, {"c"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pipelineStmts() = %q, want %q", got, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parse(`echo "oops`); err == nil {
+		t.Error("parse() of an unterminated quote succeeded")
+	}
+}
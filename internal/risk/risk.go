@@ -0,0 +1,710 @@
+// Package risk flags destructive shell commands before they reach the
+// terminal.
+//
+// Commands are parsed with mvdan.cc/sh rather than matched as text, so
+// quoting, sudo/env wrappers, pipelines, loops, functions, "sh -c" scripts,
+// here-documents fed to a shell and command substitutions are all looked
+// through.
+package risk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Level rates how much damage a command can do.
+type Level int
+
+// Risk levels, from harmless to irreversible.
+const (
+	// None means no known destructive pattern was found.
+	None Level = iota
+	// Low covers commands that delete or change a few named things.
+	Low
+	// Medium covers commands that discard work or delete in bulk.
+	Medium
+	// High covers commands that destroy data or run unreviewed code.
+	High
+	// Critical covers commands that can wipe a disk, the system or $HOME.
+	Critical
+)
+
+var levelNames = []string{"none", "low", "medium", "high", "critical"}
+
+func (l Level) String() string {
+	if l < None || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name such as "medium".
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+
+	return None, errors.Newf("unknown risk level %q (use %s)", name, strings.Join(levelNames, ", "))
+}
+
+// Finding is a destructive pattern found in a command.
+type Finding struct {
+	Level  Level
+	Reason string
+}
+
+// Highest returns the highest level among findings, or None.
+func Highest(findings []Finding) Level {
+	level := None
+	for _, f := range findings {
+		level = max(level, f.Level)
+	}
+
+	return level
+}
+
+// Analyze returns the destructive patterns found in command, most severe
+// first.
+func Analyze(command string) []Finding {
+	a := &analyzer{seen: map[string]bool{}}
+	a.script(command, 0)
+
+	sort.SliceStable(a.findings, func(i, j int) bool {
+		return a.findings[i].Level > a.findings[j].Level
+	})
+
+	return a.findings
+}
+
+// maxDepth bounds recursion into nested scripts and substitutions.
+const maxDepth = 8
+
+type analyzer struct {
+	findings []Finding
+	seen     map[string]bool
+}
+
+func (a *analyzer) add(level Level, format string, args ...any) {
+	reason := fmt.Sprintf(format, args...)
+	if a.seen[reason] {
+		return
+	}
+
+	a.seen[reason] = true
+	a.findings = append(a.findings, Finding{Level: level, Reason: reason})
+}
+
+func (a *analyzer) script(src string, depth int) {
+	if depth > maxDepth {
+		return
+	}
+
+	if isForkBomb(src) {
+		a.add(Critical, "fork bomb: spawns processes until the system locks up")
+	}
+
+	a.sql(src)
+
+	file, err := parse(src)
+	if err != nil {
+		// What cannot be parsed cannot be checked
+		a.add(Medium, "could not be checked: %v", errors.UnwrapAll(err))
+
+		return
+	}
+
+	// Walk reaches every simple command, including those in loops, function
+	// bodies and command or process substitutions
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.Stmt:
+			a.redirects(n.Redirs)
+			a.heredoc(n, depth)
+		case *syntax.BinaryCmd:
+			a.pipeline(pipelineStmts(n))
+		case *syntax.CallExpr:
+			a.call(n, depth)
+		}
+
+		return true
+	})
+}
+
+// call checks a simple command.
+func (a *analyzer) call(call *syntax.CallExpr, depth int) {
+	args, nested, input := unwrap(words(call.Args))
+	if nested != "" {
+		a.script(nested, depth+1)
+	}
+
+	if len(args) > 0 {
+		a.command(path.Base(args[0]), args, input)
+	}
+}
+
+// pipeline flags downloads piped into an interpreter.
+func (a *analyzer) pipeline(stmts []*syntax.Stmt) {
+	downloads := false
+
+	for _, stmt := range stmts {
+		call, ok := stmt.Cmd.(*syntax.CallExpr)
+		if !ok {
+			continue
+		}
+
+		args, _, _ := unwrap(words(call.Args))
+		if len(args) == 0 {
+			continue
+		}
+
+		name := path.Base(args[0])
+
+		if downloads && interpreters[name] {
+			a.add(High, "pipes a download straight into %s, running code nobody reviewed", name)
+		}
+
+		downloads = downloads || downloaders[name]
+	}
+}
+
+// heredoc checks the script a here-document or here-string feeds to a shell,
+// as in "bash <<EOF".
+func (a *analyzer) heredoc(stmt *syntax.Stmt, depth int) {
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return
+	}
+
+	args, nested, _ := unwrap(words(call.Args))
+	if len(args) == 0 || nested != "" || !shells[path.Base(args[0])] {
+		return
+	}
+
+	for _, r := range stmt.Redirs {
+		switch r.Op {
+		case syntax.Hdoc, syntax.DashHdoc:
+			a.script(literal(r.Hdoc), depth+1)
+		case syntax.WordHdoc:
+			a.script(literal(r.Word), depth+1)
+		default:
+		}
+	}
+}
+
+// Wrappers that run the rest of their arguments as a command, with the
+// options that take a separate value.
+var wrappers = map[string]map[string]bool{
+	"sudo":    {"-u": true, "-g": true, "-C": true, "-D": true, "-h": true, "-p": true, "-r": true, "-t": true, "-T": true, "-U": true},
+	"doas":    {"-u": true, "-C": true},
+	"env":     {"-u": true, "-C": true, "-S": true},
+	"nice":    {"-n": true},
+	"ionice":  {"-c": true, "-n": true},
+	"stdbuf":  {"-i": true, "-o": true, "-e": true},
+	"timeout": {"-s": true, "-k": true},
+	"xargs":   {"-I": true, "-n": true, "-P": true, "-L": true, "-d": true, "-s": true, "-a": true, "-E": true},
+	"time":    {"-f": true, "-o": true},
+	"nohup":   {},
+	"command": {},
+	"builtin": {},
+	"exec":    {"-a": true},
+	"noglob":  {},
+}
+
+var (
+	interpreters = map[string]bool{
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+		"python": true, "python3": true, "perl": true, "ruby": true, "node": true,
+	}
+	downloaders = map[string]bool{"curl": true, "wget": true, "fetch": true}
+	shells      = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true}
+)
+
+// xargsInput describes the operands xargs appends to a command.
+const xargsInput = "xargs reads from its input"
+
+// unwrap strips variable assignments and wrappers such as sudo from args. A
+// script passed to "sh -c" or eval is returned instead. input describes
+// where the command gets operands besides args, e.g. from xargs.
+func unwrap(args []string) ([]string, string, string) {
+	input := ""
+
+	for len(args) > 0 {
+		name := path.Base(args[0])
+
+		switch options, isWrapper := wrappers[name]; {
+		case isAssignment(args[0]):
+			args = args[1:]
+		case isWrapper:
+			if name == "xargs" {
+				input = xargsInput
+			}
+
+			args = skipOptions(args[1:], options)
+			if name == "timeout" && len(args) > 0 {
+				args = args[1:] // the duration
+			}
+		case name == "eval":
+			return nil, strings.Join(args[1:], " "), input
+		case shells[name]:
+			for i := 1; i < len(args)-1; i++ {
+				if arg := args[i]; strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c") {
+					return nil, args[i+1], input
+				}
+			}
+
+			return args, "", input
+		default:
+			return args, "", input
+		}
+	}
+
+	return nil, "", input
+}
+
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// skipOptions drops leading options, and the values of those in withValue.
+func skipOptions(args []string, withValue map[string]bool) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		option := args[0]
+		args = args[1:]
+
+		if option == "--" {
+			break
+		}
+
+		if withValue[option] && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+
+	return args
+}
+
+// invocation splits a command's arguments into flags and operands.
+type invocation struct {
+	short    map[rune]bool
+	long     map[string]bool
+	operands []string
+}
+
+func newInvocation(args []string) invocation {
+	inv := invocation{short: map[rune]bool{}, long: map[string]bool{}}
+
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			inv.operands = append(inv.operands, args[i+1:]...)
+
+			return inv
+		case strings.HasPrefix(arg, "--"):
+			name, _, _ := strings.Cut(arg[2:], "=")
+			inv.long[name] = true
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for _, r := range arg[1:] {
+				inv.short[r] = true
+			}
+		default:
+			inv.operands = append(inv.operands, arg)
+		}
+	}
+
+	return inv
+}
+
+// command checks a single command, args[0] being its name. input describes
+// where it gets operands besides args, e.g. from xargs or find -exec.
+func (a *analyzer) command(name string, args []string, input string) {
+	if runsDownload(args[0]) {
+		a.add(High, "runs the output of a download as a command")
+	}
+
+	if shells[name] {
+		for _, arg := range args[1:] {
+			if runsDownload(arg) {
+				a.add(High, "feeds a download straight into %s, running code nobody reviewed", name)
+			}
+		}
+	}
+
+	inv := newInvocation(args[1:])
+
+	switch {
+	case name == "rm":
+		a.rm(inv, input)
+	case name == "dd":
+		a.dd(inv)
+	case strings.HasPrefix(name, "mkfs") || name == "mke2fs" || name == "mkswap" || name == "wipefs" || name == "newfs":
+		a.add(Critical, "%s formats %s, erasing everything on it", name, lastOr(inv.operands, "a device"))
+	case name == "fdisk" || name == "sfdisk" || name == "gdisk" || name == "sgdisk" || name == "parted":
+		a.add(High, "%s edits a partition table", name)
+	case name == "shred":
+		a.add(High, "shred irrecoverably overwrites %s", strings.Join(inv.operands, " "))
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		a.chmod(name, inv)
+	case name == "git":
+		a.git(args[1:])
+	case name == "find":
+		a.find(args[1:])
+	case name == "mv" && lastOr(inv.operands, "") == "/dev/null":
+		a.add(High, "moves files to /dev/null, destroying them")
+	case name == "kill" && slices.Contains(args[1:], "-1"):
+		a.add(High, "kill -1 signals every process you are allowed to signal")
+	case name == "crontab" && inv.short['r']:
+		a.add(Medium, "crontab -r deletes all your cron jobs")
+	case name == "shutdown" || name == "reboot" || name == "halt" || name == "poweroff":
+		a.add(Medium, "%s stops or restarts the machine", name)
+	}
+}
+
+func (a *analyzer) rm(inv invocation, input string) {
+	recursive := inv.short['r'] || inv.short['R'] || inv.long["recursive"]
+
+	if inv.long["no-preserve-root"] {
+		a.add(Critical, "rm --no-preserve-root disables the guard against deleting /")
+	}
+
+	// {} is the placeholder xargs -I and find -exec replace with input
+	operands := slices.DeleteFunc(slices.Clone(inv.operands), func(s string) bool { return s == "{}" })
+	flagged := false
+
+	for _, target := range operands {
+		switch t := normalizePath(target); {
+		case recursive && t == "/":
+			a.add(Critical, "recursively deletes the root directory")
+		case recursive && isHome(t):
+			a.add(Critical, "recursively deletes your home directory (%s)", target)
+		case recursive && isSystemPath(t):
+			a.add(Critical, "recursively deletes the system directory %s", target)
+		case t == "*" || t == ".*" || t == ".":
+			a.add(either(recursive, High, Medium), "deletes everything in the current directory")
+		case emptyVariablePrefix(target):
+			a.add(either(recursive, High, Medium), "deletes from / if %s is empty or unset", strings.SplitN(target, "/", 2)[0])
+		default:
+			continue
+		}
+
+		flagged = true
+	}
+
+	targets := strings.Join(operands, " ")
+	if len(operands) == 0 {
+		targets = "every path " + input
+	}
+
+	switch {
+	case flagged || (len(operands) == 0 && input == ""):
+	case recursive:
+		a.add(Medium, "recursively deletes %s", targets)
+	default:
+		a.add(Low, "deletes %s", targets)
+	}
+}
+
+func (a *analyzer) dd(inv invocation) {
+	for _, operand := range inv.operands {
+		if target, ok := strings.CutPrefix(operand, "of="); ok && isDevice(target) {
+			a.add(Critical, "dd writes directly to %s, overwriting the device", target)
+		}
+	}
+}
+
+func (a *analyzer) chmod(name string, inv invocation) {
+	recursive := inv.short['R'] || inv.long["recursive"]
+	operands := inv.operands
+
+	worldWritable := false
+	if name == "chmod" && len(operands) > 0 {
+		mode := operands[0]
+		worldWritable = strings.HasSuffix(mode, "777") || strings.HasSuffix(mode, "666") ||
+			strings.Contains(mode, "o+w") || strings.Contains(mode, "a+w") || strings.Contains(mode, "a+rwx")
+		operands = operands[1:]
+	} else if len(operands) > 0 {
+		operands = operands[1:] // the owner or group
+	}
+
+	for _, target := range operands {
+		t := normalizePath(target)
+		if recursive && (t == "/" || isHome(t) || isSystemPath(t)) {
+			a.add(Critical, "%s -R changes ownership or permissions of everything under %s", name, target)
+
+			return
+		}
+	}
+
+	if worldWritable {
+		a.add(either(recursive, High, Medium), "makes %s world-writable", strings.Join(operands, " "))
+	}
+}
+
+// gitGlobalOptions are git options that take a separate value.
+var gitGlobalOptions = map[string]bool{"-C": true, "-c": true, "--git-dir": true, "--work-tree": true, "--namespace": true}
+
+func (a *analyzer) git(args []string) {
+	args = skipOptions(args, gitGlobalOptions)
+	if len(args) == 0 {
+		return
+	}
+
+	inv := newInvocation(args[1:])
+
+	switch args[0] {
+	case "push":
+		force := inv.short['f'] || inv.long["force"] || inv.long["force-with-lease"] || inv.long["mirror"]
+		branch := ""
+
+		for _, ref := range inv.operands {
+			if strings.HasPrefix(ref, "+") {
+				force = true
+			}
+
+			dst := ref[strings.LastIndex(ref, ":")+1:]
+			if b := strings.TrimPrefix(strings.TrimPrefix(dst, "+"), "refs/heads/"); b == "main" || b == "master" {
+				branch = b
+			}
+		}
+
+		switch {
+		case force && branch != "":
+			a.add(High, "force-pushes to %s, rewriting shared history", branch)
+		case force:
+			a.add(Medium, "force-push rewrites remote history")
+		}
+	case "reset":
+		if inv.long["hard"] {
+			a.add(Medium, "git reset --hard discards uncommitted changes")
+		}
+	case "clean":
+		if inv.short['f'] && (inv.short['d'] || inv.short['x']) {
+			a.add(Medium, "git clean deletes untracked files")
+		}
+	}
+}
+
+// findActions are find primaries that neither select files nor take a
+// value; any other primary narrows what find matches.
+var findActions = map[string]bool{
+	"-delete": true, "-depth": true, "-xdev": true, "-mount": true, "-print": true, "-print0": true,
+	"-ls": true, "-follow": true, "-noleaf": true, "-daystart": true, "-ignore_readdir_race": true,
+}
+
+func (a *analyzer) find(args []string) {
+	for len(args) > 0 && (args[0] == "-H" || args[0] == "-L" || args[0] == "-P") {
+		args = args[1:]
+	}
+
+	var roots []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") && args[0] != "(" && args[0] != "!" {
+		roots = append(roots, args[0])
+		args = args[1:]
+	}
+
+	deletes, filtered := false, false
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-delete":
+			deletes = true
+
+			a.add(Medium, "find -delete deletes every file it matches")
+		case arg == "-exec" || arg == "-execdir" || arg == "-ok" || arg == "-okdir":
+			end := i + 1
+			for end < len(args) && args[end] != ";" && args[end] != "+" {
+				end++
+			}
+
+			if inner, _, _ := unwrap(args[i+1 : end]); len(inner) > 0 {
+				name := path.Base(inner[0])
+				deletes = deletes || name == "rm"
+
+				a.command(name, inner, "find matches")
+			}
+
+			i = end
+		case arg == "-maxdepth" || arg == "-mindepth":
+			i++
+		case !findActions[arg]:
+			filtered = true
+		}
+	}
+
+	if !deletes {
+		return
+	}
+
+	for _, root := range roots {
+		if t := normalizePath(root); t == "/" || isHome(t) || isSystemPath(t) {
+			if filtered {
+				a.add(High, "find deletes the files it matches anywhere under %s", root)
+			} else {
+				a.add(Critical, "find deletes everything under %s", root)
+			}
+		}
+	}
+}
+
+// redirects flags output redirections onto devices and system files.
+func (a *analyzer) redirects(redirects []*syntax.Redirect) {
+	for _, r := range redirects {
+		switch r.Op {
+		case syntax.RdrOut, syntax.AppOut, syntax.ClbOut, syntax.RdrAll, syntax.AppAll, syntax.RdrInOut:
+		default:
+			continue
+		}
+
+		target := literal(r.Word)
+
+		switch {
+		case isDevice(target):
+			a.add(Critical, "overwrites the device %s", target)
+		case strings.HasPrefix(target, "/etc/") || strings.HasPrefix(target, "/boot/"):
+			a.add(either(r.Op == syntax.AppOut || r.Op == syntax.AppAll, Medium, High), "writes to the system file %s", target)
+		}
+	}
+}
+
+var (
+	dropPattern     = regexp.MustCompile(`(?i)\bdrop\s+(table|database|schema)\b`)
+	truncatePattern = regexp.MustCompile(`(?i)\btruncate\s+table\b`)
+	deletePattern   = regexp.MustCompile(`(?i)\bdelete\s+from\s+[\w."` + "`" + `]+\s*(;|'|"|$)`)
+)
+
+// sql flags destructive SQL, typically passed to psql -c or mysql -e.
+func (a *analyzer) sql(src string) {
+	if m := dropPattern.FindStringSubmatch(src); m != nil {
+		a.add(High, "SQL DROP %s permanently deletes data", strings.ToUpper(m[1]))
+	}
+
+	if truncatePattern.MatchString(src) {
+		a.add(High, "SQL TRUNCATE TABLE deletes every row")
+	}
+
+	if deletePattern.MatchString(src) {
+		a.add(Medium, "SQL DELETE without WHERE deletes every row")
+	}
+}
+
+// isForkBomb detects f(){ f|f& };f in any spacing, e.g. :(){ :|:& };:.
+func isForkBomb(src string) bool {
+	s := strings.Join(strings.Fields(src), "")
+
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], "(){")
+		if i < 0 {
+			return false
+		}
+
+		i += offset
+		start := strings.LastIndexAny(s[:i], ";&|({}") + 1
+
+		if name := s[start:i]; name != "" && strings.HasPrefix(s[i+3:], name+"|"+name+"&") {
+			return true
+		}
+
+		offset = i + 3
+	}
+}
+
+// runsDownload reports whether word substitutes the output of a downloader,
+// as in $(curl ...) or <(wget ...).
+func runsDownload(word string) bool {
+	for _, prefix := range []string{"$(", "<(", "`"} {
+		if body, ok := strings.CutPrefix(word, prefix); ok {
+			fields := strings.Fields(body)
+
+			return len(fields) > 0 && downloaders[path.Base(fields[0])]
+		}
+	}
+
+	return false
+}
+
+// normalizePath strips trailing slashes and a trailing "/*", so "/", "//"
+// and "/*" all become "/".
+func normalizePath(p string) string {
+	if p == "" {
+		return ""
+	}
+
+	if p = strings.TrimRight(strings.TrimSuffix(p, "/*"), "/"); p == "" {
+		return "/"
+	}
+
+	return p
+}
+
+func isHome(p string) bool {
+	switch p {
+	case "~", "$HOME", "${HOME}", "/home", "/Users", "/root":
+		return true
+	}
+
+	return false
+}
+
+var systemPaths = map[string]bool{
+	"/bin": true, "/boot": true, "/dev": true, "/etc": true, "/lib": true, "/lib64": true,
+	"/opt": true, "/proc": true, "/sbin": true, "/sys": true, "/usr": true, "/var": true,
+	"/Applications": true, "/Library": true, "/System": true,
+}
+
+func isSystemPath(p string) bool {
+	return systemPaths[p]
+}
+
+// emptyVariablePrefix reports whether a path starts with a variable other
+// than $HOME followed by "/", which becomes an absolute path when unset.
+func emptyVariablePrefix(p string) bool {
+	variable, _, ok := strings.Cut(p, "/")
+
+	return ok && strings.HasPrefix(variable, "$") && variable != "$HOME" && variable != "${HOME}"
+}
+
+var harmlessDevices = map[string]bool{
+	"/dev/null": true, "/dev/zero": true, "/dev/stdout": true, "/dev/stderr": true, "/dev/tty": true,
+}
+
+// isDevice reports whether p is a device node other than the harmless ones.
+func isDevice(p string) bool {
+	return strings.HasPrefix(p, "/dev/") && !harmlessDevices[p] && !strings.HasPrefix(p, "/dev/fd/")
+}
+
+// either returns ifTrue when cond holds and otherwise ifFalse.
+func either(cond bool, ifTrue, ifFalse Level) Level {
+	if cond {
+		return ifTrue
+	}
+
+	return ifFalse
+}
+
+func lastOr(values []string, fallback string) string {
+	if len(values) == 0 {
+		return fallback
+	}
+
+	return values[len(values)-1]
+}
@@ -0,0 +1,149 @@
+package risk
+
+import (
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command string
+		want    Level
+	}{
+		// Harmless
+		{command: "ls -la", want: None},
+		{command: "echo 'rm -rf /'", want: None},
+		{command: "grep -r 'dd of=/dev/sda' .", want: None},
+		{command: "find . -name '*.go' | xargs wc -l", want: None},
+		{command: "git push origin main", want: None},
+		{command: "chmod 755 script.sh", want: None},
+		{command: "ls > /dev/null 2>&1", want: None},
+		{command: "curl -fsSL https://example.com -o install.sh", want: None},
+		{command: "rm -rf \"$HOME/.cache/foo\"", want: Medium},
+
+		// rm
+		{command: "rm notes.txt", want: Low},
+		{command: "rm -rf build", want: Medium},
+		{command: "rm -rf /", want: Critical},
+		{command: "rm -rf /*", want: Critical},
+		{command: "sudo rm -rf --no-preserve-root /", want: Critical},
+		{command: "rm -r -f ~", want: Critical},
+		{command: "rm -rf \"$HOME\"", want: Critical},
+		{command: "rm -rf ${HOME}/", want: Critical},
+		{command: "sudo -u root rm -Rf /etc", want: Critical},
+		{command: "rm -rf \"$BUILD_DIR/\"*", want: High},
+		{command: "rm -rf *", want: High},
+		{command: "cd /tmp && rm -rf /", want: Critical},
+		{command: "FOO=1 env -i nice -n 5 rm -rf /usr", want: Critical},
+
+		// Disks
+		{command: "dd if=ubuntu.iso of=/dev/sdb bs=4M", want: Critical},
+		{command: "dd if=/dev/zero of=/dev/null count=1", want: None},
+		{command: "sudo mkfs.ext4 /dev/sdb1", want: Critical},
+		{command: "cat image.img > /dev/nvme0n1", want: Critical},
+		{command: "sudo parted /dev/sda mklabel gpt", want: High},
+
+		// Permissions
+		{command: "chmod -R 777 /", want: Critical},
+		{command: "sudo chown -R nobody /usr", want: Critical},
+		{command: "chmod -R 777 public", want: High},
+		{command: "chmod 777 file", want: Medium},
+
+		// Remote code
+		{command: "curl -fsSL https://get.example.com | sh", want: High},
+		{command: "wget -qO- https://example.com/install | sudo bash -s --", want: High},
+		{command: "bash <(curl -s https://example.com/setup)", want: High},
+		{command: "sh -c \"$(curl -fsSL https://example.com/install.sh)\"", want: High},
+
+		// Nested scripts and substitutions
+		{command: "bash -c 'rm -rf /'", want: Critical},
+		{command: "eval \"rm -rf ~\"", want: Critical},
+		{command: "echo $(rm -rf /)", want: Critical},
+		{command: `find / -name core -exec rm -rf {} \;`, want: High},
+		{command: "find . -name '*.tmp' -delete", want: Medium},
+		{command: "find / -delete", want: Critical},
+		{command: "find -L ~ -maxdepth 3 -delete", want: Critical},
+		{command: "find ~ -name '*.bak' -delete", want: High},
+		{command: "find build -type f -exec rm {} +", want: Low},
+		{command: "find / -name '*.go' -exec wc -l {} +", want: None},
+		{command: "xargs rm -rf < list", want: Medium},
+		{command: "xargs -I{} rm -rf {} < list", want: Medium},
+		{command: "cat list | xargs rm -rf /", want: Critical},
+
+		// Compound commands and here-documents
+		{command: "for d in a b; do rm -rf /; done", want: Critical},
+		{command: "cleanup() { rm -rf ~; }; cleanup", want: Critical},
+		{command: "case $1 in x) rm -rf /usr;; esac", want: Critical},
+		{command: "if true; then dd if=x of=/dev/sda; fi", want: Critical},
+		{command: "bash <<EOF\nrm -rf /\nEOF", want: Critical},
+		{command: "sh <<< 'rm -rf ~'", want: Critical},
+		{command: "cat <<EOF\nrm -rf /\nEOF", want: None},
+
+		// Unparseable commands are not passed unchecked
+		{command: `echo "unterminated`, want: Medium},
+
+		// Fork bombs
+		{command: ":(){ :|:& };:", want: Critical},
+		{command: "bomb() { bomb | bomb & }; bomb", want: Critical},
+
+		// git
+		{command: "git push --force origin main", want: High},
+		{command: "git push origin +master", want: High},
+		{command: "git -C repo push -f origin HEAD:refs/heads/main", want: High},
+		{command: "git push --force-with-lease origin feature", want: Medium},
+		{command: "git reset --hard HEAD~3", want: Medium},
+		{command: "git clean -fdx", want: Medium},
+
+		// SQL
+		{command: "psql -c 'DROP TABLE users;'", want: High},
+		{command: "mysql -e \"drop database prod\"", want: High},
+		{command: "psql -c 'DELETE FROM sessions;'", want: Medium},
+		{command: "psql -c 'DELETE FROM sessions WHERE expired'", want: None},
+
+		// Misc
+		{command: "mv important.db /dev/null", want: High},
+		{command: "kill -9 -1", want: High},
+		{command: "sudo reboot", want: Medium},
+		{command: "echo 'root:x' > /etc/passwd", want: High},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			t.Parallel()
+
+			findings := Analyze(tt.command)
+			if got := Highest(findings); got != tt.want {
+				t.Errorf("Analyze(%q) = %v (%v), want %v", tt.command, got, findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeOrder(t *testing.T) {
+	t.Parallel()
+
+	findings := Analyze("rm notes.txt; dd if=x of=/dev/sda")
+	if len(findings) != 2 || findings[0].Level != Critical || findings[1].Level != Low {
+		t.Errorf("Analyze() = %v, want the critical finding first", findings)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	for _, level := range []Level{None, Low, Medium, High, Critical} {
+		got, err := ParseLevel(level.String())
+		if err != nil || got != level {
+			t.Errorf("ParseLevel(%q) = %v, %v", level.String(), got, err)
+		}
+	}
+
+	if got, err := ParseLevel("HIGH"); err != nil || got != High {
+		t.Errorf("ParseLevel(%q) = %v, %v; want case-insensitive match", "HIGH", got, err)
+	}
+
+	if _, err := ParseLevel("severe"); err == nil {
+		t.Error("ParseLevel() expected error for unknown level")
+	}
+}
@@ -0,0 +1,88 @@
+// Package runner executes suggested commands in the user's shell, streaming
+// their output while keeping the tail of stderr for error reports.
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+
+	"github.com/cockroachdb/errors"
+)
+
+// maxStderrTail is how much of the end of stderr a Result keeps.
+const maxStderrTail = 2048
+
+// Result describes a finished command.
+type Result struct {
+	ExitCode int
+	// StderrTail is the last few kilobytes the command wrote to stderr.
+	StderrTail string
+}
+
+// Shell returns the shell commands run in and the flag that passes a command
+// string: $SHELL -c, /bin/sh -c without $SHELL, and cmd /C on Windows.
+func Shell() (string, string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", "/C"
+	}
+
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell, "-c"
+	}
+
+	return "/bin/sh", "-c"
+}
+
+// Run executes command in the user's shell with the terminal's stdin,
+// copying its output to stdout and stderr. A non-zero exit is reported in the
+// Result, not as an error. Interrupts go to the command, not to howto.
+func Run(ctx context.Context, command string, stdout, stderr io.Writer) (Result, error) {
+	shell, flag := Shell()
+	tail := &tailBuffer{limit: maxStderrTail}
+
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = io.MultiWriter(stderr, tail)
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	defer signal.Stop(interrupts)
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return Result{ExitCode: exitErr.ExitCode(), StderrTail: tail.String()}, nil
+	}
+
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "failed to run %s", shell)
+	}
+
+	return Result{StderrTail: tail.String()}, nil
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if excess := len(t.buf) - t.limit; excess > 0 {
+		t.buf = t.buf[excess:]
+	}
+
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
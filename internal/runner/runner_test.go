@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+
+	var stdout, stderr bytes.Buffer
+
+	result, err := Run(context.Background(), "echo out; echo err >&2; exit 3", &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.ExitCode != 3 || result.StderrTail != "err\n" {
+		t.Errorf("Run() = %+v, want exit code 3 and stderr tail %q", result, "err\n")
+	}
+
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("Run() streamed stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	result, err = Run(context.Background(), "true", &stdout, &stderr)
+	if err != nil || result.ExitCode != 0 {
+		t.Errorf("Run() = %+v, %v; want success", result, err)
+	}
+}
+
+func TestRunMissingShell(t *testing.T) {
+	t.Setenv("SHELL", "/nonexistent/shell")
+
+	if _, err := Run(context.Background(), "true", &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("Run() expected error for a missing shell")
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	t.Parallel()
+
+	tail := &tailBuffer{limit: 5}
+	_, _ = tail.Write([]byte("abc"))
+	_, _ = tail.Write([]byte(strings.Repeat("x", 3) + "yz"))
+
+	if got := tail.String(); got != "xxxyz" {
+		t.Errorf("tailBuffer = %q, want %q", got, "xxxyz")
+	}
+}
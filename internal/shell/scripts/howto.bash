@@ -0,0 +1,21 @@
+# howto shell integration for bash (4.0 or newer).
+#
+# Add to ~/.bashrc:
+#   eval "$(howto shell-init bash)"
+#
+# Type a request on the command line and press Alt-g: the line is replaced by
+# the suggested command, ready to edit or run. Rebind with:
+#   bind -x '"<key>": __howto_readline'
+
+__howto_readline() {
+  local query=$READLINE_LINE
+  [[ -z ${query//[[:space:]]/} ]] && return 0
+
+  local cmd
+  if cmd=$(command howto --quiet -- "$query") && [[ -n $cmd ]]; then
+    READLINE_LINE=$cmd
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+
+bind -x '"\eg": __howto_readline'
@@ -0,0 +1,24 @@
+# howto shell integration for fish.
+#
+# Add to ~/.config/fish/config.fish:
+#   howto shell-init fish | source
+#
+# Type a request on the command line and press Alt-g: the line is replaced by
+# the suggested command, ready to edit or run. Rebind with:
+#   bind <key> __howto_commandline
+
+function __howto_commandline
+    set -l query (commandline)
+    if test -z (string trim -- "$query")
+        return
+    end
+
+    set -l cmd (command howto --quiet -- "$query")
+    if test $status -eq 0; and test -n "$cmd"
+        commandline -r -- "$cmd"
+    end
+
+    commandline -f repaint
+end
+
+bind \eg __howto_commandline
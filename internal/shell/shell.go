@@ -0,0 +1,30 @@
+// Package shell provides line-editor integrations that place howto's
+// suggestions into the shell's input buffer without relying on TIOCSTI.
+package shell
+
+import (
+	"embed"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+)
+
+//go:embed scripts
+var scripts embed.FS
+
+// Supported lists the shells with an integration script.
+var Supported = []string{"bash", "fish", "zsh"}
+
+// Script returns the integration script for the named shell.
+func Script(name string) (string, error) {
+	if !slices.Contains(Supported, name) {
+		return "", errors.Newf("unsupported shell %q (supported: bash, fish, zsh)", name)
+	}
+
+	data, err := scripts.ReadFile("scripts/howto." + name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %s integration", name)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	t.Parallel()
+
+	// Each integration must call howto in quiet mode and write the line buffer.
+	tests := map[string]string{
+		"zsh":  "BUFFER=$cmd",
+		"bash": "READLINE_LINE=$cmd",
+		"fish": "commandline -r",
+	}
+
+	for name, bufferWrite := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Script(name)
+			if err != nil {
+				t.Fatalf("Script(%q) error = %v", name, err)
+			}
+
+			for _, want := range []string{"howto --quiet --", bufferWrite} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Script(%q) missing %q", name, want)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown shell", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := Script("tcsh"); err == nil {
+			t.Error("Script(\"tcsh\") expected error, got nil")
+		}
+	})
+}
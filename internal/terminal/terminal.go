@@ -0,0 +1,10 @@
+package terminal
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// ErrInjectionUnsupported is returned by InsertInput when the kernel refuses to
+// push input into the terminal (e.g. Linux 6.2+ with dev.tty.legacy_tiocsti=0,
+// or OpenBSD). The command has been printed instead.
+var ErrInjectionUnsupported = errors.New("terminal input injection (TIOCSTI) is not permitted")
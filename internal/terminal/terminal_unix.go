@@ -7,27 +7,41 @@ import (
 	"syscall"
 	"unsafe"
 
+	"github.com/cockroachdb/errors"
 	"golang.org/x/term"
 )
 
 // InsertInput inserts the command into the terminal's input buffer.
 // This allows the user to see and edit the command before executing it.
-func InsertInput(cmd string) {
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+// If the kernel refuses the injection, the command is printed instead and
+// ErrInjectionUnsupported is returned.
+func InsertInput(cmd string) error {
+	fd := int(os.Stdin.Fd())
+
+	oldState, err := term.MakeRaw(fd)
 	if err != nil {
 		// Fallback to just printing the command
 		printCommand(cmd)
 
-		return
+		return nil
 	}
 
-	defer func() { _ = term.Restore(int(os.Stdin.Fd()), oldState) }()
+	for i := range len(cmd) {
+		char := cmd[i]
+
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCSTI, uintptr(unsafe.Pointer(&char)))
+		if errno != 0 {
+			_ = term.Restore(fd, oldState)
+
+			printCommand(cmd)
 
-	for _, c := range cmd {
-		char := byte(c)
-		//nolint:errcheck // TIOCSTI may fail on some systems, we handle by falling back
-		syscall.Syscall(syscall.SYS_IOCTL, uintptr(0), syscall.TIOCSTI, uintptr(unsafe.Pointer(&char)))
+			return errors.Wrapf(ErrInjectionUnsupported, "%v", errno)
+		}
 	}
+
+	_ = term.Restore(fd, oldState)
+
+	return nil
 }
 
 func printCommand(cmd string) {
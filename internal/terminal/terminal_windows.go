@@ -7,6 +7,8 @@ import (
 )
 
 // InsertInput on Windows prints the command since TIOCSTI is not available.
-func InsertInput(cmd string) {
+func InsertInput(cmd string) error {
 	fmt.Println(cmd)
+
+	return nil
 }
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// maxAnnotationWidth caps the text column; longer text moves its description
+// to the next line.
+const maxAnnotationWidth = 40
+
+// Annotation pairs a piece of a command with its description.
+type Annotation struct {
+	Text        string
+	Description string
+	// Level is the nesting depth: 0 for a command, 1 for its flags and arguments.
+	Level int
+}
+
+// PrintAnnotations prints annotations as a colorized two-column breakdown with
+// the descriptions aligned.
+func PrintAnnotations(items []Annotation) {
+	width := 0
+
+	for _, item := range items {
+		if w := annotationWidth(item); w <= maxAnnotationWidth && w > width {
+			width = w
+		}
+	}
+
+	command := color.New(color.FgGreen, color.Bold)
+	argument := color.New(color.FgYellow)
+	description := color.New(color.Faint)
+
+	for _, item := range items {
+		indent := strings.Repeat("  ", item.Level)
+
+		c := argument
+		if item.Level == 0 {
+			c = command
+		}
+
+		_, _ = c.Fprint(output, indent+item.Text)
+
+		pad := width - annotationWidth(item)
+		if pad < 0 {
+			// Too wide to align: put the description on its own line
+			_, _ = output.Write([]byte("\n"))
+			pad = width
+		}
+
+		_, _ = output.Write([]byte(strings.Repeat(" ", pad+2)))
+		_, _ = description.Fprintln(output, item.Description)
+	}
+}
+
+func annotationWidth(item Annotation) int {
+	return 2*item.Level + utf8.RuneCountInString(item.Text)
+}
@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// Answer is the reply to a question asked with Ask.
+type Answer int
+
+const (
+	// AnswerNo declines; it is the default.
+	AnswerNo Answer = iota
+	// AnswerYes accepts.
+	AnswerYes
+	// AnswerRefine asks to refine the command in question first.
+	AnswerRefine
+)
+
+// Confirm asks a yes/no question on stderr and reads a single key press from
+// the terminal. Anything but y declines.
+func Confirm(question string) (bool, error) {
+	answer, err := ask(question, false)
+
+	return answer == AnswerYes, err
+}
+
+// ConfirmOrRefine is Confirm with a third choice: r answers AnswerRefine.
+func ConfirmOrRefine(question string) (Answer, error) {
+	return ask(question, true)
+}
+
+func ask(question string, refine bool) (Answer, error) {
+	fd := int(os.Stdin.Fd())
+
+	keys := "[y/N]"
+	if refine {
+		keys = "[y/N/r to refine]"
+	}
+
+	_, _ = color.New(color.FgYellow, color.Bold).Fprint(os.Stderr, question+" "+keys+" ")
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return AnswerNo, errors.Wrap(err, "failed to enable raw mode")
+	}
+
+	key := make([]byte, 1)
+	_, err = os.Stdin.Read(key)
+	_ = term.Restore(fd, state)
+
+	if err != nil {
+		return AnswerNo, errors.Wrap(err, "failed to read answer")
+	}
+
+	answer, label := answerKey(key[0], refine)
+	_, _ = fmt.Fprintln(os.Stderr, label)
+
+	return answer, nil
+}
+
+// answerKey maps a key press to the answer, and the word echoed for it; r
+// only refines when refine is set.
+func answerKey(key byte, refine bool) (Answer, string) {
+	switch {
+	case key == 'y' || key == 'Y':
+		return AnswerYes, "yes"
+	case refine && (key == 'r' || key == 'R'):
+		return AnswerRefine, "refine"
+	}
+
+	return AnswerNo, "no"
+}
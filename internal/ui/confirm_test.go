@@ -0,0 +1,27 @@
+package ui
+
+import "testing"
+
+func TestAnswerKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key    byte
+		refine bool
+		want   Answer
+	}{
+		{key: 'y', want: AnswerYes},
+		{key: 'Y', refine: true, want: AnswerYes},
+		{key: 'n', want: AnswerNo},
+		{key: '\r', refine: true, want: AnswerNo},
+		{key: 'r', refine: true, want: AnswerRefine},
+		{key: 'R', refine: true, want: AnswerRefine},
+		{key: 'r', want: AnswerNo},
+	}
+
+	for _, tt := range tests {
+		if got, _ := answerKey(tt.key, tt.refine); got != tt.want {
+			t.Errorf("answerKey(%q, %v) = %v, want %v", tt.key, tt.refine, got, tt.want)
+		}
+	}
+}
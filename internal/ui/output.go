@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/fatih/color"
 )
@@ -20,23 +21,38 @@ const (
 	OutputInfo
 )
 
+// output is where all ui messages are written.
+var output io.Writer = color.Output
+
+// SetOutput redirects all ui messages, headers and tables to w. Commands that
+// reserve stdout for machine-readable results point it at stderr.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
 // Print prints colored output.
 func Print(outputType OutputType, message string) {
+	var c *color.Color
+
 	switch outputType {
 	case OutputSuccess:
-		color.Green(message)
+		c = color.New(color.FgGreen)
 	case OutputError:
-		color.Red(message)
+		c = color.New(color.FgRed)
 	case OutputWarning:
-		color.Yellow(message)
+		c = color.New(color.FgYellow)
 	case OutputInfo:
-		color.Cyan(message)
+		c = color.New(color.FgCyan)
+	default:
+		return
 	}
+
+	_, _ = c.Fprintln(output, message)
 }
 
 // PrintHeader prints a formatted header.
 func PrintHeader(title string) {
-	color.Cyan("\n=== %s ===\n", title)
+	_, _ = color.New(color.FgCyan).Fprintf(output, "\n=== %s ===\n", title)
 }
 
 // PrintSuccess prints a success message.
@@ -59,6 +75,20 @@ func PrintInfo(message string) {
 	Print(OutputInfo, "ℹ "+message)
 }
 
+// PrintCommand prints a shell command, prefixed with a prompt sign.
+func PrintCommand(command string) {
+	_, _ = color.New(color.FgGreen, color.Bold).Fprintln(output, "$ "+command)
+}
+
+// PrintDanger prints a prominent warning followed by one line per reason.
+func PrintDanger(title string, reasons []string) {
+	_, _ = color.New(color.FgRed, color.Bold).Fprintln(output, "⚠ "+title)
+
+	for _, reason := range reasons {
+		_, _ = color.New(color.FgRed).Fprintln(output, "  • "+reason)
+	}
+}
+
 // PrintTable prints a simple table.
 func PrintTable(headers []string, rows [][]string) {
 	// Calculate column widths
@@ -77,38 +107,38 @@ func PrintTable(headers []string, rows [][]string) {
 
 	// Print headers
 	for i, header := range headers {
-		fmt.Printf("%-*s", colWidths[i], header)
+		_, _ = fmt.Fprintf(output, "%-*s", colWidths[i], header)
 
 		if i < len(headers)-1 {
-			fmt.Print("  ")
+			_, _ = fmt.Fprint(output, "  ")
 		}
 	}
 
-	fmt.Println()
+	_, _ = fmt.Fprintln(output)
 
 	// Print separator
 	for i := range headers {
 		for range colWidths[i] {
-			fmt.Print("-")
+			_, _ = fmt.Fprint(output, "-")
 		}
 
 		if i < len(headers)-1 {
-			fmt.Print("  ")
+			_, _ = fmt.Fprint(output, "  ")
 		}
 	}
 
-	fmt.Println()
+	_, _ = fmt.Fprintln(output)
 
 	// Print rows
 	for _, row := range rows {
 		for i, cell := range row {
-			fmt.Printf("%-*s", colWidths[i], cell)
+			_, _ = fmt.Fprintf(output, "%-*s", colWidths[i], cell)
 
 			if i < len(row)-1 {
-				fmt.Print("  ")
+				_, _ = fmt.Fprint(output, "  ")
 			}
 		}
 
-		fmt.Println()
+		_, _ = fmt.Fprintln(output)
 	}
 }
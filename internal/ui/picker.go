@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// ErrPickCanceled is returned by Pick when the user dismisses the picker.
+var ErrPickCanceled = errors.New("selection canceled")
+
+// ErrPickRefine is returned by Pick, together with the highlighted choice,
+// when the user presses r to refine it.
+var ErrPickRefine = errors.New("refinement requested")
+
+// Choice is an entry of the picker.
+type Choice struct {
+	Label  string
+	Detail string
+}
+
+// Interactive reports whether a picker can be shown, i.e. whether stdin and
+// stderr are both terminals.
+func Interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// Pick shows an arrow-key picker on stderr and returns the index of the
+// chosen item. Up/down or k/j move, enter selects, 1-9 select directly, r
+// selects the highlighted item for refinement and esc, q or Ctrl-C cancel.
+func Pick(choices []Choice) (int, error) {
+	fd := int(os.Stdin.Fd())
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to enable raw mode")
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	width, _, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	out := os.Stderr
+	faint := color.New(color.Faint)
+	selected := color.New(color.FgGreen, color.Bold)
+
+	_, _ = faint.Fprint(out, "↑/↓ to move, enter to select, r to refine, esc to cancel\r\n")
+
+	render := func(current int) {
+		for i, c := range choices {
+			marker, style := "  ", color.New(color.Reset)
+			if i == current {
+				marker, style = "> ", selected
+			}
+
+			label := Truncate(fmt.Sprintf("%s%d. %s", marker, i+1, c.Label), width-1)
+			_, _ = fmt.Fprint(out, "\r\033[K")
+			_, _ = style.Fprint(out, label)
+
+			if room := width - 3 - utf8.RuneCountInString(label); c.Detail != "" && room > 0 {
+				_, _ = faint.Fprint(out, "  "+Truncate(c.Detail, room))
+			}
+
+			_, _ = fmt.Fprint(out, "\r\n")
+		}
+	}
+
+	// Erase the hint and the list once a choice is made
+	defer func() { _, _ = fmt.Fprintf(out, "\033[%dA\r\033[J", len(choices)+1) }()
+
+	current := 0
+	buf := make([]byte, 8)
+
+	for {
+		render(current)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to read key")
+		}
+
+		next, done, err := pickKey(string(buf[:n]), current, len(choices))
+		if done {
+			return next, err
+		}
+
+		current = next
+
+		_, _ = fmt.Fprintf(out, "\033[%dA", len(choices))
+	}
+}
+
+// pickKey applies a key press to a picker of count choices with current
+// highlighted. It returns the choice highlighted next or, once done, the
+// result of Pick.
+func pickKey(key string, current, count int) (int, bool, error) {
+	switch {
+	case key == "\r" || key == "\n":
+		return current, true, nil
+	case key == "\x1b[A" || key == "\x1bOA" || key == "k" || key == "\x10":
+		return (current + count - 1) % count, false, nil
+	case key == "\x1b[B" || key == "\x1bOB" || key == "j" || key == "\x0e":
+		return (current + 1) % count, false, nil
+	case key == "\x1b" || key == "q" || key == "\x03":
+		return 0, true, ErrPickCanceled
+	case key == "r":
+		return current, true, ErrPickRefine
+	case len(key) == 1 && key[0] >= '1' && key[0] <= '9' && int(key[0]-'1') < count:
+		return int(key[0] - '1'), true, nil
+	}
+
+	return current, false, nil
+}
+
+// Truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func Truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	if n <= 1 {
+		return strings.Repeat("…", max(n, 0))
+	}
+
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPickKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		key      string
+		current  int
+		wantNext int
+		wantDone bool
+		wantErr  error
+	}{
+		{name: "enter selects", key: "\r", current: 1, wantNext: 1, wantDone: true},
+		{name: "newline selects", key: "\n", current: 2, wantNext: 2, wantDone: true},
+		{name: "up moves", key: "\x1b[A", current: 1, wantNext: 0},
+		{name: "up wraps", key: "k", current: 0, wantNext: 2},
+		{name: "down moves", key: "\x1bOB", current: 0, wantNext: 1},
+		{name: "down wraps", key: "j", current: 2, wantNext: 0},
+		{name: "digit selects", key: "3", current: 0, wantNext: 2, wantDone: true},
+		{name: "digit past the list is ignored", key: "4", current: 1, wantNext: 1},
+		{name: "r refines the highlighted choice", key: "r", current: 1, wantNext: 1, wantDone: true, wantErr: ErrPickRefine},
+		{name: "R is ignored", key: "R", current: 1, wantNext: 1},
+		{name: "esc cancels", key: "\x1b", current: 2, wantNext: 0, wantDone: true, wantErr: ErrPickCanceled},
+		{name: "ctrl-c cancels", key: "\x03", current: 1, wantNext: 0, wantDone: true, wantErr: ErrPickCanceled},
+		{name: "other keys are ignored", key: "x", current: 1, wantNext: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			next, done, err := pickKey(tt.key, tt.current, 3)
+			if next != tt.wantNext || done != tt.wantDone || !errors.Is(err, tt.wantErr) {
+				t.Errorf("pickKey(%q, %d, 3) = %d, %v, %v; want %d, %v, %v",
+					tt.key, tt.current, next, done, err, tt.wantNext, tt.wantDone, tt.wantErr)
+			}
+		})
+	}
+}
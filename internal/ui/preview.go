@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// Preview renders streamed response text on stderr while it is generated and
+// erases it once the final output is ready, so stdout only carries results.
+type Preview struct {
+	out     io.Writer
+	width   int
+	enabled bool
+	text    strings.Builder
+}
+
+// NewPreview creates a preview that is active only when stderr is a terminal.
+func NewPreview() *Preview {
+	fd := int(os.Stderr.Fd())
+
+	width, _, err := term.GetSize(fd)
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	return &Preview{
+		out:     os.Stderr,
+		width:   width,
+		enabled: term.IsTerminal(fd),
+	}
+}
+
+// Write appends a streamed token to the preview.
+func (p *Preview) Write(token string) {
+	if !p.enabled {
+		return
+	}
+
+	p.text.WriteString(token)
+	_, _ = color.New(color.Faint).Fprint(p.out, token)
+}
+
+// Clear erases everything the preview has printed.
+func (p *Preview) Clear() {
+	if !p.enabled || p.text.Len() == 0 {
+		return
+	}
+
+	rows := 0
+
+	for line := range strings.SplitSeq(p.text.String(), "\n") {
+		rows += max(1, (utf8.RuneCountInString(line)+p.width-1)/p.width)
+	}
+
+	if rows > 1 {
+		_, _ = fmt.Fprintf(p.out, "\033[%dA", rows-1)
+	}
+
+	_, _ = fmt.Fprint(p.out, "\r\033[J")
+
+	p.text.Reset()
+}